@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	aclean "github.com/airenas/async-api/pkg/clean"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/airenas/big-tts/internal/pkg/clean"
 	"github.com/airenas/big-tts/internal/pkg/mongo"
+	"github.com/airenas/big-tts/internal/pkg/storage"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/labstack/gommon/color"
 	"github.com/pkg/errors"
@@ -47,6 +49,9 @@ func main() {
 			goapp.Log.Fatal(errors.Wrap(err, "can't init IDs provider"))
 		}
 	} else if typ == "dir" {
+		if isS3(cfg) {
+			goapp.Log.Fatalf("cleaner type '%s' is not supported for s3 storage", typ)
+		}
 		tData.IDsProvider, err = afile.NewOldDirProvider(cfg.GetDuration("timer.expire"), cfg.GetString("fileStorage.path"))
 		if err != nil {
 			goapp.Log.Fatal(errors.Wrap(err, "can't init IDs provider"))
@@ -100,10 +105,32 @@ func getDbCleaners(msp *amongo.SessionProvider) ([]clean.Cleaner, error) {
 	return res, nil
 }
 
+func isS3(cfg *viper.Viper) bool {
+	return strings.EqualFold(strings.TrimSpace(cfg.GetString("storage.type")), storage.TypeS3)
+}
+
 func getFileCleaners(cfg *viper.Viper) ([]clean.Cleaner, error) {
 	patterns := cfg.GetStringSlice("fileStorage.patterns")
 	path := cfg.GetString("fileStorage.path")
 	res := make([]clean.Cleaner, 0)
+	if isS3(cfg) {
+		st, err := storage.NewFromConfig(cfg, path)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't init storage")
+		}
+		s3, ok := st.(*storage.S3)
+		if !ok {
+			return nil, errors.New("no S3 storage")
+		}
+		for _, p := range patterns {
+			cl, err := storage.NewCleaner(s3, p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can't init cleaner for %s", p)
+			}
+			res = append(res, cl)
+		}
+		return res, nil
+	}
 	for _, p := range patterns {
 		cl, err := aclean.NewLocalFile(path, p)
 		if err != nil {
@@ -17,7 +17,7 @@ func Test_getDbCleaners(t *testing.T) {
 		want    int
 		wantErr bool
 	}{
-		{name: "OK", args: args{msp: &amongo.SessionProvider{}}, want: 3, wantErr: false},
+		{name: "OK", args: args{msp: &amongo.SessionProvider{}}, want: 5, wantErr: false},
 		{name: "Fails", args: args{msp: nil}, want: 0, wantErr: true},
 	}
 	for _, tt := range tests {
@@ -65,3 +65,21 @@ func Test_getFileCleaners(t *testing.T) {
 		})
 	}
 }
+
+func Test_getFileCleaners_S3(t *testing.T) {
+	v := viper.New()
+	v.Set("fileStorage.patterns", []string{"in/{ID}.*", "work/{ID}/"})
+	v.Set("storage.type", "s3")
+	v.Set("storage.s3.url", "http://minio:9000")
+	v.Set("storage.s3.bucket", "tts")
+	v.Set("storage.s3.accessKey", "a")
+	v.Set("storage.s3.secretKey", "s")
+	got, err := getFileCleaners(v)
+	if err != nil || len(got) != 2 {
+		t.Errorf("getFileCleaners() = %v, %v, want 2", len(got), err)
+	}
+	v.Set("storage.s3.bucket", "")
+	if _, err := getFileCleaners(v); err == nil {
+		t.Errorf("getFileCleaners() expected error")
+	}
+}
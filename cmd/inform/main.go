@@ -83,6 +83,24 @@ func main() {
 		goapp.Log.Fatal(errors.Wrap(err, "can't init email retriever"))
 	}
 
+	if secret := cfg.GetString("callback.secret"); secret != "" {
+		data.CallbackSender, err = inform.NewHTTPCallbackSender(secret, cfg.GetInt("callback.retries"),
+			cfg.GetDuration("callback.backoff"), cfg.GetDuration("callback.timeout"))
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "can't init callback sender"))
+		}
+		data.CallbackRetriever, err = mongo.NewRequest(mongoSessionProvider)
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "can't init callback retriever"))
+		}
+		if data.CallbackWorkCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Callback)); err != nil {
+			goapp.Log.Fatal(err)
+		}
+		data.MsgSender = rabbit.NewSender(msgChannelProvider)
+	} else {
+		goapp.Log.Warn("No callback.secret, callbacks disabled")
+	}
+
 	printBanner()
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
@@ -110,7 +128,7 @@ func main() {
 
 func initQueues(prv *rabbit.ChannelProvider) error {
 	goapp.Log.Info("Initializing queues")
-	for _, n := range [...]string{messages.Inform} {
+	for _, n := range [...]string{messages.Inform, messages.Callback} {
 		err := prv.RunOnChannelWithRetry(func(ch *amqp.Channel) error {
 			_, err := rabbit.DeclareQueue(ch, prv.QueueName(n))
 			return err
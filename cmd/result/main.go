@@ -3,9 +3,9 @@ package main
 import (
 	mng "github.com/airenas/async-api/pkg/mongo"
 
-	"github.com/airenas/async-api/pkg/file"
-	"github.com/airenas/big-tts/internal/pkg/result"
 	"github.com/airenas/big-tts/internal/pkg/mongo"
+	"github.com/airenas/big-tts/internal/pkg/result"
+	"github.com/airenas/big-tts/internal/pkg/storage"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/labstack/gommon/color"
 	"github.com/pkg/errors"
@@ -18,7 +18,7 @@ func main() {
 	data.Port = cfg.GetInt("port")
 	var err error
 
-	data.Reader, err = file.NewLocalLoader(cfg.GetString("fileStorage.path"))
+	data.Reader, err = storage.NewFromConfig(cfg, cfg.GetString("fileStorage.path"))
 	if err != nil {
 		goapp.Log.Fatal(errors.Wrap(err, "can't init file storage reader"))
 	}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -13,12 +15,18 @@ import (
 	"github.com/airenas/big-tts/internal/pkg/messages"
 	"github.com/airenas/big-tts/internal/pkg/mongo"
 	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/big-tts/internal/pkg/status"
+	"github.com/airenas/big-tts/internal/pkg/storage"
 	"github.com/airenas/big-tts/internal/pkg/synthesize"
 	"github.com/airenas/big-tts/internal/pkg/synthesizer"
+	"github.com/airenas/big-tts/internal/pkg/upload"
 	"github.com/airenas/big-tts/internal/pkg/usage"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/labstack/gommon/color"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
 	"github.com/streadway/amqp"
 )
 
@@ -34,34 +42,6 @@ func main() {
 		goapp.Log.Fatal(errors.Wrap(err, "can't init rabbitmq channel provider"))
 	}
 	defer msgChannelProvider.Close()
-	err = initQueues(msgChannelProvider)
-	if err != nil {
-		goapp.Log.Fatal(errors.Wrap(err, "can't init queues"))
-	}
-
-	ch, err := msgChannelProvider.Channel()
-	if err != nil {
-		goapp.Log.Fatal(errors.Wrap(err, "can't open channel"))
-	}
-	if err = ch.Qos(1, 0, false); err != nil {
-		goapp.Log.Fatal(errors.Wrap(err, "can't set Qos"))
-	}
-
-	if data.UploadCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Upload)); err != nil {
-		goapp.Log.Fatal(err)
-	}
-	if data.SplitCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Split)); err != nil {
-		goapp.Log.Fatal(err)
-	}
-	if data.SynthesizeCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Synthesize)); err != nil {
-		goapp.Log.Fatal(err)
-	}
-	if data.JoinCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Join)); err != nil {
-		goapp.Log.Fatal(err)
-	}
-	if data.RestoreUsageCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Fail)); err != nil {
-		goapp.Log.Fatal(err)
-	}
 
 	data.MsgSender = rabbit.NewSender(msgChannelProvider)
 	data.InformMsgSender = data.MsgSender
@@ -72,36 +52,77 @@ func main() {
 	}
 	defer mongoSessionProvider.Close()
 
-	data.StatusSaver, err = mongo.NewStatus(mongoSessionProvider)
+	statusStore, err := mongo.NewStatus(mongoSessionProvider)
 	if err != nil {
 		goapp.Log.Fatal(errors.Wrap(err, "can't init mongo status saver"))
 	}
-	data.Splitter, err = splitter.NewWorker(cfg.GetString("splitter.inTemplate"),
-		cfg.GetString("splitter.outTemplate"))
-	if err != nil {
-		goapp.Log.Fatal(errors.Wrap(err, "can't init splitter"))
-	}
-	data.Synthesizer, err = synthesizer.NewWorker(cfg.GetString("splitter.outTemplate"),
-		cfg.GetString("synthesizer.outTemplate"),
-		cfg.GetString("synthesizer.URL"),
-		cfg.GetInt("synthesizer.workers"))
+	data.StatusSaver = statusStore
+	data.CancelChecker = statusStore
+	cfg.SetDefault("cancel.checkInterval", 5*time.Second)
+	data.CancelCheckInterval = cfg.GetDuration("cancel.checkInterval")
+	goapp.Log.Infof("Cancel check interval: %s", data.CancelCheckInterval)
+	st, err := storage.NewFromConfig(cfg, "")
 	if err != nil {
-		goapp.Log.Fatal(errors.Wrap(err, "can't init synthesizer"))
+		goapp.Log.Fatal(errors.Wrap(err, "can't init storage"))
 	}
 	data.UsageRestorer, err = usage.NewWorker(cfg.GetString("doorman.URL"))
 	if err != nil {
 		goapp.Log.Fatal(errors.Wrap(err, "can't init usage restorer"))
 	}
-	data.Joiner, err = joiner.NewWorker(cfg.GetString("synthesizer.outTemplate"),
-		cfg.GetString("joiner.outTemplate"),
-		cfg.GetString("joiner.workTemplate"),
-		cfg.GetStringSlice("joiner.metadata"))
+	reg := synthesize.NewRegistry()
+	// the usage is restored for the jobs canceled before or while synthesizing,
+	// the synthesizer restores the part of not synthesized parts
+	for _, r := range []struct {
+		name string
+		f    synthesize.WorkerFactory
+		opt  synthesize.StageOptions
+	}{
+		{name: status.Split.String(), f: func() (synthesize.Worker, error) { return newSplitter(cfg, st, mongoSessionProvider) },
+			opt: synthesize.StageOptions{RestorePart: 1}},
+		{name: status.Synthesize.String(), f: func() (synthesize.Worker, error) { return newSynthesizer(cfg, st) },
+			opt: synthesize.StageOptions{RestorePart: 1, WatchCancel: true}},
+		{name: status.Join.String(), f: func() (synthesize.Worker, error) { return newJoiner(cfg, st) }},
+	} {
+		if err := reg.Register(r.name, r.f, r.opt); err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "can't register stage"))
+		}
+	}
+	cfg.SetDefault("pipeline.stages", synthesize.DefaultStages)
+	data.Stages, err = reg.NewStages(cfg.GetStringSlice("pipeline.stages"))
 	if err != nil {
-		goapp.Log.Fatal(errors.Wrap(err, "can't init joiner"))
+		goapp.Log.Fatal(errors.Wrap(err, "can't init pipeline"))
+	}
+
+	err = initQueues(msgChannelProvider, data.Stages)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init queues"))
+	}
+	ch, err := msgChannelProvider.Channel()
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't open channel"))
+	}
+	if err = ch.Qos(1, 0, false); err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't set Qos"))
+	}
+	if data.UploadCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Upload)); err != nil {
+		goapp.Log.Fatal(err)
+	}
+	for _, s := range data.Stages {
+		if s.Ch, err = makeQChannel(ch, msgChannelProvider.QueueName(s.Queue)); err != nil {
+			goapp.Log.Fatal(err)
+		}
+	}
+	if data.RestoreUsageCh, err = makeQChannel(ch, msgChannelProvider.QueueName(messages.Fail)); err != nil {
+		goapp.Log.Fatal(err)
 	}
 
 	printBanner()
 
+	if port := cfg.GetInt("metrics.port"); port > 0 {
+		prometheus.MustRegister(synthesizer.CacheCollectors()...)
+		go startMetrics(port)
+	}
+
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	data.StopCtx = ctx
 	doneCh, err := synthesize.StartWorkerService(ctx, data)
@@ -126,10 +147,95 @@ func main() {
 	}
 }
 
-func initQueues(prv *rabbit.ChannelProvider) error {
+func startMetrics(port int) {
+	goapp.Log.Infof("Starting metrics at %d", port)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Addr: ":" + strconv.Itoa(port), Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	if err := srv.ListenAndServe(); err != nil {
+		goapp.Log.Error(errors.Wrap(err, "can't start metrics server"))
+	}
+}
+
+func newSplitter(cfg *viper.Viper, st storage.Storage, sp *mng.SessionProvider) (synthesize.Worker, error) {
+	sw, err := splitter.NewWorker(st, cfg.GetString("splitter.inTemplate"),
+		cfg.GetString("splitter.outTemplate"))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't init splitter")
+	}
+	lexicons, err := mongo.NewLexicon(sp)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't init mongo lexicon store")
+	}
+	sw.SetLexicons(lexicons)
+	sw.SetAbbreviations(cfg.GetStringSlice("splitter.abbreviations"))
+	if err := sw.SetPauses(splitter.Pauses{Paragraph: cfg.GetDuration("splitter.paragraphPause"),
+		Heading: cfg.GetDuration("splitter.headingPause"), ListItem: cfg.GetDuration("splitter.listPause")}); err != nil {
+		return nil, errors.Wrap(err, "can't init splitter pauses")
+	}
+	return sw, nil
+}
+
+func newSynthesizer(cfg *viper.Viper, st storage.Storage) (synthesize.Worker, error) {
+	synth, err := synthesizer.NewWorker(st, cfg.GetString("splitter.outTemplate"),
+		cfg.GetString("synthesizer.outTemplate"),
+		cfg.GetString("synthesizer.URL"),
+		cfg.GetInt("synthesizer.workers"))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't init synthesizer")
+	}
+	cfg.SetDefault("synthesizer.defaultPriority", upload.DefaultPriority)
+	if err := synth.SetDefaultPriority(cfg.GetInt("synthesizer.defaultPriority")); err != nil {
+		return nil, errors.Wrap(err, "can't init synthesizer priority")
+	}
+	if dir := cfg.GetString("cache.dir"); dir != "" {
+		cfg.SetDefault("cache.maxSize", "1GB")
+		cache, err := synthesizer.NewCache(dir, int64(cfg.GetSizeInBytes("cache.maxSize")),
+			cfg.GetDuration("cache.ttl"), cfg.GetString("cache.version"))
+		if err != nil {
+			return nil, errors.Wrap(err, "can't init cache")
+		}
+		synth.SetCache(cache)
+	}
+	return synth, nil
+}
+
+func newJoiner(cfg *viper.Viper, st storage.Storage) (synthesize.Worker, error) {
+	jw, err := joiner.NewWorker(st, cfg.GetString("synthesizer.outTemplate"),
+		cfg.GetString("joiner.outTemplate"),
+		cfg.GetString("joiner.workTemplate"),
+		cfg.GetStringSlice("joiner.metadata"))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't init joiner")
+	}
+	err = jw.SetSplitPath(cfg.GetString("splitter.outTemplate"), cfg.GetBool("joiner.subtitles"))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't init joiner split dir")
+	}
+	loudness, err := joiner.ParseLoudness(cfg.GetString("joiner.loudness"))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't init joiner loudness")
+	}
+	err = jw.SetProcessing(joiner.Processing{Loudness: loudness, Gap: cfg.GetDuration("joiner.gap")})
+	if err != nil {
+		return nil, errors.Wrap(err, "can't init joiner processing")
+	}
+	if path := cfg.GetString("joiner.assetTemplate"); path != "" {
+		cfg.SetDefault("joiner.musicVolume", 0.2)
+		if err := jw.SetAssets(path, cfg.GetFloat64("joiner.musicVolume")); err != nil {
+			return nil, errors.Wrap(err, "can't init joiner assets")
+		}
+	}
+	return jw, nil
+}
+
+func initQueues(prv *rabbit.ChannelProvider, stages []*synthesize.Stage) error {
 	goapp.Log.Info("Initializing queues")
-	for _, n := range [...]string{messages.Split, messages.Synthesize, messages.Upload, messages.Join,
-		messages.Inform, messages.Fail} {
+	names := []string{messages.Upload, messages.Inform, messages.Fail}
+	for _, s := range stages {
+		names = append(names, s.Queue)
+	}
+	for _, n := range names {
 		err := prv.RunOnChannelWithRetry(func(ch *amqp.Channel) error {
 			_, err := rabbit.DeclareQueue(ch, prv.QueueName(n))
 			return err
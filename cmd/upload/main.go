@@ -1,11 +1,17 @@
 package main
 
 import (
-	"github.com/airenas/async-api/pkg/file"
+	"context"
+	"time"
+
 	mng "github.com/airenas/async-api/pkg/mongo"
 	"github.com/airenas/async-api/pkg/rabbit"
+	"github.com/airenas/big-tts/internal/pkg/extract"
 	"github.com/airenas/big-tts/internal/pkg/messages"
 	"github.com/airenas/big-tts/internal/pkg/mongo"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/airenas/big-tts/internal/pkg/synthesizer"
 	"github.com/airenas/big-tts/internal/pkg/upload"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/labstack/gommon/color"
@@ -27,11 +33,56 @@ func main() {
 	if err != nil {
 		goapp.Log.Fatal(errors.Wrap(err, "can't init configuration"))
 	}
+	cfg.SetDefault("synthesis.priority.default", upload.DefaultPriority)
+	err = data.Configurator.InitPriority(cfg.GetInt("synthesis.priority.default"),
+		cfg.GetStringSlice("synthesis.priority.ranges"))
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init priority"))
+	}
+	if url := cfg.GetString("voices.url"); url != "" {
+		cfg.SetDefault("voices.timeout", 10*time.Second)
+		cfg.SetDefault("voices.refresh", 10*time.Minute)
+		if cfg.GetDuration("voices.refresh") <= 0 {
+			goapp.Log.Fatal(errors.Errorf("wrong voices refresh interval %s", cfg.GetDuration("voices.refresh")))
+		}
+		vp, err := upload.NewHTTPVoiceProvider(url, cfg.GetDuration("voices.timeout"))
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "can't init voices provider"))
+		}
+		if err := data.Configurator.Voices().Refresh(vp); err != nil {
+			goapp.Log.Warn(errors.Wrap(err, "can't refresh voices, using config"))
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		data.Configurator.Voices().StartRefresh(ctx, vp, cfg.GetDuration("voices.refresh"))
+	}
+
+	data.Extractor, err = extract.NewExtractor(cfg.GetDuration("extract.paragraphPause"),
+		cfg.GetDuration("extract.headingPause"), cfg.GetBool("extract.chapters"))
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init text extractor"))
+	}
+
+	estimator := splitter.NewEstimator()
+	estimator.SetAbbreviations(cfg.GetStringSlice("splitter.abbreviations"))
+	if err := estimator.SetPauses(splitter.Pauses{Paragraph: cfg.GetDuration("splitter.paragraphPause"),
+		Heading: cfg.GetDuration("splitter.headingPause"), ListItem: cfg.GetDuration("splitter.listPause")}); err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init estimator pauses"))
+	}
+	data.Estimator = estimator
+	data.SpeechRate, err = upload.NewSpeechRate(cfg.GetFloat64("estimate.charsPerSecond"),
+		cfg.GetStringSlice("estimate.voices"))
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init speech rate"))
+	}
 
-	data.Saver, err = file.NewLocalSaver(cfg.GetString("fileStorage.path"))
+	fileStorage, err := storage.NewFromConfig(cfg, cfg.GetString("fileStorage.path"))
 	if err != nil {
-		goapp.Log.Fatal(errors.Wrap(err, "can't init file saver"))
+		goapp.Log.Fatal(errors.Wrap(err, "can't init file storage"))
 	}
+	data.Saver = fileStorage
+	data.Loader = fileStorage
+	data.Remover = fileStorage
 
 	mongoSessionProvider, err := mng.NewSessionProvider(cfg.GetString("mongo.url"), mongo.GetIndexes(), "tts")
 	if err != nil {
@@ -39,10 +90,58 @@ func main() {
 	}
 	defer mongoSessionProvider.Close()
 
-	data.ReqSaver, err = mongo.NewRequest(mongoSessionProvider)
+	requestStore, err := mongo.NewRequest(mongoSessionProvider)
 	if err != nil {
 		goapp.Log.Fatal(errors.Wrap(err, "can't init mongo request saver"))
 	}
+	data.ReqSaver = requestStore
+	data.RequestStore = requestStore
+
+	data.SessionStore, err = mongo.NewUploadSession(mongoSessionProvider)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init mongo upload session store"))
+	}
+
+	statusStore, err := mongo.NewStatus(mongoSessionProvider)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init mongo status store"))
+	}
+	data.Canceler = statusStore
+	data.StatusStore = statusStore
+
+	workStorage, err := storage.NewFromConfig(cfg, "")
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init work storage"))
+	}
+	data.Progress, err = synthesizer.NewProgress(workStorage, cfg.GetString("retry.splitTemplate"),
+		cfg.GetString("retry.audioTemplate"))
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init progress provider"))
+	}
+	cfg.SetDefault("retry.firstStage", "Split")
+	data.FirstStage = cfg.GetString("retry.firstStage")
+
+	data.KeyWindow = cfg.GetDuration("idempotency.window")
+	data.KeyFromContent = cfg.GetBool("idempotency.contentHash")
+	data.KeyStore, err = mongo.NewIdempotency(mongoSessionProvider)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init mongo idempotency store"))
+	}
+	goapp.Log.Infof("Idempotency window: %s, content hash: %t", data.KeyWindow, data.KeyFromContent)
+	data.LexiconStore, err = mongo.NewLexicon(mongoSessionProvider)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init mongo lexicon store"))
+	}
+	data.AssetStore, err = mongo.NewAsset(mongoSessionProvider)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init mongo asset store"))
+	}
+	assetStorage, err := storage.NewFromConfig(cfg, cfg.GetString("assets.path"))
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "can't init asset storage"))
+	}
+	data.AssetSaver = assetStorage
+	data.AssetRemover = assetStorage
 
 	msgChannelProvider, err := rabbit.NewChannelProvider(cfg.GetString("messageServer.url"),
 		cfg.GetString("messageServer.user"), cfg.GetString("messageServer.pass"))
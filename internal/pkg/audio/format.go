@@ -0,0 +1,120 @@
+package audio
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Format describes an output audio format
+type Format struct {
+	// Name is the format name and the file extension
+	Name        string
+	ContentType string
+	// Codec is the ffmpeg audio encoder
+	Codec string
+	// Native formats are produced by the TTS backend
+	Native bool
+	// Lossless formats do not accept bitrate
+	Lossless bool
+	// SampleRates lists allowed sample rates, empty - any from the common list
+	SampleRates []int
+}
+
+// SourceFormat is requested from the TTS backend for the formats that are not native
+const SourceFormat = "m4a"
+
+var (
+	formats = map[string]*Format{
+		"mp3":  {Name: "mp3", ContentType: "audio/mpeg", Codec: "libmp3lame", Native: true},
+		"m4a":  {Name: "m4a", ContentType: "audio/mp4", Codec: "aac", Native: true},
+		"wav":  {Name: "wav", ContentType: "audio/wav", Codec: "pcm_s16le", Lossless: true},
+		"flac": {Name: "flac", ContentType: "audio/flac", Codec: "flac", Lossless: true},
+		"ogg": {Name: "ogg", ContentType: "audio/ogg", Codec: "libopus",
+			SampleRates: []int{8000, 12000, 16000, 24000, 48000}},
+	}
+	sampleRates = []int{8000, 11025, 16000, 22050, 24000, 32000, 44100, 48000}
+)
+
+const (
+	minBitrate = 8
+	maxBitrate = 320
+)
+
+// Get returns format by name
+func Get(name string) (*Format, bool) {
+	res, ok := formats[name]
+	return res, ok
+}
+
+// ContentType returns http content type by the file extension, empty if unknown
+func ContentType(ext string) string {
+	if f, ok := formats[strings.TrimPrefix(strings.ToLower(ext), ".")]; ok {
+		return f.ContentType
+	}
+	return ""
+}
+
+// PartFormat returns the format requested from the TTS backend for the output format
+func PartFormat(name string) string {
+	if f, ok := formats[name]; ok && !f.Native {
+		return SourceFormat
+	}
+	return name
+}
+
+// ValidateBitrate checks bitrate in kbps for the format, 0 - means default
+func (f *Format) ValidateBitrate(v int) error {
+	if v == 0 {
+		return nil
+	}
+	if f.Lossless {
+		return errors.Errorf("bitrate is not supported for %s", f.Name)
+	}
+	if v < minBitrate || v > maxBitrate {
+		return errors.Errorf("bitrate (%d) must be in [%d,%d]", v, minBitrate, maxBitrate)
+	}
+	return nil
+}
+
+// ValidateSampleRate checks sample rate in Hz for the format, 0 - means default
+func (f *Format) ValidateSampleRate(v int) error {
+	if v == 0 {
+		return nil
+	}
+	allowed := f.SampleRates
+	if len(allowed) == 0 {
+		allowed = sampleRates
+	}
+	for _, r := range allowed {
+		if r == v {
+			return nil
+		}
+	}
+	return errors.Errorf("sample rate (%d) is not supported for %s, allowed %s", v, f.Name, toString(allowed))
+}
+
+// EncodeParams returns ffmpeg output params. Returns nil if the input in format from
+// can be copied without encoding
+func (f *Format) EncodeParams(from string, bitrate, sampleRate int) []string {
+	if from == f.Name && bitrate == 0 && sampleRate == 0 {
+		return nil
+	}
+	res := []string{"-c:a", f.Codec}
+	if bitrate > 0 {
+		res = append(res, "-b:a", strconv.Itoa(bitrate)+"k")
+	}
+	if sampleRate > 0 {
+		res = append(res, "-ar", strconv.Itoa(sampleRate))
+	}
+	return res
+}
+
+func toString(v []int) string {
+	res := make([]string, len(v))
+	for i, r := range v {
+		res[i] = strconv.Itoa(r)
+	}
+	return strings.Join(res, ",")
+}
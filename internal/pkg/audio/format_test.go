@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	for _, n := range []string{"mp3", "m4a", "wav", "ogg", "flac"} {
+		f, ok := Get(n)
+		assert.True(t, ok, n)
+		assert.Equal(t, n, f.Name)
+	}
+	_, ok := Get("aac")
+	assert.False(t, ok)
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "audio/mpeg", ContentType(".mp3"))
+	assert.Equal(t, "audio/mp4", ContentType("m4a"))
+	assert.Equal(t, "audio/ogg", ContentType(".OGG"))
+	assert.Equal(t, "", ContentType(".txt"))
+}
+
+func TestPartFormat(t *testing.T) {
+	assert.Equal(t, "mp3", PartFormat("mp3"))
+	assert.Equal(t, "m4a", PartFormat("m4a"))
+	assert.Equal(t, SourceFormat, PartFormat("wav"))
+	assert.Equal(t, SourceFormat, PartFormat("flac"))
+	assert.Equal(t, SourceFormat, PartFormat("ogg"))
+}
+
+func TestFormat_ValidateBitrate(t *testing.T) {
+	mp3, _ := Get("mp3")
+	flac, _ := Get("flac")
+	assert.Nil(t, mp3.ValidateBitrate(0))
+	assert.Nil(t, mp3.ValidateBitrate(128))
+	assert.NotNil(t, mp3.ValidateBitrate(7))
+	assert.NotNil(t, mp3.ValidateBitrate(321))
+	assert.Nil(t, flac.ValidateBitrate(0))
+	assert.NotNil(t, flac.ValidateBitrate(128))
+}
+
+func TestFormat_ValidateSampleRate(t *testing.T) {
+	wav, _ := Get("wav")
+	ogg, _ := Get("ogg")
+	assert.Nil(t, wav.ValidateSampleRate(0))
+	assert.Nil(t, wav.ValidateSampleRate(8000))
+	assert.Nil(t, wav.ValidateSampleRate(44100))
+	assert.NotNil(t, wav.ValidateSampleRate(8001))
+	assert.Nil(t, ogg.ValidateSampleRate(48000))
+	assert.NotNil(t, ogg.ValidateSampleRate(44100))
+}
+
+func TestFormat_EncodeParams(t *testing.T) {
+	mp3, _ := Get("mp3")
+	wav, _ := Get("wav")
+	assert.Nil(t, mp3.EncodeParams("mp3", 0, 0))
+	assert.Equal(t, []string{"-c:a", "libmp3lame", "-b:a", "64k"}, mp3.EncodeParams("mp3", 64, 0))
+	assert.Equal(t, []string{"-c:a", "pcm_s16le", "-ar", "8000"}, wav.EncodeParams("m4a", 0, 8000))
+	assert.Equal(t, []string{"-c:a", "pcm_s16le"}, wav.EncodeParams("m4a", 0, 0))
+}
@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+func fromDOCX(data []byte) ([]block, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readZipFile(zr, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	return parseDOCXDocument(doc)
+}
+
+func parseDOCXDocument(data []byte) ([]block, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	c := &collector{}
+	inText := false
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "can't parse document.xml")
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "p":
+				c.flush()
+			case "pStyle":
+				c.heading = isDOCXHeading(getAttr(tt, "val"))
+			case "outlineLvl":
+				c.heading = true
+			case "t":
+				inText = true
+			case "tab", "br", "cr":
+				c.write(" ")
+			}
+		case xml.EndElement:
+			switch tt.Name.Local {
+			case "p":
+				c.flush()
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				c.write(string(tt))
+			}
+		}
+	}
+	c.flush()
+	return c.res, nil
+}
+
+func isDOCXHeading(style string) bool {
+	st := strings.ToLower(style)
+	return strings.HasPrefix(st, "heading") || st == "title" || st == "subtitle"
+}
+
+func getAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	epubContainer struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+
+	epubPackage struct {
+		Items []struct {
+			ID        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		ItemRefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+)
+
+func fromEPUB(data []byte) ([]block, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	files, err := getEPUBSpine(zr)
+	if err != nil {
+		return nil, err
+	}
+	c := &collector{}
+	for _, f := range files {
+		fd, err := readZipFile(zr, f)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseHTML(fd, c); err != nil {
+			return nil, errors.Wrapf(err, "can't parse %s", f)
+		}
+	}
+	return c.res, nil
+}
+
+func getEPUBSpine(zr *zip.Reader) ([]string, error) {
+	cd, err := readZipFile(zr, "META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	var container epubContainer
+	if err := xml.NewDecoder(bytes.NewReader(cd)).Decode(&container); err != nil {
+		return nil, errors.Wrap(err, "can't parse container.xml")
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].FullPath == "" {
+		return nil, errors.New("no rootfile in container.xml")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	od, err := readZipFile(zr, opfPath)
+	if err != nil {
+		return nil, err
+	}
+	var pkg epubPackage
+	if err := xml.NewDecoder(bytes.NewReader(od)).Decode(&pkg); err != nil {
+		return nil, errors.Wrapf(err, "can't parse %s", opfPath)
+	}
+	items := map[string]string{}
+	for _, it := range pkg.Items {
+		items[it.ID] = it.Href
+	}
+	dir := path.Dir(opfPath)
+	var res []string
+	for _, ir := range pkg.ItemRefs {
+		href, ok := items[ir.IDRef]
+		if !ok {
+			return nil, errors.Errorf("no manifest item '%s'", ir.IDRef)
+		}
+		if uh, err := url.PathUnescape(href); err == nil {
+			href = uh
+		}
+		res = append(res, path.Join(dir, href))
+	}
+	if len(res) == 0 {
+		return nil, errors.New("empty spine")
+	}
+	return res, nil
+}
@@ -0,0 +1,146 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+)
+
+// Extractor converts uploaded documents into SSML the splitter can process
+type Extractor struct {
+	paragraphPause time.Duration
+	headingPause   time.Duration
+	// chapters marks headings as chapters with <mark/>
+	chapters bool
+}
+
+type block struct {
+	text    string
+	heading bool
+}
+
+type extractFunc func([]byte) ([]block, error)
+
+var extractors = map[string]extractFunc{
+	".docx":     fromDOCX,
+	".odt":      fromODT,
+	".html":     fromHTML,
+	".htm":      fromHTML,
+	".xhtml":    fromHTML,
+	".epub":     fromEPUB,
+	".md":       fromMarkdown,
+	".markdown": fromMarkdown,
+}
+
+var textTypes = map[string]bool{".html": true, ".htm": true, ".xhtml": true, ".md": true, ".markdown": true}
+
+// NewExtractor creates extractor instance
+func NewExtractor(paragraphPause, headingPause time.Duration, chapters bool) (*Extractor, error) {
+	if paragraphPause < 0 {
+		return nil, errors.Errorf("wrong paragraph pause %s", paragraphPause)
+	}
+	if headingPause < 0 {
+		return nil, errors.Errorf("wrong heading pause %s", headingPause)
+	}
+	goapp.Log.Infof("Extractor pauses: paragraph %s, heading %s, chapters: %t", paragraphPause, headingPause, chapters)
+	return &Extractor{paragraphPause: paragraphPause, headingPause: headingPause, chapters: chapters}, nil
+}
+
+// Supports returns true if the file extension can be converted
+func Supports(ext string) bool {
+	_, ok := extractors[strings.ToLower(ext)]
+	return ok
+}
+
+// IsText returns true if the file type is a text markup, not a binary document
+func IsText(ext string) bool {
+	_, ok := textTypes[strings.ToLower(ext)]
+	return ok
+}
+
+// Extract converts document data to SSML text
+func (e *Extractor) Extract(ext string, data []byte) ([]byte, error) {
+	f, ok := extractors[strings.ToLower(ext)]
+	if !ok {
+		return nil, errors.Errorf("unsupported file type '%s'", ext)
+	}
+	blocks, err := f(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't extract text from %s", ext)
+	}
+	return e.toSSML(blocks)
+}
+
+func (e *Extractor) toSSML(blocks []block) ([]byte, error) {
+	res := &bytes.Buffer{}
+	res.WriteString("<speak>")
+	c := 0
+	for _, b := range blocks {
+		txt := normalizeSpace(b.text)
+		if txt == "" {
+			continue
+		}
+		if c > 0 {
+			res.WriteString(" ")
+		}
+		if b.heading && e.chapters {
+			res.WriteString(`<mark name="`)
+			if err := xml.EscapeText(res, []byte(txt)); err != nil {
+				return nil, err
+			}
+			res.WriteString(`"/>`)
+		}
+		if err := xml.EscapeText(res, []byte(txt)); err != nil {
+			return nil, err
+		}
+		res.WriteString(breakStr(e.pause(b.heading)))
+		c++
+	}
+	if c == 0 {
+		return nil, errors.New("no text found")
+	}
+	res.WriteString("</speak>")
+	return res.Bytes(), nil
+}
+
+func (e *Extractor) pause(heading bool) time.Duration {
+	if heading {
+		return e.headingPause
+	}
+	return e.paragraphPause
+}
+
+func breakStr(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(`<break time="%dms"/>`, d.Milliseconds())
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// collector accumulates text into blocks
+type collector struct {
+	res     []block
+	current strings.Builder
+	heading bool
+}
+
+func (c *collector) write(s string) {
+	c.current.WriteString(s)
+}
+
+func (c *collector) flush() {
+	if txt := normalizeSpace(c.current.String()); txt != "" {
+		c.res = append(c.res, block{text: txt, heading: c.heading})
+	}
+	c.current.Reset()
+	c.heading = false
+}
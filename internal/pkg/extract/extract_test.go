@@ -0,0 +1,128 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExtractor(t *testing.T) {
+	got, err := NewExtractor(time.Second, 2*time.Second, false)
+	assert.Nil(t, err)
+	assert.NotNil(t, got)
+	_, err = NewExtractor(-time.Second, time.Second, false)
+	assert.NotNil(t, err)
+	_, err = NewExtractor(time.Second, -time.Second, false)
+	assert.NotNil(t, err)
+}
+
+func TestSupports(t *testing.T) {
+	tests := []struct {
+		args string
+		want bool
+	}{
+		{args: ".docx", want: true},
+		{args: ".DOCX", want: true},
+		{args: ".odt", want: true},
+		{args: ".html", want: true},
+		{args: ".epub", want: true},
+		{args: ".md", want: true},
+		{args: ".txt", want: false},
+		{args: ".doc", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			assert.Equal(t, tt.want, Supports(tt.args))
+		})
+	}
+}
+
+func TestIsText(t *testing.T) {
+	assert.True(t, IsText(".html"))
+	assert.True(t, IsText(".MD"))
+	assert.False(t, IsText(".docx"))
+	assert.False(t, IsText(".epub"))
+}
+
+func TestExtractor_Extract(t *testing.T) {
+	e, _ := NewExtractor(500*time.Millisecond, time.Second, false)
+	tests := []struct {
+		name    string
+		ext     string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "html", ext: ".html", data: []byte(`<html><head><title>t</title></head><body>` +
+			`<h1>Head</h1><p>Olia <b>olia</b></p><script>x</script><p>a &amp; b</p></body></html>`),
+			want: `<speak>Head<break time="1000ms"/> Olia olia<break time="500ms"/> a &amp; b<break time="500ms"/></speak>`},
+		{name: "markdown", ext: ".md", data: []byte("# Head\n\nOlia **olia**\nline [link](http://a)\n\n- item1\n- item2\n```\ncode\n```\n"),
+			want: `<speak>Head<break time="1000ms"/> Olia olia line link<break time="500ms"/> item1<break time="500ms"/> item2<break time="500ms"/></speak>`},
+		{name: "docx", ext: ".docx", data: makeZip(t, map[string]string{"word/document.xml": `<w:document xmlns:w="w"><w:body>` +
+			`<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Head</w:t></w:r></w:p>` +
+			`<w:p><w:r><w:t>Olia</w:t><w:tab/><w:t>olia</w:t></w:r></w:p></w:body></w:document>`}),
+			want: `<speak>Head<break time="1000ms"/> Olia olia<break time="500ms"/></speak>`},
+		{name: "odt", ext: ".odt", data: makeZip(t, map[string]string{"content.xml": `<office:document-content xmlns:office="o" xmlns:text="t">` +
+			`<office:body><office:text><text:h>Head</text:h><text:p>Olia<text:s/><text:span>olia</text:span></text:p>` +
+			`</office:text></office:body></office:document-content>`}),
+			want: `<speak>Head<break time="1000ms"/> Olia olia<break time="500ms"/></speak>`},
+		{name: "epub", ext: ".epub", data: makeZip(t, map[string]string{
+			"META-INF/container.xml": `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+			"OEBPS/content.opf": `<package><manifest><item id="c1" href="c1.xhtml"/><item id="c2" href="text/c%202.xhtml"/></manifest>` +
+				`<spine><itemref idref="c1"/><itemref idref="c2"/></spine></package>`,
+			"OEBPS/c1.xhtml":       `<html><body><h2>Head</h2></body></html>`,
+			"OEBPS/text/c 2.xhtml": `<html><body><p>Olia</p></body></html>`}),
+			want: `<speak>Head<break time="1000ms"/> Olia<break time="500ms"/></speak>`},
+		{name: "markdown literals", ext: ".md", data: []byte("#tag #1 olia\n\n5 * 3 * 2 and *a* `b` ~~c~~ __d__\n"),
+			want: `<speak>#tag #1 olia<break time="500ms"/> 5 * 3 * 2 and a b c d<break time="500ms"/></speak>`},
+		{name: "empty", ext: ".html", data: []byte(`<html><body> </body></html>`), wantErr: true},
+		{name: "no zip", ext: ".docx", data: []byte(`olia`), wantErr: true},
+		{name: "no document", ext: ".docx", data: makeZip(t, map[string]string{"a.xml": "<a/>"}), wantErr: true},
+		{name: "wrong spine", ext: ".epub", data: makeZip(t, map[string]string{
+			"META-INF/container.xml": `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`,
+			"content.opf":            `<package><spine><itemref idref="c1"/></spine></package>`}), wantErr: true},
+		{name: "unsupported", ext: ".doc", data: []byte(`olia`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Extract(tt.ext, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Extractor.Extract() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestExtractor_Extract_NoPause(t *testing.T) {
+	e, _ := NewExtractor(0, 0, false)
+	got, err := e.Extract(".html", []byte(`<p>olia</p><p>olia2</p>`))
+	assert.Nil(t, err)
+	assert.Equal(t, `<speak>olia olia2</speak>`, string(got))
+}
+
+func TestExtractor_Extract_Chapters(t *testing.T) {
+	e, _ := NewExtractor(0, 0, true)
+	got, err := e.Extract(".md", []byte("# Head \"1\"\n\nolia\n\n## Head2\n\nolia2\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, `<speak><mark name="Head &#34;1&#34;"/>Head &#34;1&#34; olia <mark name="Head2"/>Head2 olia2</speak>`, string(got))
+}
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	zw := zip.NewWriter(b)
+	for k, v := range files {
+		w, err := zw.Create(k)
+		require.Nil(t, err)
+		_, err = w.Write([]byte(v))
+		require.Nil(t, err)
+	}
+	require.Nil(t, zw.Close())
+	return b.Bytes()
+}
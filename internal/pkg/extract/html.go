@@ -0,0 +1,82 @@
+package extract
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var skipTags = map[atom.Atom]bool{atom.Script: true, atom.Style: true, atom.Head: true,
+	atom.Title: true, atom.Noscript: true, atom.Template: true, atom.Svg: true, atom.Math: true}
+
+var headingTags = map[atom.Atom]bool{atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true}
+
+var blockTags = map[atom.Atom]bool{atom.P: true, atom.Div: true, atom.Li: true, atom.Ul: true,
+	atom.Ol: true, atom.Dd: true, atom.Dt: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Section: true, atom.Article: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Table: true, atom.Tr: true, atom.Td: true, atom.Th: true, atom.Figcaption: true,
+	atom.Body: true, atom.Hr: true}
+
+func fromHTML(data []byte) ([]block, error) {
+	c := &collector{}
+	if err := parseHTML(data, c); err != nil {
+		return nil, err
+	}
+	return c.res, nil
+}
+
+func parseHTML(data []byte, c *collector) error {
+	z := html.NewTokenizer(bytes.NewReader(data))
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				c.flush()
+				return nil
+			}
+			return errors.Wrap(z.Err(), "can't parse html")
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tn, _ := z.TagName()
+			a := atom.Lookup(tn)
+			if skipTags[a] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if headingTags[a] {
+				c.flush()
+				c.heading = true
+			} else if blockTags[a] {
+				c.flush()
+			} else if a == atom.Br {
+				c.write(" ")
+			}
+		case html.EndTagToken:
+			tn, _ := z.TagName()
+			a := atom.Lookup(tn)
+			if skipTags[a] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip == 0 && (headingTags[a] || blockTags[a]) {
+				c.flush()
+			}
+		case html.TextToken:
+			if skip == 0 {
+				c.write(string(z.Text()))
+			}
+		}
+	}
+}
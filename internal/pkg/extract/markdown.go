@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdImageRegexp  = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRegexp   = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdListRegexp   = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	mdRuleRegexp   = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+	mdSetextRegexp = regexp.MustCompile(`^\s*(=+|-+)\s*$`)
+	mdHeadRegexp   = regexp.MustCompile(`^#{1,6}(\s|$)`)
+	// mdEmphasis strips the matched emphasis pairs only, the order matters: ** before *
+	mdEmphasis = []*regexp.Regexp{
+		regexp.MustCompile("`([^`]+)`"),
+		regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`),
+		regexp.MustCompile(`__(\S(?:.*?\S)?)__`),
+		regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`),
+		regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`),
+	}
+)
+
+func fromMarkdown(data []byte) ([]block, error) {
+	c := &collector{}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	inCode := false
+	for i, l := range lines {
+		tl := strings.TrimSpace(l)
+		if strings.HasPrefix(tl, "```") || strings.HasPrefix(tl, "~~~") {
+			c.flush()
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if tl == "" {
+			c.flush()
+			continue
+		}
+		if mdHeadRegexp.MatchString(tl) {
+			c.flush()
+			c.heading = true
+			c.write(mdInline(strings.Trim(tl, "# \t")))
+			c.flush()
+			continue
+		}
+		if i+1 < len(lines) && mdSetextRegexp.MatchString(lines[i+1]) && c.current.Len() == 0 {
+			c.heading = true
+			c.write(mdInline(tl))
+			c.flush()
+			continue
+		}
+		if mdSetextRegexp.MatchString(l) || mdRuleRegexp.MatchString(l) {
+			c.flush()
+			continue
+		}
+		tl = strings.TrimSpace(strings.TrimLeft(tl, ">"))
+		if mdListRegexp.MatchString(tl) {
+			c.flush()
+			tl = mdListRegexp.ReplaceAllString(tl, "")
+		}
+		c.write(" ")
+		c.write(mdInline(tl))
+	}
+	c.flush()
+	return c.res, nil
+}
+
+func mdInline(s string) string {
+	s = mdImageRegexp.ReplaceAllString(s, "$1")
+	s = mdLinkRegexp.ReplaceAllString(s, "$1")
+	for _, r := range mdEmphasis {
+		s = r.ReplaceAllString(s, "$1")
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+func fromODT(data []byte) ([]block, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readZipFile(zr, "content.xml")
+	if err != nil {
+		return nil, err
+	}
+	return parseODTContent(doc)
+}
+
+func parseODTContent(data []byte) ([]block, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	c := &collector{}
+	depth, skip := 0, 0
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "can't parse content.xml")
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch tt.Name.Local {
+			case "annotation", "note-citation", "tracked-changes":
+				skip = 1
+			case "h", "p":
+				if depth == 0 {
+					c.flush()
+					c.heading = tt.Name.Local == "h"
+				}
+				depth++
+			case "s", "tab", "line-break":
+				c.write(" ")
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if tt.Name.Local == "h" || tt.Name.Local == "p" {
+				depth--
+				if depth == 0 {
+					c.flush()
+				}
+			}
+		case xml.CharData:
+			if depth > 0 && skip == 0 {
+				c.write(string(tt))
+			}
+		}
+	}
+	c.flush()
+	return c.res, nil
+}
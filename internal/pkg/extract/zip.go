@@ -0,0 +1,42 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+const maxZipEntrySize = 100 * 1024 * 1024
+
+func openZip(data []byte) (*zip.Reader, error) {
+	res, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open zip")
+	}
+	return res, nil
+}
+
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	name = path.Clean(name)
+	for _, f := range zr.File {
+		if path.Clean(f.Name) == name {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, errors.Wrapf(err, "can't open %s", name)
+			}
+			defer rc.Close()
+			res, err := io.ReadAll(io.LimitReader(rc, maxZipEntrySize+1))
+			if err != nil {
+				return nil, errors.Wrapf(err, "can't read %s", name)
+			}
+			if len(res) > maxZipEntrySize {
+				return nil, errors.Errorf("%s is too large", name)
+			}
+			return res, nil
+		}
+	}
+	return nil, errors.Errorf("no %s in archive", name)
+}
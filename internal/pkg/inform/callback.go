@@ -0,0 +1,130 @@
+package inform
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/airenas/big-tts/internal/pkg/utils"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+)
+
+// CallbackData is the JSON posted to the callback URL
+type CallbackData struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+}
+
+// SignatureHeader keeps HMAC-SHA256 signature of the callback body
+const SignatureHeader = "X-Signature"
+
+// HTTPCallbackSender posts signed callbacks with retries
+type HTTPCallbackSender struct {
+	secret     []byte
+	retries    int
+	backoff    time.Duration
+	httpClient *http.Client
+
+	waitFunc func(context.Context, time.Duration) error
+}
+
+// NewHTTPCallbackSender creates callback sender
+func NewHTTPCallbackSender(secret string, retries int, backoff, timeout time.Duration) (*HTTPCallbackSender, error) {
+	if secret == "" {
+		return nil, errors.New("no callback secret")
+	}
+	if retries < 0 {
+		return nil, errors.Errorf("wrong retries %d", retries)
+	}
+	if backoff <= 0 {
+		return nil, errors.Errorf("wrong backoff %s", backoff)
+	}
+	if timeout <= 0 {
+		return nil, errors.Errorf("wrong timeout %s", timeout)
+	}
+	res := &HTTPCallbackSender{secret: []byte(secret), retries: retries, backoff: backoff}
+	dialer := &net.Dialer{Control: checkAddress}
+	res.httpClient = &http.Client{Timeout: timeout, Transport: &http.Transport{DialContext: dialer.DialContext}}
+	res.waitFunc = wait
+	goapp.Log.Infof("Callback retries: %d, backoff: %s, timeout: %s", retries, backoff, timeout)
+	return res, nil
+}
+
+// Send posts the JSON body, retries with exponential backoff on connection or server errors.
+// The retries stop when ctx is canceled
+func (s *HTTPCallbackSender) Send(ctx context.Context, url string, b []byte) error {
+	sign := Sign(s.secret, b)
+	wait := s.backoff
+	for i := 0; ; i++ {
+		retry, err := s.post(ctx, url, b, sign)
+		if err == nil {
+			return nil
+		}
+		if !retry || i >= s.retries {
+			return err
+		}
+		goapp.Log.Warnf("Callback failed, retry after %s: %v", wait, err)
+		if err := s.waitFunc(ctx, wait); err != nil {
+			return errors.Wrap(err, "callback retry canceled")
+		}
+		wait *= 2
+	}
+}
+
+func (s *HTTPCallbackSender) post(ctx context.Context, url string, b []byte, sign string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return false, errors.Wrap(err, "can't prepare request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, sign)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return true, errors.Wrap(err, "can't invoke callback")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 10000))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			errors.Errorf("callback returned code %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// checkAddress rejects the connection to an internal IP, the callback host may resolve to it
+func checkAddress(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrapf(err, "wrong address %s", address)
+	}
+	if utils.IsInternalHost(host) {
+		return errors.Errorf("callback to internal address %s is not allowed", host)
+	}
+	return nil
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// Sign returns 'sha256=<hex HMAC-SHA256>' of the body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,156 @@
+package inform
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPCallbackSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		retries int
+		backoff time.Duration
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "OK", secret: "s", retries: 3, backoff: time.Second, timeout: time.Second, wantErr: false},
+		{name: "No retries", secret: "s", retries: 0, backoff: time.Second, timeout: time.Second, wantErr: false},
+		{name: "No secret", secret: "", retries: 3, backoff: time.Second, timeout: time.Second, wantErr: true},
+		{name: "Retries", secret: "s", retries: -1, backoff: time.Second, timeout: time.Second, wantErr: true},
+		{name: "Backoff", secret: "s", retries: 3, backoff: 0, timeout: time.Second, wantErr: true},
+		{name: "Timeout", secret: "s", retries: 3, backoff: time.Second, timeout: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPCallbackSender(tt.secret, tt.retries, tt.backoff, tt.timeout)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestHTTPCallbackSender_Send(t *testing.T) {
+	var gotBody []byte
+	var gotSign string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSign = r.Header.Get(SignatureHeader)
+	}))
+	defer srv.Close()
+	s := newTestSender(t, 3)
+
+	err := s.Send(context.Background(), srv.URL, []byte(`{"id":"olia"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"olia"}`, string(gotBody))
+	assert.Equal(t, Sign([]byte("secret"), gotBody), gotSign)
+}
+
+func TestHTTPCallbackSender_Send_Retries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	s := newTestSender(t, 3)
+	var waits []time.Duration
+	s.waitFunc = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	assert.Nil(t, s.Send(context.Background(), srv.URL, []byte(`{"id":"olia"}`)))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+}
+
+func TestHTTPCallbackSender_Send_Fails(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	s := newTestSender(t, 2)
+
+	assert.NotNil(t, s.Send(context.Background(), srv.URL, []byte(`{"id":"olia"}`)))
+	assert.Equal(t, 3, calls)
+}
+
+func TestHTTPCallbackSender_Send_NoRetryOnClientError(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	s := newTestSender(t, 2)
+
+	assert.NotNil(t, s.Send(context.Background(), srv.URL, []byte(`{"id":"olia"}`)))
+	assert.Equal(t, 1, calls)
+}
+
+func TestHTTPCallbackSender_Send_Canceled(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	s := newTestSender(t, 2)
+	s.waitFunc = wait
+	ctx, cf := context.WithCancel(context.Background())
+	cf()
+
+	assert.NotNil(t, s.Send(ctx, srv.URL, []byte(`{"id":"olia"}`)))
+	assert.Equal(t, 0, calls)
+}
+
+func TestHTTPCallbackSender_Send_Internal(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	s, err := NewHTTPCallbackSender("secret", 0, time.Second, time.Second)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, s.Send(context.Background(), srv.URL, []byte(`{"id":"olia"}`)))
+	assert.Equal(t, 0, calls)
+}
+
+func Test_checkAddress(t *testing.T) {
+	assert.Nil(t, checkAddress("tcp", "8.8.8.8:80", nil))
+	assert.NotNil(t, checkAddress("tcp", "127.0.0.1:80", nil))
+	assert.NotNil(t, checkAddress("tcp", "[fe80::1]:80", nil))
+	assert.NotNil(t, checkAddress("tcp", "8.8.8.8", nil))
+}
+
+func Test_wait(t *testing.T) {
+	ctx, cf := context.WithCancel(context.Background())
+	assert.Nil(t, wait(ctx, time.Millisecond))
+	cf()
+	assert.NotNil(t, wait(ctx, time.Hour))
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign([]byte("key"), []byte("The quick brown fox jumps over the lazy dog")))
+}
+
+func newTestSender(t *testing.T, retries int) *HTTPCallbackSender {
+	t.Helper()
+	res, err := NewHTTPCallbackSender("secret", retries, time.Second, time.Second)
+	assert.Nil(t, err)
+	res.waitFunc = func(context.Context, time.Duration) error { return nil }
+	res.httpClient = &http.Client{Timeout: time.Second} // test servers listen on the loopback
+	return res
+}
@@ -3,10 +3,12 @@ package inform
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/airenas/async-api/pkg/inform"
 	"github.com/airenas/async-api/pkg/messages"
+	bmessages "github.com/airenas/big-tts/internal/pkg/messages"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/jordan-wright/email"
 	"github.com/pkg/errors"
@@ -23,7 +25,7 @@ type EmailMaker interface {
 	Make(data *inform.Data) (*email.Email, error)
 }
 
-// EmailRetriever return the email by ID
+// EmailRetriever return the email by ID, empty if the job has no email
 type EmailRetriever interface {
 	GetEmail(ID string) (string, error)
 }
@@ -35,6 +37,21 @@ type Locker interface {
 	UnLock(id string, lockKey string, value *int) error
 }
 
+// CallbackRetriever returns the callback URL by ID, empty if there is no callback
+type CallbackRetriever interface {
+	GetCallbackURL(ID string) (string, error)
+}
+
+// CallbackSender posts the callback, ctx cancels the retries
+type CallbackSender interface {
+	Send(ctx context.Context, url string, body []byte) error
+}
+
+// MsgSender forwards the message to the callback queue
+type MsgSender interface {
+	Send(msg messages.Message, queue, replyQueue string) error
+}
+
 // ServiceData keeps data required for service work
 type ServiceData struct {
 	TaskName       string
@@ -44,6 +61,12 @@ type ServiceData struct {
 	EmailRetriever EmailRetriever
 	Locker         Locker
 	Location       *time.Location
+	// Callback fields are optional, callbacks are not sent if not set.
+	// Callbacks are posted from their own queue so the retries do not delay the emails
+	CallbackRetriever CallbackRetriever
+	CallbackSender    CallbackSender
+	CallbackWorkCh    <-chan amqp.Delivery
+	MsgSender         MsgSender
 }
 
 // StartWorkerService starts the event queue listener service to listen for configured events
@@ -59,9 +82,21 @@ func StartWorkerService(ctx context.Context, data *ServiceData) (<-chan struct{}
 		return nil, err
 	}
 
-	ctxInt, cancelF := context.WithCancel(context.Background())
-	go listenQueue(ctx, data.WorkCh, data, cancelF)
-	return ctxInt.Done(), nil
+	// the service stops if any queue is closed
+	ctxInt, cancelF := context.WithCancel(ctx)
+	res := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go listenQueue(ctxInt, data.WorkCh, data, work, cancelF, wg)
+	if data.CallbackSender != nil {
+		wg.Add(1)
+		go listenQueue(ctxInt, data.CallbackWorkCh, data, workCallback, cancelF, wg)
+	}
+	go func() {
+		wg.Wait()
+		close(res)
+	}()
+	return res, nil
 }
 
 func validate(data *ServiceData) error {
@@ -83,13 +118,53 @@ func validate(data *ServiceData) error {
 	if data.WorkCh == nil {
 		return errors.New("no work channel")
 	}
+	if data.CallbackRetriever != nil || data.CallbackSender != nil || data.CallbackWorkCh != nil ||
+		data.MsgSender != nil {
+		if data.CallbackRetriever == nil || data.CallbackSender == nil {
+			return errors.New("no callback retriever or sender")
+		}
+		if data.CallbackWorkCh == nil {
+			return errors.New("no callback work channel")
+		}
+		if data.MsgSender == nil {
+			return errors.New("no msg sender")
+		}
+	}
 	return nil
 }
 
 // work is main method to send the message
-func work(data *ServiceData, message *messages.InformMessage) error {
+func work(ctx context.Context, data *ServiceData, message *messages.InformMessage) error {
 	goapp.Log.Infof("Got task %s for ID: %s", data.TaskName, message.ID)
 
+	if err := forwardCallback(data, message); err != nil {
+		return err
+	}
+	return sendEmail(data, message)
+}
+
+// workCallback posts the callback of the forwarded message
+func workCallback(ctx context.Context, data *ServiceData, message *messages.InformMessage) error {
+	goapp.Log.Infof("Got callback task for ID: %s", message.ID)
+	return sendCallback(ctx, data, message)
+}
+
+// forwardCallback passes the message to the callback queue if the job has a callback URL
+func forwardCallback(data *ServiceData, message *messages.InformMessage) error {
+	if data.CallbackRetriever == nil {
+		return nil
+	}
+	url, err := data.CallbackRetriever.GetCallbackURL(message.ID)
+	if err != nil {
+		return errors.Wrap(err, "can't retrieve callback URL")
+	}
+	if url == "" {
+		return nil
+	}
+	return errors.Wrap(data.MsgSender.Send(message, bmessages.Callback, ""), "can't forward callback")
+}
+
+func sendEmail(data *ServiceData, message *messages.InformMessage) error {
 	mailData := inform.Data{}
 	mailData.ID = message.ID
 	mailData.MsgTime = toLocalTime(data, message.At)
@@ -101,6 +176,10 @@ func work(data *ServiceData, message *messages.InformMessage) error {
 		goapp.Log.Error(err)
 		return errors.Wrap(err, "can't retrieve email")
 	}
+	if mailData.Email == "" {
+		goapp.Log.Infof("No email for %s, skip", message.ID)
+		return nil
+	}
 
 	email, err := data.EmailMaker.Make(&mailData)
 	if err != nil {
@@ -129,7 +208,48 @@ func work(data *ServiceData, message *messages.InformMessage) error {
 	return nil
 }
 
-func listenQueue(ctx context.Context, q <-chan amqp.Delivery, data *ServiceData, cancelF func()) {
+func sendCallback(ctx context.Context, data *ServiceData, message *messages.InformMessage) error {
+	url, err := data.CallbackRetriever.GetCallbackURL(message.ID)
+	if err != nil {
+		return errors.Wrap(err, "can't retrieve callback URL")
+	}
+	if url == "" {
+		return nil
+	}
+	body, err := json.Marshal(&CallbackData{ID: message.ID, Type: message.Type, At: message.At})
+	if err != nil {
+		return errors.Wrap(err, "can't marshal callback")
+	}
+
+	lockKey := callbackLockKey(message.Type)
+	err = data.Locker.Lock(message.ID, lockKey)
+	if err != nil {
+		return errors.Wrap(err, "can't lock callback")
+	}
+	var unlockValue = 0
+	defer func() {
+		if err := data.Locker.UnLock(message.ID, lockKey, &unlockValue); err != nil {
+			goapp.Log.Error(err)
+		}
+	}()
+
+	err = data.CallbackSender.Send(ctx, url, body)
+	if err != nil {
+		return errors.Wrap(err, "can't send callback")
+	}
+	unlockValue = 2
+	return nil
+}
+
+func callbackLockKey(msgType string) string {
+	return "callback" + msgType
+}
+
+type workFunc func(context.Context, *ServiceData, *messages.InformMessage) error
+
+func listenQueue(ctx context.Context, q <-chan amqp.Delivery, data *ServiceData, wf workFunc, cancelF func(),
+	wg *sync.WaitGroup) {
+	defer wg.Done()
 	defer cancelF()
 	for {
 		select {
@@ -142,7 +262,7 @@ func listenQueue(ctx context.Context, q <-chan amqp.Delivery, data *ServiceData,
 					goapp.Log.Infof("Stopped listening queue")
 					return
 				}
-				err := processMsg(ctx, &d, data)
+				err := processMsg(ctx, &d, data, wf)
 				if err != nil {
 					goapp.Log.Error(err)
 				}
@@ -151,13 +271,13 @@ func listenQueue(ctx context.Context, q <-chan amqp.Delivery, data *ServiceData,
 	}
 }
 
-func processMsg(ctx context.Context, d *amqp.Delivery, data *ServiceData) error {
+func processMsg(ctx context.Context, d *amqp.Delivery, data *ServiceData, wf workFunc) error {
 	var message messages.InformMessage
 	if err := json.Unmarshal(d.Body, &message); err != nil {
 		_ = d.Nack(false, false)
 		return errors.Wrap(err, "can't unmarshal message "+string(d.Body))
 	}
-	err := work(data, &message)
+	err := wf(ctx, data, &message)
 	if err != nil {
 		goapp.Log.Errorf("can't process message %s\n%s", d.MessageId, string(d.Body))
 		goapp.Log.Error(err)
@@ -21,11 +21,15 @@ var (
 	tCtx     context.Context
 	tCancelF func()
 	tWrkCh   chan amqp.Delivery
+	tCbWrkCh chan amqp.Delivery
 
 	tSender         *mocks.MockSender
 	tEmailMaker     *mocks.MockEmailMaker
 	tEmailRetriever *mocks.MockEmailRetriever
 	tLocker         *mocks.MockLocker
+	tCbRetriever    *mocks.MockCallbackRetriever
+	tCbSender       *mocks.MockCallbackSender
+	tMsgSender      *mocks.MockMsgSender
 )
 
 func initTest(t *testing.T) {
@@ -35,8 +39,13 @@ func initTest(t *testing.T) {
 	tEmailMaker = mocks.NewMockEmailMaker()
 	tEmailRetriever = mocks.NewMockEmailRetriever()
 	tLocker = mocks.NewMockLocker()
+	tCbRetriever = mocks.NewMockCallbackRetriever()
+	tCbSender = mocks.NewMockCallbackSender()
+	tMsgSender = mocks.NewMockMsgSender()
+	pegomock.When(tEmailRetriever.GetEmail(pegomock.Any[string]())).ThenReturn("olia@olia.lt", nil)
 
 	tWrkCh = make(chan amqp.Delivery)
+	tCbWrkCh = make(chan amqp.Delivery)
 
 	tData = &ServiceData{WorkCh: tWrkCh, TaskName: "olia", Location: time.Local, EmailSender: tSender,
 		EmailMaker: tEmailMaker, EmailRetriever: tEmailRetriever, Locker: tLocker}
@@ -112,6 +121,25 @@ func Test_WorkMsg_FailRetriever(t *testing.T) {
 	tSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[*email.Email]())
 }
 
+func Test_WorkMsg_NoEmail(t *testing.T) {
+	initTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	assert.Nil(t, err)
+
+	msg := amessages.InformMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, At: time.Now(), Type: amessages.InformTypeStarted}
+	msgdata, _ := json.Marshal(msg)
+
+	pegomock.When(tEmailRetriever.GetEmail(pegomock.Any[string]())).ThenReturn("", nil)
+	tWrkCh <- amqp.Delivery{Body: msgdata}
+	close(tWrkCh)
+	waitT(t, ch)
+
+	tEmailRetriever.VerifyWasCalledOnce().GetEmail(pegomock.Any[string]())
+	tEmailMaker.VerifyWasCalled(pegomock.Never()).Make(pegomock.Any[*ainform.Data]())
+	tLocker.VerifyWasCalled(pegomock.Never()).Lock(pegomock.Any[string](), pegomock.Any[string]())
+	tSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[*email.Email]())
+}
+
 func Test_WorkMsg_FailMaker(t *testing.T) {
 	initTest(t)
 	ch, err := StartWorkerService(tCtx, tData)
@@ -175,6 +203,113 @@ func Test_WorkMsg_FailSender(t *testing.T) {
 	tSender.VerifyWasCalledOnce().Send(pegomock.Any[*email.Email]())
 }
 
+func initCallbackTest(t *testing.T) {
+	initTest(t)
+	tData.CallbackRetriever, tData.CallbackSender = tCbRetriever, tCbSender
+	tData.CallbackWorkCh, tData.MsgSender = tCbWrkCh, tMsgSender
+}
+
+func Test_WorkMsg_Callback(t *testing.T) {
+	initCallbackTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	assert.Nil(t, err)
+
+	msg := amessages.InformMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, At: time.Now(), Type: amessages.InformTypeFinished}
+	msgdata, _ := json.Marshal(msg)
+
+	pegomock.When(tCbRetriever.GetCallbackURL(pegomock.Any[string]())).ThenReturn("http://cb", nil)
+	tWrkCh <- amqp.Delivery{Body: msgdata}
+	close(tWrkCh)
+	waitT(t, ch)
+
+	gMsg, gQueue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "olia", gMsg.(*amessages.InformMessage).ID)
+	assert.Equal(t, "BigTTS/Callback", gQueue)
+	tCbSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[context.Context](), pegomock.Any[string](), pegomock.Any[[]byte]())
+	tSender.VerifyWasCalledOnce().Send(pegomock.Any[*email.Email]())
+}
+
+func Test_WorkMsg_CallbackForwardFails(t *testing.T) {
+	initCallbackTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	assert.Nil(t, err)
+
+	msg := amessages.InformMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, At: time.Now(), Type: amessages.InformTypeFinished}
+	msgdata, _ := json.Marshal(msg)
+
+	pegomock.When(tCbRetriever.GetCallbackURL(pegomock.Any[string]())).ThenReturn("http://cb", nil)
+	pegomock.When(tMsgSender.Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]())).ThenReturn(errors.New("err"))
+	tWrkCh <- amqp.Delivery{Body: msgdata}
+	close(tWrkCh)
+	waitT(t, ch)
+
+	tSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[*email.Email]())
+}
+
+func Test_WorkMsg_NoCallback(t *testing.T) {
+	initCallbackTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	assert.Nil(t, err)
+
+	msg := amessages.InformMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, At: time.Now(), Type: amessages.InformTypeFinished}
+	msgdata, _ := json.Marshal(msg)
+
+	tWrkCh <- amqp.Delivery{Body: msgdata}
+	close(tWrkCh)
+	waitT(t, ch)
+
+	tCbRetriever.VerifyWasCalledOnce().GetCallbackURL(pegomock.Any[string]())
+	tMsgSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]())
+	tLocker.VerifyWasCalledOnce().Lock(pegomock.Any[string](), pegomock.Any[string]())
+	tSender.VerifyWasCalledOnce().Send(pegomock.Any[*email.Email]())
+}
+
+func Test_CallbackMsg(t *testing.T) {
+	initCallbackTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	assert.Nil(t, err)
+
+	at := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := amessages.InformMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, At: at, Type: amessages.InformTypeFinished}
+	msgdata, _ := json.Marshal(msg)
+
+	pegomock.When(tCbRetriever.GetCallbackURL(pegomock.Any[string]())).ThenReturn("http://cb", nil)
+	tCbWrkCh <- amqp.Delivery{Body: msgdata}
+	close(tCbWrkCh)
+	waitT(t, ch)
+
+	_, gURL, gData := tCbSender.VerifyWasCalledOnce().Send(pegomock.Any[context.Context](), pegomock.Any[string](),
+		pegomock.Any[[]byte]()).GetCapturedArguments()
+	assert.Equal(t, "http://cb", gURL)
+	assert.Equal(t, `{"id":"olia","type":"Finished","at":"2022-01-02T03:04:05Z"}`, string(gData))
+	gLockID, gLockType := tLocker.VerifyWasCalledOnce().Lock(pegomock.Any[string](), pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "olia", gLockID)
+	assert.Equal(t, "callbackFinished", gLockType)
+	tSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[*email.Email]())
+}
+
+func Test_sendCallback_Fails(t *testing.T) {
+	initCallbackTest(t)
+	msg := &amessages.InformMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, At: time.Now(), Type: amessages.InformTypeFailed}
+
+	pegomock.When(tCbRetriever.GetCallbackURL(pegomock.Any[string]())).ThenReturn("http://cb", nil)
+	pegomock.When(tCbSender.Send(pegomock.Any[context.Context](), pegomock.Any[string](),
+		pegomock.Any[[]byte]())).ThenReturn(errors.New("err"))
+	assert.NotNil(t, sendCallback(tCtx, tData, msg))
+	_, _, gUnlockValue := tLocker.VerifyWasCalledOnce().UnLock(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[*int]()).GetCapturedArguments()
+	assert.Equal(t, 0, *gUnlockValue)
+
+	pegomock.When(tLocker.Lock(pegomock.Any[string](), pegomock.Any[string]())).ThenReturn(errors.New("err"))
+	assert.NotNil(t, sendCallback(tCtx, tData, msg))
+	tCbSender.VerifyWasCalledOnce().Send(pegomock.Any[context.Context](), pegomock.Any[string](), pegomock.Any[[]byte]())
+
+	pegomock.When(tCbRetriever.GetCallbackURL(pegomock.Any[string]())).ThenReturn("", errors.New("err"))
+	assert.NotNil(t, sendCallback(tCtx, tData, msg))
+}
+
 func Test_validate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -189,6 +324,12 @@ func Test_validate(t *testing.T) {
 		{name: "Fail", args: func(sd *ServiceData) { sd.Locker = nil }, wantErr: true},
 		{name: "Fail", args: func(sd *ServiceData) { sd.TaskName = "" }, wantErr: true},
 		{name: "Fail", args: func(sd *ServiceData) { sd.WorkCh = nil }, wantErr: true},
+		{name: "OK, callbacks", args: setCallbacks, wantErr: false},
+		{name: "Fail", args: func(sd *ServiceData) { sd.CallbackRetriever = mocks.NewMockCallbackRetriever() }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { sd.CallbackSender = mocks.NewMockCallbackSender() }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { setCallbacks(sd); sd.CallbackRetriever = nil }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { setCallbacks(sd); sd.CallbackWorkCh = nil }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { setCallbacks(sd); sd.MsgSender = nil }, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -202,3 +343,10 @@ func Test_validate(t *testing.T) {
 		})
 	}
 }
+
+func setCallbacks(sd *ServiceData) {
+	sd.CallbackRetriever = mocks.NewMockCallbackRetriever()
+	sd.CallbackSender = mocks.NewMockCallbackSender()
+	sd.CallbackWorkCh = make(<-chan amqp.Delivery)
+	sd.MsgSender = mocks.NewMockMsgSender()
+}
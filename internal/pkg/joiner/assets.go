@@ -0,0 +1,136 @@
+package joiner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+)
+
+// duckFilter lowers the music while the narration is heard
+const duckFilter = "sidechaincompress=threshold=0.02:ratio=8:attack=20:release=400"
+
+// assets are the local files of the job's intro, outro and music bed
+type assets struct {
+	intro, outro, music string
+}
+
+func (a *assets) enabled() bool {
+	return a.intro != "" || a.outro != "" || a.music != ""
+}
+
+// SetAssets enables intro, outro and music, path is a template of the uploaded asset file.
+// musicVolume is the music bed volume in (0, 1]
+func (w *Worker) SetAssets(path string, musicVolume float64) error {
+	if !strings.Contains(path, "{}") {
+		return errors.Errorf("no ID template in asset path")
+	}
+	if musicVolume <= 0 || musicVolume > 1 {
+		return errors.Errorf("wrong music volume %g, expected (0, 1]", musicVolume)
+	}
+	goapp.Log.Infof("Joiner assets: %s, music volume: %g", path, musicVolume)
+	w.assetPath, w.musicVolume = path, musicVolume
+	return nil
+}
+
+// loadAssets downloads the assets of the message into dir
+func (w *Worker) loadAssets(msg *messages.TTSMessage, dir string) (*assets, error) {
+	res := &assets{}
+	for _, a := range []struct {
+		id  string
+		res *string
+	}{{id: msg.Intro, res: &res.intro}, {id: msg.Outro, res: &res.outro}, {id: msg.Music, res: &res.music}} {
+		if a.id == "" {
+			continue
+		}
+		if w.assetPath == "" {
+			return nil, errors.Errorf("assets are not configured")
+		}
+		fn := strings.ReplaceAll(w.assetPath, "{}", a.id)
+		lf, err := w.downloadFunc(fn, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't download asset %s", fn)
+		}
+		*a.res = lf
+	}
+	return res, nil
+}
+
+// inputs returns ffmpeg input params of the assets, the music is looped.
+// The inputs follow the narration, so the first asset is input 1
+func (a *assets) inputs() []string {
+	var res []string
+	for _, f := range []string{a.intro, a.outro} {
+		if f != "" {
+			res = append(res, "-i", f)
+		}
+	}
+	if a.music != "" {
+		res = append(res, "-stream_loop", "-1", "-i", a.music)
+	}
+	return res
+}
+
+func (a *assets) count() int {
+	res := 0
+	for _, f := range []string{a.intro, a.outro, a.music} {
+		if f != "" {
+			res++
+		}
+	}
+	return res
+}
+
+// graph makes ffmpeg filter graph: the ducked music is mixed under the narration,
+// the intro and outro are concatenated around it, the loudness is normalized last.
+// Returns the graph and the label of the output
+func (a *assets) graph(sampleRate int, musicVolume float64, l *Loudness) (string, string) {
+	format := "aformat=sample_fmts=fltp:channel_layouts=stereo"
+	if sampleRate > 0 {
+		format += ":sample_rates=" + strconv.Itoa(sampleRate)
+	}
+	var steps []string
+	in := 1
+	input := func() string {
+		res := fmt.Sprintf("[%d:a]", in)
+		in++
+		return res
+	}
+	steps = append(steps, "[0:a]"+format+"[narr]")
+	intro, outro := "", ""
+	if a.intro != "" {
+		intro = "[intro]"
+		steps = append(steps, input()+format+intro)
+	}
+	if a.outro != "" {
+		outro = "[outro]"
+		steps = append(steps, input()+format+outro)
+	}
+	res := "[narr]"
+	if a.music != "" {
+		steps = append(steps, fmt.Sprintf("%s%s,volume=%g[music]", input(), format, musicVolume),
+			"[narr]asplit=2[voice][side]",
+			"[music][side]"+duckFilter+"[ducked]",
+			"[voice][ducked]amix=inputs=2:duration=first:normalize=0[mixed]")
+		res = "[mixed]"
+	}
+	if intro != "" || outro != "" {
+		n := 1
+		if intro != "" {
+			n++
+		}
+		if outro != "" {
+			n++
+		}
+		steps = append(steps, fmt.Sprintf("%s%s%sconcat=n=%d:v=0:a=1[joined]", intro, res, outro, n))
+		res = "[joined]"
+	}
+	if l != nil {
+		steps = append(steps, res+l.filter()+"[norm]")
+		res = "[norm]"
+	}
+	return strings.Join(steps, ";"), res
+}
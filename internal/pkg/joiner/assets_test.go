@@ -0,0 +1,111 @@
+package joiner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_SetAssets(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	assert.NotNil(t, got.SetAssets("assets", 0.2))
+	assert.NotNil(t, got.SetAssets("assets/{}", 0))
+	assert.NotNil(t, got.SetAssets("assets/{}", 1.1))
+	assert.Nil(t, got.SetAssets("assets/{}", 0.2))
+	assert.Equal(t, "assets/{}", got.assetPath)
+	assert.Equal(t, 0.2, got.musicVolume)
+}
+
+func TestWorker_Do_Assets(t *testing.T) {
+	got := newTestWorker(t, nil, 2)
+	assert.Nil(t, got.SetAssets("assets/{}", 0.2))
+	assert.Nil(t, got.SetSplitPath("split/{}", false))
+	got.processing = Processing{Loudness: &Loudness{Integrated: -23, TruePeak: -1, Range: 7}}
+	got.sampleRateFunc = func(s string) (int, error) { return 22050, nil }
+	got.durationFunc = func(s string) (time.Duration, error) {
+		if s == "local/assets/i1" {
+			return 3 * time.Second, nil
+		}
+		return time.Second, nil
+	}
+	got.saveFunc = func(s string, b []byte) error {
+		if s == "save/id1/chapters.txt" {
+			assert.Contains(t, string(b), "START=3000\nEND=5000\n")
+		}
+		return nil
+	}
+	got.convertFunc = func(s []string) error {
+		assert.Equal(t, []string{"ffmpeg", "-f", "concat",
+			"-safe", "0",
+			"-i", "save/id1/list.txt",
+			"-i", "local/assets/i1",
+			"-i", "local/assets/o1",
+			"-stream_loop", "-1", "-i", "local/assets/m1",
+			"-i", "save/id1/chapters.txt", "-map_chapters", "4",
+			"-filter_complex", "[0:a]aformat=sample_fmts=fltp:channel_layouts=stereo:sample_rates=22050[narr];" +
+				"[1:a]aformat=sample_fmts=fltp:channel_layouts=stereo:sample_rates=22050[intro];" +
+				"[2:a]aformat=sample_fmts=fltp:channel_layouts=stereo:sample_rates=22050[outro];" +
+				"[3:a]aformat=sample_fmts=fltp:channel_layouts=stereo:sample_rates=22050,volume=0.2[music];" +
+				"[narr]asplit=2[voice][side];" +
+				"[music][side]sidechaincompress=threshold=0.02:ratio=8:attack=20:release=400[ducked];" +
+				"[voice][ducked]amix=inputs=2:duration=first:normalize=0[mixed];" +
+				"[intro][mixed][outro]concat=n=3:v=0:a=1[joined];" +
+				"[joined]loudnorm=I=-23:TP=-1:LRA=7[norm]",
+			"-map", "[norm]",
+			"-c:a", "libmp3lame", "-ar", "22050",
+			"save/id1/result.mp3"}, s)
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"},
+		OutputFormat: "mp3", Intro: "i1", Outro: "o1", Music: "m1"})
+	assert.Nil(t, err)
+}
+
+func TestWorker_Do_AssetsOutro(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	assert.Nil(t, got.SetAssets("assets/{}", 0.2))
+	got.sampleRateFunc = func(s string) (int, error) { return 22050, nil }
+	got.convertFunc = func(s []string) error {
+		assert.Equal(t, []string{"ffmpeg", "-f", "concat",
+			"-safe", "0",
+			"-i", "save/id1/list.txt",
+			"-i", "local/assets/o1",
+			"-filter_complex", "[0:a]aformat=sample_fmts=fltp:channel_layouts=stereo:sample_rates=22050[narr];" +
+				"[1:a]aformat=sample_fmts=fltp:channel_layouts=stereo:sample_rates=22050[outro];" +
+				"[narr][outro]concat=n=2:v=0:a=1[joined]",
+			"-map", "[joined]",
+			"-c:a", "libmp3lame",
+			"save/id1/result.mp3"}, s)
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"},
+		OutputFormat: "mp3", Outro: "o1"})
+	assert.Nil(t, err)
+}
+
+func TestWorker_Do_AssetsNotConfigured(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"},
+		OutputFormat: "mp3", Music: "m1"})
+	assert.NotNil(t, err)
+}
+
+func TestNewWorker_MetadataFail(t *testing.T) {
+	_, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "save/{}/", []string{"title={{.title"})
+	assert.NotNil(t, err)
+}
+
+func TestWorker_getMetadataParams(t *testing.T) {
+	got := newTestWorker(t, []string{"copyright=UAB", " title={{.title}} ", "artist={{.author}}",
+		"comment={{.id}}, {{.voice}}", " "}, 1)
+	res, err := got.getMetadataParams(&messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"},
+		Voice: "astra", Metadata: map[string]string{"title": "Olia"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-metadata", "copyright=UAB", "-metadata", "title=Olia",
+		"-metadata", "comment=id1, astra"}, res)
+}
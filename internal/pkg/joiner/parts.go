@@ -0,0 +1,136 @@
+package joiner
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManifestFile lists the files of the split result, it is saved next to the result
+	ManifestFile = "manifest.json"
+	// SplitByChapter starts a new result file at each chapter
+	SplitByChapter = "chapter"
+)
+
+// Manifest lists the files of the split result
+type Manifest struct {
+	Files []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is a file of the split result, start and duration are in seconds
+type ManifestEntry struct {
+	Name     string  `json:"name"`
+	Title    string  `json:"title,omitempty"`
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+}
+
+// PartName returns the name of the result file, i starts from 1
+func PartName(i int, ext string) string {
+	return fmt.Sprintf("result_%03d.%s", i, ext)
+}
+
+// segment is a time range of the result
+type segment struct {
+	from, to time.Duration
+	title    string
+}
+
+func splitRequested(msg *messages.TTSMessage) bool {
+	return msg.MaxDuration > 0 || msg.SplitBy != ""
+}
+
+// splitResult cuts the joined file into numbered files, saves them with the manifest
+func (w *Worker) splitResult(msg *messages.TTSMessage, wpath, file string, chapters []splitter.Chapter,
+	durations []time.Duration, offset time.Duration) error {
+	total, err := w.durationFunc(file)
+	if err != nil {
+		return errors.Wrapf(err, "can't get duration of %s", file)
+	}
+	segments := makeSegments(chapters, durations, offset, total, msg.SplitBy == SplitByChapter, msg.MaxDuration)
+	m := Manifest{}
+	for i, s := range segments {
+		name := PartName(i+1, msg.OutputFormat)
+		out := filepath.Join(wpath, name)
+		params := []string{"ffmpeg", "-i", file, "-ss", toSeconds(s.from)}
+		if i < len(segments)-1 {
+			params = append(params, "-to", toSeconds(s.to))
+		}
+		params = append(params, "-map", "0:a", "-map_chapters", "-1", "-c", "copy", out)
+		if err := w.convertFunc(params); err != nil {
+			return err
+		}
+		if err := w.upload(msg.ID, name, out); err != nil {
+			return err
+		}
+		m.Files = append(m.Files, ManifestEntry{Name: name, Title: s.title, Start: roundSeconds(s.from),
+			Duration: roundSeconds(s.to - s.from)})
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal manifest")
+	}
+	mFile := filepath.Join(wpath, ManifestFile)
+	if err := w.saveFunc(mFile, data); err != nil {
+		return errors.Wrapf(err, "can't save %s", mFile)
+	}
+	return w.upload(msg.ID, ManifestFile, mFile)
+}
+
+// makeSegments cuts the result at the parts boundaries: at each chapter if byChapter,
+// and before a part making the file longer than maxDuration. A file is longer only if a part is longer.
+// The first file starts at 0 with the intro, the last one ends at total with the outro
+func makeSegments(chapters []splitter.Chapter, durations []time.Duration, offset, total time.Duration,
+	byChapter bool, maxDuration time.Duration) []segment {
+	starts := make([]time.Duration, len(durations)+1)
+	starts[0] = offset
+	for i, d := range durations {
+		starts[i+1] = starts[i] + d
+	}
+	titles := make([]string, len(durations))
+	chapterStart := make([]bool, len(durations))
+	for _, ch := range chapters {
+		if ch.Part >= 0 && ch.Part < len(durations) {
+			titles[ch.Part], chapterStart[ch.Part] = ch.Title, true
+		}
+	}
+	for i := 1; i < len(titles); i++ {
+		if !chapterStart[i] {
+			titles[i] = titles[i-1]
+		}
+	}
+	title := func(part int) string {
+		if part < len(titles) {
+			return titles[part]
+		}
+		return ""
+	}
+	var res []segment
+	from, first := time.Duration(0), 0
+	for i := 1; i < len(durations); i++ {
+		cut := byChapter && chapterStart[i]
+		if maxDuration > 0 && starts[i+1]-from > maxDuration {
+			cut = true
+		}
+		if cut {
+			res = append(res, segment{from: from, to: starts[i], title: title(first)})
+			from, first = starts[i], i
+		}
+	}
+	return append(res, segment{from: from, to: total, title: title(first)})
+}
+
+func toSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func roundSeconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
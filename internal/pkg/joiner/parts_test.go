@@ -0,0 +1,109 @@
+package joiner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_makeSegments(t *testing.T) {
+	sec := time.Second
+	tests := []struct {
+		name        string
+		chapters    []splitter.Chapter
+		durations   []time.Duration
+		offset      time.Duration
+		total       time.Duration
+		byChapter   bool
+		maxDuration time.Duration
+		want        []segment
+	}{
+		{name: "One", durations: []time.Duration{sec, sec}, total: 2 * sec, maxDuration: 10 * sec,
+			want: []segment{{from: 0, to: 2 * sec}}},
+		{name: "No parts", total: 2 * sec, byChapter: true, want: []segment{{from: 0, to: 2 * sec}}},
+		{name: "Max", durations: []time.Duration{sec, sec, sec, sec, sec}, total: 5 * sec, maxDuration: 2 * sec,
+			want: []segment{{from: 0, to: 2 * sec}, {from: 2 * sec, to: 4 * sec}, {from: 4 * sec, to: 5 * sec}}},
+		{name: "Long part", durations: []time.Duration{sec, 3 * sec, sec}, total: 5 * sec, maxDuration: 2 * sec,
+			want: []segment{{from: 0, to: sec}, {from: sec, to: 4 * sec}, {from: 4 * sec, to: 5 * sec}}},
+		{name: "Chapters", chapters: []splitter.Chapter{{Title: "One", Part: 0}, {Title: "Two", Part: 2}},
+			durations: []time.Duration{sec, sec, sec}, offset: sec, total: 5 * sec, byChapter: true,
+			want: []segment{{from: 0, to: 3 * sec, title: "One"}, {from: 3 * sec, to: 5 * sec, title: "Two"}}},
+		{name: "Chapters and max", chapters: []splitter.Chapter{{Title: "One", Part: 1}},
+			durations: []time.Duration{sec, sec, sec, sec}, total: 4 * sec, byChapter: true, maxDuration: 2 * sec,
+			want: []segment{{from: 0, to: sec}, {from: sec, to: 3 * sec, title: "One"},
+				{from: 3 * sec, to: 4 * sec, title: "One"}}},
+		{name: "Chapters not split", chapters: []splitter.Chapter{{Title: "One", Part: 1}},
+			durations: []time.Duration{sec, sec}, total: 2 * sec, maxDuration: time.Minute,
+			want: []segment{{from: 0, to: 2 * sec}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, makeSegments(tt.chapters, tt.durations, tt.offset, tt.total, tt.byChapter,
+				tt.maxDuration))
+		})
+	}
+}
+
+func TestPartName(t *testing.T) {
+	assert.Equal(t, "result_001.mp3", PartName(1, "mp3"))
+	assert.Equal(t, "result_012.m4a", PartName(12, "m4a"))
+}
+
+func TestWorker_Do_Split(t *testing.T) {
+	got := newTestWorker(t, nil, 3)
+	got.durationFunc = func(s string) (time.Duration, error) {
+		if s == "save/id1/result.mp3" {
+			return 3500 * time.Millisecond, nil
+		}
+		return time.Second, nil
+	}
+	var cmds [][]string
+	got.convertFunc = func(s []string) error {
+		cmds = append(cmds, s)
+		return nil
+	}
+	var uploaded []string
+	got.uploadFunc = func(s, f string) error {
+		uploaded = append(uploaded, s)
+		return nil
+	}
+	manifest := ""
+	got.saveFunc = func(s string, b []byte) error {
+		if s == "save/id1/manifest.json" {
+			manifest = string(b)
+		}
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"},
+		OutputFormat: "mp3", MaxDuration: 2 * time.Second})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(cmds))
+	assert.Equal(t, []string{"ffmpeg", "-i", "save/id1/result.mp3", "-ss", "0.000", "-to", "2.000",
+		"-map", "0:a", "-map_chapters", "-1", "-c", "copy", "save/id1/result_001.mp3"}, cmds[1])
+	assert.Equal(t, []string{"ffmpeg", "-i", "save/id1/result.mp3", "-ss", "2.000",
+		"-map", "0:a", "-map_chapters", "-1", "-c", "copy", "save/id1/result_002.mp3"}, cmds[2])
+	assert.Equal(t, []string{"new/id1/result_001.mp3", "new/id1/result_002.mp3", "new/id1/manifest.json",
+		"new/id1/result.mp3"}, uploaded)
+	assert.Equal(t, `{"files":[{"name":"result_001.mp3","start":0,"duration":2},`+
+		`{"name":"result_002.mp3","start":2,"duration":1.5}]}`, manifest)
+}
+
+func TestWorker_Do_SplitFail(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	calls := 0
+	got.convertFunc = func(s []string) error {
+		calls++
+		if calls > 1 {
+			return assert.AnError
+		}
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"},
+		OutputFormat: "mp3", SplitBy: SplitByChapter})
+	assert.NotNil(t, err)
+}
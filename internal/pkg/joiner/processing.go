@@ -0,0 +1,205 @@
+package joiner
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airenas/big-tts/internal/pkg/audio"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/pkg/errors"
+)
+
+// Loudness is the target of the ffmpeg loudnorm filter
+type Loudness struct {
+	// Integrated loudness, LUFS
+	Integrated float64
+	// TruePeak is the maximum true peak, dBTP
+	TruePeak float64
+	// Range is the loudness range, LU
+	Range float64
+}
+
+var loudnessPresets = map[string]Loudness{
+	"ebu":     {Integrated: -23, TruePeak: -1, Range: 7},
+	"acx":     {Integrated: -20, TruePeak: -3, Range: 11},
+	"podcast": {Integrated: -16, TruePeak: -1.5, Range: 11},
+}
+
+// ParseLoudness parses a preset name (ebu, acx, podcast) or the integrated loudness in LUFS, e.g. -18.
+// Returns nil for an empty string
+func ParseLoudness(s string) (*Loudness, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return nil, nil
+	}
+	if l, ok := loudnessPresets[s]; ok {
+		return &l, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < -70 || v > -5 {
+		return nil, errors.Errorf("wrong loudness '%s', expected ebu, acx, podcast or LUFS in [-70, -5]", s)
+	}
+	return &Loudness{Integrated: v, TruePeak: -1, Range: 11}, nil
+}
+
+func (l *Loudness) filter() string {
+	return fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g", l.Integrated, l.TruePeak, l.Range)
+}
+
+// Processing configures the audio processing of the joined parts, the zero value - no processing
+type Processing struct {
+	// Loudness normalizes the result if set
+	Loudness *Loudness
+	// Gap is the silence between parts: longer leading and trailing silence of the parts is trimmed
+	Gap time.Duration
+}
+
+func (p *Processing) enabled() bool {
+	return p.Loudness != nil || p.Gap > 0
+}
+
+// trim is the part of a file to take
+type trim struct {
+	from, to time.Duration
+}
+
+// silenceThreshold is the volume ffmpeg's silencedetect takes as silence
+const silenceThreshold = "-50dB"
+
+// trimSilence leaves at most a half of the gap of silence at the start and the end of each file
+func (w *Worker) trimSilence(files []string) ([]trim, error) {
+	if w.processing.Gap <= 0 {
+		return nil, nil
+	}
+	half := w.processing.Gap / 2
+	res := make([]trim, 0, len(files))
+	for _, f := range files {
+		d, err := w.durationFunc(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get duration of %s", f)
+		}
+		lead, trail, err := w.silenceFunc(f, d)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't detect silence of %s", f)
+		}
+		t := trim{to: d}
+		if lead+trail < d {
+			if lead > half {
+				t.from = lead - half
+			}
+			if trail > half {
+				t.to = d - trail + half
+			}
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// encodeParams returns ffmpeg output params and the audio filter, the filter graph if assets are mixed.
+// The streams are copied only if no encoding params, no processing and no assets are needed
+func (w *Worker) encodeParams(f *audio.Format, partFormat string, msg *messages.TTSMessage, files []string,
+	as *assets) ([]string, string, error) {
+	res := f.EncodeParams(partFormat, msg.Bitrate, msg.SampleRate)
+	if !w.processing.enabled() && !as.enabled() {
+		return res, "", nil
+	}
+	if len(res) == 0 {
+		res = []string{"-c:a", f.Codec}
+	}
+	l := w.processing.Loudness
+	if l == nil && !as.enabled() {
+		return res, "", nil
+	}
+	sr := msg.SampleRate
+	if sr == 0 && len(files) > 0 {
+		// loudnorm upsamples to 192kHz, keep the rate of the parts if the format allows it
+		var err error
+		if sr, err = w.sampleRateFunc(files[0]); err != nil {
+			return nil, "", errors.Wrapf(err, "can't get sample rate of %s", files[0])
+		}
+		if l != nil && f.ValidateSampleRate(sr) == nil {
+			res = append(res, "-ar", strconv.Itoa(sr))
+		}
+	}
+	if !as.enabled() {
+		return res, l.filter(), nil
+	}
+	graph, label := as.graph(sr, w.musicVolume, l)
+	return append([]string{"-map", label}, res...), graph, nil
+}
+
+var (
+	silenceStart = regexp.MustCompile(`silence_start: *(-?[0-9.]+)`)
+	silenceEnd   = regexp.MustCompile(`silence_end: *(-?[0-9.]+)`)
+)
+
+// detectSilence returns the leading and trailing silence of the file of duration d
+func detectSilence(file string, d time.Duration) (time.Duration, time.Duration, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-i", file,
+		"-af", "silencedetect=n="+silenceThreshold+":d=0.05", "-f", "null", "-")
+	var out bytes.Buffer
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return 0, 0, errors.Wrap(err, "Output: "+out.String())
+	}
+	lead, trail := parseSilence(out.String(), d)
+	return lead, trail, nil
+}
+
+// parseSilence finds the leading and trailing silence in the silencedetect output
+func parseSilence(out string, d time.Duration) (time.Duration, time.Duration) {
+	const eps = 10 * time.Millisecond
+	var lead, trail time.Duration
+	start := time.Duration(-1)
+	for _, l := range strings.Split(out, "\n") {
+		if m := silenceStart.FindStringSubmatch(l); m != nil {
+			start = toDuration(m[1])
+			if start < 0 {
+				start = 0
+			}
+		} else if m := silenceEnd.FindStringSubmatch(l); m != nil && start >= 0 {
+			end := toDuration(m[1])
+			if start <= eps {
+				lead = end
+			}
+			if end >= d-eps {
+				trail = d - start
+			}
+			start = -1
+		}
+	}
+	if start >= 0 { // silence lasts till the end
+		if start <= eps {
+			lead = d
+		}
+		trail = d - start
+	}
+	return lead, trail
+}
+
+func toDuration(s string) time.Duration {
+	v, _ := strconv.ParseFloat(s, 64)
+	return time.Duration(v * float64(time.Second))
+}
+
+func probeSampleRate(file string) (int, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "a:0", "-show_entries", "stream=sample_rate",
+		"-of", "csv=p=0", file)
+	var errBuffer bytes.Buffer
+	cmd.Stderr = &errBuffer
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, errors.Wrap(err, "Output: "+errBuffer.String())
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't parse sample rate '%s'", strings.TrimSpace(string(out)))
+	}
+	return v, nil
+}
@@ -0,0 +1,145 @@
+package joiner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLoudness(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    *Loudness
+		wantErr bool
+	}{
+		{s: "", want: nil},
+		{s: " EBU ", want: &Loudness{Integrated: -23, TruePeak: -1, Range: 7}},
+		{s: "acx", want: &Loudness{Integrated: -20, TruePeak: -3, Range: 11}},
+		{s: "-18.5", want: &Loudness{Integrated: -18.5, TruePeak: -1, Range: 11}},
+		{s: "-4", wantErr: true},
+		{s: "olia", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseLoudness(tt.s)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_parseSilence(t *testing.T) {
+	tests := []struct {
+		name      string
+		out       string
+		wantLead  time.Duration
+		wantTrail time.Duration
+	}{
+		{name: "none", out: "Duration: 00:00:10.00"},
+		{name: "both", out: "[silencedetect @ 0x1] silence_start: -0.01\n" +
+			"[silencedetect @ 0x1] silence_end: 0.5 | silence_duration: 0.51\n" +
+			"[silencedetect @ 0x1] silence_start: 3\n[silencedetect @ 0x1] silence_end: 3.5 | silence_duration: 0.5\n" +
+			"[silencedetect @ 0x1] silence_start: 8.8\n", wantLead: 500 * time.Millisecond, wantTrail: 1200 * time.Millisecond},
+		{name: "end", out: "[silencedetect @ 0x1] silence_start: 9.5\n[silencedetect @ 0x1] silence_end: 10 | silence_duration: 0.5\n",
+			wantTrail: 500 * time.Millisecond},
+		{name: "all", out: "[silencedetect @ 0x1] silence_start: 0\n", wantLead: 10 * time.Second, wantTrail: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lead, trail := parseSilence(tt.out, 10*time.Second)
+			assert.Equal(t, tt.wantLead, lead)
+			assert.Equal(t, tt.wantTrail, trail)
+		})
+	}
+}
+
+func TestWorker_SetProcessing(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	assert.NotNil(t, got.SetProcessing(Processing{Gap: -time.Second}))
+	assert.Nil(t, got.SetProcessing(Processing{Gap: time.Second}))
+	assert.Equal(t, time.Second, got.processing.Gap)
+}
+
+func TestWorker_Do_Loudness(t *testing.T) {
+	got := newTestWorker(t, nil, 2)
+	got.processing = Processing{Loudness: &Loudness{Integrated: -23, TruePeak: -1, Range: 7}}
+	got.sampleRateFunc = func(s string) (int, error) {
+		assert.Equal(t, "local/in/id1/0000.mp3", s)
+		return 22050, nil
+	}
+	got.convertFunc = func(s []string) error {
+		assert.Equal(t, []string{"ffmpeg", "-f", "concat",
+			"-safe", "0",
+			"-i", "save/id1/list.txt",
+			"-af", "loudnorm=I=-23:TP=-1:LRA=7",
+			"-c:a", "libmp3lame", "-ar", "22050",
+			"save/id1/result.mp3"}, s)
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	assert.Nil(t, err)
+}
+
+func TestWorker_Do_LoudnessSampleRate(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	got.processing = Processing{Loudness: &Loudness{Integrated: -16, TruePeak: -1.5, Range: 11}}
+	got.sampleRateFunc = func(s string) (int, error) { return 22050, nil }
+	got.convertFunc = func(s []string) error {
+		assert.Equal(t, []string{"ffmpeg", "-f", "concat",
+			"-safe", "0",
+			"-i", "save/id1/list.txt",
+			"-af", "loudnorm=I=-16:TP=-1.5:LRA=11",
+			"-c:a", "libopus",
+			"save/id1/result.ogg"}, s)
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "ogg"})
+	assert.Nil(t, err)
+}
+
+func TestWorker_Do_Gap(t *testing.T) {
+	got := newTestWorker(t, nil, 2)
+	got.processing = Processing{Gap: 400 * time.Millisecond}
+	got.durationFunc = func(s string) (time.Duration, error) { return 5 * time.Second, nil }
+	got.silenceFunc = func(s string, d time.Duration) (time.Duration, time.Duration, error) {
+		assert.Equal(t, 5*time.Second, d)
+		if s == "local/in/id1/0000.mp3" {
+			return time.Second, 100 * time.Millisecond, nil
+		}
+		return 0, 2 * time.Second, nil
+	}
+	got.saveFunc = func(s string, b []byte) error {
+		if s == "save/id1/list.txt" {
+			assert.Equal(t, "file 'local/in/id1/0000.mp3'\ninpoint 0.800\noutpoint 5.000\n"+
+				"file 'local/in/id1/0001.mp3'\ninpoint 0.000\noutpoint 3.200\n", string(b))
+		}
+		if s == "save/id1/chapters.txt" {
+			assert.Contains(t, string(b), "START=0\nEND=7400\n")
+		}
+		return nil
+	}
+	got.convertFunc = func(s []string) error {
+		assert.Contains(t, s, "libmp3lame")
+		assert.NotContains(t, s, "copy")
+		return nil
+	}
+	err := got.SetSplitPath("split/{}", false)
+	assert.Nil(t, err)
+	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	assert.Nil(t, err)
+}
+
+func TestWorker_Do_GapFail(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	got.processing = Processing{Gap: time.Second}
+	got.silenceFunc = func(s string, d time.Duration) (time.Duration, time.Duration, error) {
+		return 0, 0, errors.New("olia")
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	assert.NotNil(t, err)
+}
@@ -3,13 +3,21 @@ package joiner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/airenas/big-tts/internal/pkg/audio"
 	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/airenas/big-tts/internal/pkg/subtitles"
 	"github.com/airenas/big-tts/internal/pkg/utils"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/pkg/errors"
@@ -20,16 +28,35 @@ type Worker struct {
 	inDir    string
 	savePath string
 	workPath string
-	metadata []string
+	// metadata are the templates of key=value entries, filled with the request's metadata
+	metadata []*template.Template
+	// splitPath is a template of the splitter's dir with texts and chapters
+	splitPath  string
+	subtitles  bool
+	processing Processing
+	// assetPath is a template of the uploaded asset file
+	assetPath   string
+	musicVolume float64
 
-	existsFunc    func(string) bool
+	existsFunc    func(string) (bool, error)
+	loadFunc      func(string) ([]byte, error)
+	downloadFunc  func(string, string) (string, error)
+	uploadFunc    func(string, string) error
 	saveFunc      func(string, []byte) error
 	createDirFunc func(string) error
+	removeDirFunc func(string) error
 	convertFunc   func([]string) error
+	durationFunc  func(string) (time.Duration, error)
+	// silenceFunc returns the leading and trailing silence of a file
+	silenceFunc    func(string, time.Duration) (time.Duration, time.Duration, error)
+	sampleRateFunc func(string) (int, error)
 }
 
-// NewWorker creates new join worker
-func NewWorker(inDir, savePath, workPath string, metadata []string) (*Worker, error) {
+// NewWorker creates new join worker, workPath is a local dir for ffmpeg files
+func NewWorker(st storage.Storage, inDir, savePath, workPath string, metadata []string) (*Worker, error) {
+	if st == nil {
+		return nil, errors.Errorf("no storage")
+	}
 	if !strings.Contains(inDir, "{}") {
 		return nil, errors.Errorf("no ID template in inDir")
 	}
@@ -39,40 +66,209 @@ func NewWorker(inDir, savePath, workPath string, metadata []string) (*Worker, er
 	if !strings.Contains(workPath, "{}") {
 		return nil, errors.Errorf("no ID template in workPath")
 	}
+	tmpls, err := parseMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
 	goapp.Log.Infof("Joiner in: %s", inDir)
 	goapp.Log.Infof("Joiner out: %s", savePath)
-	res := &Worker{inDir: inDir, savePath: savePath, metadata: metadata, workPath: workPath}
-	res.existsFunc = utils.FileExists
+	res := &Worker{inDir: inDir, savePath: savePath, metadata: tmpls, workPath: workPath}
+	res.existsFunc = st.Exists
+	res.loadFunc = func(name string) ([]byte, error) { return storage.ReadFile(st, name) }
+	res.downloadFunc = st.Download
+	res.uploadFunc = st.Upload
 	res.saveFunc = utils.WriteFile
 	res.convertFunc = runCmd
 	res.createDirFunc = func(name string) error { return os.MkdirAll(name, os.ModePerm) }
+	res.removeDirFunc = os.RemoveAll
+	res.durationFunc = probeDuration
+	res.silenceFunc = detectSilence
+	res.sampleRateFunc = probeSampleRate
 	return res, nil
 }
 
+// SetSplitPath enables chapters, path is a template of the splitter's output dir.
+// Subtitles are made if subtitles is true
+func (w *Worker) SetSplitPath(path string, subtitles bool) error {
+	if !strings.Contains(path, "{}") {
+		return errors.Errorf("no ID template in split path")
+	}
+	goapp.Log.Infof("Joiner split dir: %s, subtitles: %t", path, subtitles)
+	w.splitPath, w.subtitles = path, subtitles
+	return nil
+}
+
+// SetProcessing enables loudness normalization and the silence trimming between parts.
+// The result is re-encoded then
+func (w *Worker) SetProcessing(p Processing) error {
+	if p.Gap < 0 {
+		return errors.Errorf("wrong gap %s", p.Gap)
+	}
+	if p.Loudness != nil {
+		goapp.Log.Infof("Joiner loudness: %s", p.Loudness.filter())
+	}
+	goapp.Log.Infof("Joiner gap: %s", p.Gap)
+	w.processing = p
+	return nil
+}
+
 // Do is an entry function for join worker
 func (w *Worker) Do(ctx context.Context, msg *messages.TTSMessage) error {
 	goapp.Log.Infof("Doing join job for %s", msg.ID)
-	files, err := w.makeList(msg.ID, msg.OutputFormat)
-	if err != nil {
-		return errors.Wrapf(err, "can't prepare files list")
+	f, ok := audio.Get(msg.OutputFormat)
+	if !ok {
+		return errors.Errorf("unknown output format '%s'", msg.OutputFormat)
 	}
-	path := strings.ReplaceAll(w.savePath, "{}", msg.ID)
-	err = w.createDirFunc(path)
+	partFormat := audio.PartFormat(msg.OutputFormat)
+	files, err := w.makeList(msg.ID, partFormat)
 	if err != nil {
-		return errors.Wrapf(err, "can't create %s", path)
+		return errors.Wrapf(err, "can't prepare files list")
 	}
 	wpath := strings.ReplaceAll(w.workPath, "{}", msg.ID)
 	err = w.createDirFunc(wpath)
 	if err != nil {
 		return errors.Wrapf(err, "can't create %s", wpath)
 	}
+	// parts are copied here only if the storage is not a local disk
+	partsDir := filepath.Join(wpath, "parts")
+	defer func() {
+		if err := w.removeDirFunc(partsDir); err != nil {
+			goapp.Log.Warn(errors.Wrapf(err, "can't remove %s", partsDir))
+		}
+	}()
+	localFiles := make([]string, 0, len(files))
+	for _, fn := range files {
+		lf, err := w.downloadFunc(fn, partsDir)
+		if err != nil {
+			return errors.Wrapf(err, "can't download %s", fn)
+		}
+		localFiles = append(localFiles, lf)
+	}
+	as, err := w.loadAssets(msg, partsDir)
+	if err != nil {
+		return err
+	}
+	trims, err := w.trimSilence(localFiles)
+	if err != nil {
+		return err
+	}
 	listFile := filepath.Join(wpath, "list.txt")
-	err = w.saveFunc(listFile, []byte(prepareListFile(files)))
+	err = w.saveFunc(listFile, []byte(prepareListFile(localFiles, trims)))
 	if err != nil {
 		return errors.Wrapf(err, "can't save %s", listFile)
 	}
-	outFile := filepath.Join(path, fmt.Sprintf("result.%s", msg.OutputFormat))
-	return w.join(listFile, outFile)
+	chapters, err := w.loadChapters(msg.ID)
+	if err != nil {
+		return errors.Wrapf(err, "can't load chapters")
+	}
+	var durations []time.Duration
+	// offset is the duration of the intro, chapters and subtitles start after it
+	var offset time.Duration
+	if len(chapters) > 0 || w.subtitles || splitRequested(msg) {
+		if durations, err = w.getDurations(localFiles, trims); err != nil {
+			return err
+		}
+		if as.intro != "" {
+			if offset, err = w.durationFunc(as.intro); err != nil {
+				return errors.Wrapf(err, "can't get duration of %s", as.intro)
+			}
+		}
+	}
+	chaptersFile := ""
+	if len(chapters) > 0 {
+		chaptersFile = filepath.Join(wpath, "chapters.txt")
+		if err := w.saveFunc(chaptersFile, []byte(prepareChaptersFile(chapters, durations, offset))); err != nil {
+			return errors.Wrapf(err, "can't save %s", chaptersFile)
+		}
+	}
+	resName := fmt.Sprintf("result.%s", msg.OutputFormat)
+	tmpFile := filepath.Join(wpath, resName)
+	encParams, filter, err := w.encodeParams(f, partFormat, msg, localFiles, as)
+	if err != nil {
+		return err
+	}
+	meta, err := w.getMetadataParams(msg)
+	if err != nil {
+		return err
+	}
+	if err := w.join(listFile, chaptersFile, as, tmpFile, encParams, filter, meta); err != nil {
+		return err
+	}
+	if splitRequested(msg) {
+		if err := w.splitResult(msg, wpath, tmpFile, chapters, durations, offset); err != nil {
+			return errors.Wrapf(err, "can't split result")
+		}
+	}
+	if err := w.upload(msg.ID, resName, tmpFile); err != nil {
+		return err
+	}
+	if w.subtitles {
+		if err := w.makeSubtitles(msg.ID, wpath, durations, offset); err != nil {
+			return errors.Wrapf(err, "can't make subtitles")
+		}
+	}
+	return nil
+}
+
+func (w *Worker) upload(ID, name, file string) error {
+	outFile := filepath.Join(strings.ReplaceAll(w.savePath, "{}", ID), name)
+	if err := w.uploadFunc(outFile, file); err != nil {
+		return errors.Wrapf(err, "can't save %s", outFile)
+	}
+	return nil
+}
+
+// getDurations returns the durations of the files, the trimmed ones if trims are provided
+func (w *Worker) getDurations(files []string, trims []trim) ([]time.Duration, error) {
+	res := make([]time.Duration, 0, len(files))
+	if trims != nil {
+		for _, t := range trims {
+			res = append(res, t.to-t.from)
+		}
+		return res, nil
+	}
+	for _, f := range files {
+		d, err := w.durationFunc(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get duration of %s", f)
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// makeSubtitles aligns the split texts to the parts durations, saves srt and vtt files next to the result.
+// The first part starts at offset
+func (w *Worker) makeSubtitles(ID, wpath string, durations []time.Duration, offset time.Duration) error {
+	var cues []subtitles.Cue
+	start := offset
+	for i, d := range durations {
+		path := filepath.Join(strings.ReplaceAll(w.splitPath, "{}", ID), fmt.Sprintf("%04d.txt", i))
+		text, err := w.loadFunc(path)
+		if err != nil {
+			return errors.Wrapf(err, "can't load %s", path)
+		}
+		pc, err := subtitles.Align(string(text), start, d)
+		if err != nil {
+			return errors.Wrapf(err, "can't align %s", path)
+		}
+		cues = append(cues, pc...)
+		start += d
+	}
+	for _, st := range []struct {
+		typ  string
+		data string
+	}{{typ: subtitles.TypeSRT, data: subtitles.SRT(cues)}, {typ: subtitles.TypeVTT, data: subtitles.VTT(cues)}} {
+		name := "result." + st.typ
+		file := filepath.Join(wpath, name)
+		if err := w.saveFunc(file, []byte(st.data)); err != nil {
+			return errors.Wrapf(err, "can't save %s", file)
+		}
+		if err := w.upload(ID, name, file); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (w *Worker) makeList(ID, format string) ([]string, error) {
@@ -81,27 +277,110 @@ func (w *Worker) makeList(ID, format string) ([]string, error) {
 
 	for i := 0; ; i++ {
 		inFile := filepath.Join(path, fmt.Sprintf("%04d.%s", i, format))
-		if w.existsFunc(inFile) {
-			res = append(res, inFile)
-		} else {
+		ok, err := w.existsFunc(inFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't check %s", inFile)
+		}
+		if !ok {
 			break
 		}
+		res = append(res, inFile)
 	}
 	return res, nil
 }
 
-func prepareListFile(files []string) string {
+// prepareListFile makes ffmpeg concat list, the parts of the files are taken if trims are provided
+func prepareListFile(files []string, trims []trim) string {
 	res := strings.Builder{}
 
-	for _, s := range files {
+	for i, s := range files {
 		res.WriteString(fmt.Sprintf("file '%s'\n", s))
+		if i < len(trims) {
+			res.WriteString(fmt.Sprintf("inpoint %.3f\noutpoint %.3f\n", trims[i].from.Seconds(), trims[i].to.Seconds()))
+		}
 	}
 	return res.String()
 }
 
-func (w *Worker) join(nameIn string, out string) error {
-	params := []string{"ffmpeg", "-f", "concat", "-safe", "0", "-i", nameIn, "-c", "copy"}
-	params = append(params, getMetadataParams(w.metadata)...)
+// loadChapters returns the chapters saved by the splitter, nil if the job has no chapters
+func (w *Worker) loadChapters(ID string) ([]splitter.Chapter, error) {
+	if w.splitPath == "" {
+		return nil, nil
+	}
+	path := filepath.Join(strings.ReplaceAll(w.splitPath, "{}", ID), splitter.ChaptersFile)
+	ok, err := w.existsFunc(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't check %s", path)
+	}
+	if !ok {
+		return nil, nil
+	}
+	data, err := w.loadFunc(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't load %s", path)
+	}
+	var res []splitter.Chapter
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, errors.Wrapf(err, "can't unmarshal %s", path)
+	}
+	return res, nil
+}
+
+// prepareChaptersFile makes ffmpeg metadata file, a chapter starts at the beginning of its part.
+// The first part starts at offset
+func prepareChaptersFile(chapters []splitter.Chapter, durations []time.Duration, offset time.Duration) string {
+	starts := make([]time.Duration, len(durations)+1)
+	starts[0] = offset
+	for i, d := range durations {
+		starts[i+1] = starts[i] + d
+	}
+	res := strings.Builder{}
+	res.WriteString(";FFMETADATA1\n")
+	for i, ch := range chapters {
+		if ch.Part < 0 || ch.Part >= len(durations) {
+			continue
+		}
+		end := starts[len(durations)]
+		if i+1 < len(chapters) && chapters[i+1].Part < len(durations) {
+			end = starts[chapters[i+1].Part]
+		}
+		if end <= starts[ch.Part] {
+			continue
+		}
+		res.WriteString("[CHAPTER]\nTIMEBASE=1/1000\n")
+		res.WriteString(fmt.Sprintf("START=%d\nEND=%d\n", starts[ch.Part].Milliseconds(), end.Milliseconds()))
+		res.WriteString(fmt.Sprintf("title=%s\n", escapeMetadata(ch.Title)))
+	}
+	return res.String()
+}
+
+var metadataEscaper = strings.NewReplacer("\\", "\\\\", "=", "\\=", ";", "\\;", "#", "\\#", "\n", "\\\n")
+
+func escapeMetadata(s string) string {
+	return metadataEscaper.Replace(s)
+}
+
+// join concatenates files, encodes if encParams are provided, otherwise copies the streams.
+// Chapters are taken from the ffmpeg metadata file if provided, the audio filter is applied if not empty.
+// If assets are provided, the filter is a graph mixing them
+func (w *Worker) join(nameIn, chaptersIn string, as *assets, out string, encParams []string, filter string,
+	meta []string) error {
+	params := []string{"ffmpeg", "-f", "concat", "-safe", "0", "-i", nameIn}
+	params = append(params, as.inputs()...)
+	if chaptersIn != "" {
+		params = append(params, "-i", chaptersIn, "-map_chapters", strconv.Itoa(as.count()+1))
+	}
+	if as.enabled() {
+		params = append(params, "-filter_complex", filter)
+	} else if filter != "" {
+		params = append(params, "-af", filter)
+	}
+	if len(encParams) > 0 {
+		params = append(params, encParams...)
+	} else {
+		params = append(params, "-c", "copy")
+	}
+	params = append(params, meta...)
 	params = append(params, out)
 	err := w.convertFunc(params)
 	if err != nil {
@@ -110,16 +389,59 @@ func (w *Worker) join(nameIn string, out string) error {
 	return nil
 }
 
-func getMetadataParams(prm []string) []string {
-	res := []string{}
-	for _, p := range prm {
+// parseMetadata parses key=value entries, the value may be a template, e.g. title={{.title}}
+func parseMetadata(prm []string) ([]*template.Template, error) {
+	var res []*template.Template
+	for i, p := range prm {
 		pt := strings.TrimSpace(p)
-		if pt != "" {
-			res = append(res, "-metadata")
-			res = append(res, pt)
+		if pt == "" {
+			continue
 		}
+		t, err := template.New(fmt.Sprintf("metadata%d", i)).Option("missingkey=zero").Parse(pt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "wrong metadata '%s'", pt)
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// getMetadataParams fills the metadata templates with the request's metadata, id and voice.
+// The entries with an empty value are skipped
+func (w *Worker) getMetadataParams(msg *messages.TTSMessage) ([]string, error) {
+	data := map[string]string{}
+	for k, v := range msg.Metadata {
+		data[k] = v
+	}
+	data["id"], data["voice"] = msg.ID, msg.Voice
+	res := []string{}
+	for _, t := range w.metadata {
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			return nil, errors.Wrap(err, "can't fill metadata")
+		}
+		pt := strings.TrimSpace(b.String())
+		if k, v, ok := strings.Cut(pt, "="); !ok || strings.TrimSpace(k) == "" || strings.TrimSpace(v) == "" {
+			continue
+		}
+		res = append(res, "-metadata", pt)
+	}
+	return res, nil
+}
+
+func probeDuration(file string) (time.Duration, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", file)
+	var errBuffer bytes.Buffer
+	cmd.Stderr = &errBuffer
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, errors.Wrap(err, "Output: "+errBuffer.String())
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't parse duration '%s'", strings.TrimSpace(string(out)))
 	}
-	return res
+	return time.Duration(v * float64(time.Second)), nil
 }
 
 func runCmd(cmdArr []string) error {
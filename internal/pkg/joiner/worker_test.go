@@ -3,45 +3,79 @@ package joiner
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	amessages "github.com/airenas/async-api/pkg/messages"
 	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/big-tts/internal/pkg/storage"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewWorker(t *testing.T) {
-	got, err := NewWorker("{}.txt", "new{}.txt", "save{}", nil)
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new{}.txt", "save{}", nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, got)
-	_, err = NewWorker(".txt", "new{}.txt", "Save{}", nil)
+	_, err = NewWorker(&storage.Local{}, ".txt", "new{}.txt", "Save{}", nil)
 	assert.NotNil(t, err)
-	_, err = NewWorker("{}.txt", "new.txt", "Save{}", nil)
+	_, err = NewWorker(&storage.Local{}, "{}.txt", "new.txt", "Save{}", nil)
 	assert.NotNil(t, err)
-	_, err = NewWorker("{}.txt", "new{}.txt", "Save", nil)
+	_, err = NewWorker(&storage.Local{}, "{}.txt", "new{}.txt", "Save", nil)
+	assert.NotNil(t, err)
+	_, err = NewWorker(nil, "{}.txt", "new{}.txt", "save{}", nil)
 	assert.NotNil(t, err)
 }
 
-func TestWorker_Do(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "save/{}/", []string{"aaa=bbb", "ccc=ddd"})
+func newTestWorker(t *testing.T, metadata []string, parts int) *Worker {
+	t.Helper()
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "save/{}/", metadata)
 	assert.Nil(t, err)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
+		if s == "split/id1/chapters.json" {
+			return true, nil
+		}
+		files++
+		return files <= parts, nil
+	}
+	got.downloadFunc = func(s, dir string) (string, error) {
+		assert.Equal(t, "save/id1/parts", dir)
+		return "local/" + s, nil
+	}
+	got.uploadFunc = func(s, f string) error { return nil }
+	got.createDirFunc = func(s string) error { return nil }
+	got.removeDirFunc = func(s string) error { return nil }
+	got.saveFunc = func(s string, b []byte) error { return nil }
+	got.convertFunc = func(s []string) error { return nil }
+	got.loadFunc = func(s string) ([]byte, error) { return []byte(`[{"title":"One","part":0}]`), nil }
+	got.durationFunc = func(s string) (time.Duration, error) { return time.Second, nil }
+	return got
+}
+
+func TestWorker_Do(t *testing.T) {
+	got := newTestWorker(t, []string{"aaa=bbb", "ccc=ddd"}, 2)
+	files := 0
+	got.existsFunc = func(s string) (bool, error) {
 		if files == 0 {
 			assert.Equal(t, "in/id1/0000.mp3", s)
 		}
 		files++
-		return files < 3
+		return files < 3, nil
 	}
-	testCreate := "new/id1/"
 	got.createDirFunc = func(s string) error {
-		assert.Equal(t, testCreate, s)
-		testCreate = "save/id1/"
+		assert.Equal(t, "save/id1/", s)
+		return nil
+	}
+	removed := ""
+	got.removeDirFunc = func(s string) error {
+		removed = s
 		return nil
 	}
 	got.saveFunc = func(s string, b []byte) error {
 		assert.Equal(t, "save/id1/list.txt", s)
-		assert.Equal(t, "file 'in/id1/0000.mp3'\nfile 'in/id1/0001.mp3'\n", string(b))
+		assert.Equal(t, "file 'local/in/id1/0000.mp3'\nfile 'local/in/id1/0001.mp3'\n", string(b))
 		return nil
 	}
 	got.convertFunc = func(s []string) error {
@@ -51,76 +85,234 @@ func TestWorker_Do(t *testing.T) {
 			"-c", "copy",
 			"-metadata", "aaa=bbb",
 			"-metadata", "ccc=ddd",
-			"new/id1/result.mp3"}, s)
+			"save/id1/result.mp3"}, s)
 		return nil
 	}
-	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	uploaded := false
+	got.uploadFunc = func(s, f string) error {
+		assert.Equal(t, "new/id1/result.mp3", s)
+		assert.Equal(t, "save/id1/result.mp3", f)
+		uploaded = true
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
 	assert.Nil(t, err)
+	assert.True(t, uploaded)
+	assert.Equal(t, "save/id1/parts", removed)
 }
 
-func TestWorker_Do_Fail(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "save/{}/", nil)
-	assert.Nil(t, err)
+func TestWorker_Do_Transcode(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
+		if files == 0 {
+			assert.Equal(t, "in/id1/0000.m4a", s)
+		}
 		files++
-		return files < 3
-	}
-	got.createDirFunc = func(s string) error {
-		return errors.New("err")
-	}
-	got.saveFunc = func(s string, b []byte) error {
-		return nil
+		return files < 2, nil
 	}
 	got.convertFunc = func(s []string) error {
+		assert.Equal(t, []string{"ffmpeg", "-f", "concat",
+			"-safe", "0",
+			"-i", "save/id1/list.txt",
+			"-c:a", "libopus", "-b:a", "32k", "-ar", "16000",
+			"save/id1/result.ogg"}, s)
 		return nil
 	}
-	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
-	assert.NotNil(t, err)
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "ogg",
+		Bitrate: 32, SampleRate: 16000})
+	assert.Nil(t, err)
 }
 
-func TestWorker_Do_FailSave(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "save/{}/", nil)
-	assert.Nil(t, err)
-	files := 0
-	got.existsFunc = func(s string) bool {
-		files++
-		return files < 3
+func TestWorker_SetSplitPath(t *testing.T) {
+	got := newTestWorker(t, nil, 1)
+	assert.Nil(t, got.SetSplitPath("split/{}", false))
+	assert.NotNil(t, got.SetSplitPath("split", false))
+}
+
+func TestWorker_Do_Chapters(t *testing.T) {
+	got := newTestWorker(t, nil, 0)
+	assert.Nil(t, got.SetSplitPath("split/{}", false))
+	got.existsFunc = func(s string) (bool, error) {
+		return s == "in/id1/0000.m4a" || s == "in/id1/0001.m4a" || s == "in/id1/0002.m4a" || s == "split/id1/chapters.json", nil
 	}
-	got.createDirFunc = func(s string) error {
-		return nil
+	got.loadFunc = func(s string) ([]byte, error) {
+		assert.Equal(t, "split/id1/chapters.json", s)
+		return []byte(`[{"title":"One","part":0},{"title":"Two=2","part":2}]`), nil
 	}
+	got.durationFunc = func(s string) (time.Duration, error) {
+		return map[string]time.Duration{"local/in/id1/0000.m4a": time.Second, "local/in/id1/0001.m4a": 1500 * time.Millisecond,
+			"local/in/id1/0002.m4a": 2 * time.Second}[s], nil
+	}
+	saved := map[string]string{}
 	got.saveFunc = func(s string, b []byte) error {
-		return errors.New("err")
+		saved[s] = string(b)
+		return nil
 	}
 	got.convertFunc = func(s []string) error {
+		assert.Equal(t, []string{"ffmpeg", "-f", "concat",
+			"-safe", "0",
+			"-i", "save/id1/list.txt",
+			"-i", "save/id1/chapters.txt", "-map_chapters", "1",
+			"-c", "copy",
+			"save/id1/result.m4a"}, s)
 		return nil
 	}
-	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
-	assert.NotNil(t, err)
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "m4a"})
+	assert.Nil(t, err)
+	assert.Equal(t, ";FFMETADATA1\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=2500\ntitle=One\n"+
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=2500\nEND=4500\ntitle=Two\\=2\n", saved["save/id1/chapters.txt"])
 }
 
-func TestWorker_Do_FailConvert(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "save/{}/", nil)
-	assert.Nil(t, err)
-	files := 0
-	got.existsFunc = func(s string) bool {
-		files++
-		return files < 3
+func TestWorker_Do_NoChapters(t *testing.T) {
+	got := newTestWorker(t, nil, 0)
+	assert.Nil(t, got.SetSplitPath("split/{}", false))
+	got.existsFunc = func(s string) (bool, error) {
+		return s == "in/id1/0000.mp3", nil
 	}
-	got.createDirFunc = func(s string) error {
+	got.convertFunc = func(s []string) error {
+		assert.NotContains(t, s, "-map_chapters")
 		return nil
 	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	assert.Nil(t, err)
+}
+
+func TestWorker_Do_Subtitles(t *testing.T) {
+	got := newTestWorker(t, nil, 2)
+	assert.Nil(t, got.SetSplitPath("split/{}", true))
+	got.existsFunc = func(s string) (bool, error) {
+		return s == "in/id1/0000.mp3" || s == "in/id1/0001.mp3", nil
+	}
+	got.loadFunc = func(s string) ([]byte, error) {
+		return map[string][]byte{"split/id1/0000.txt": []byte("Olia."), "split/id1/0001.txt": []byte("Olia 2.")}[s], nil
+	}
+	got.durationFunc = func(s string) (time.Duration, error) {
+		return map[string]time.Duration{"local/in/id1/0000.mp3": time.Second, "local/in/id1/0001.mp3": 2 * time.Second}[s], nil
+	}
+	saved := map[string]string{}
 	got.saveFunc = func(s string, b []byte) error {
+		saved[s] = string(b)
 		return nil
 	}
-	got.convertFunc = func(s []string) error {
-		return errors.New("err")
+	uploaded := map[string]string{}
+	got.uploadFunc = func(s, f string) error {
+		uploaded[s] = f
+		return nil
+	}
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,000\nOlia.\n\n2\n00:00:01,000 --> 00:00:03,000\nOlia 2.\n\n",
+		saved["save/id1/result.srt"])
+	assert.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nOlia.\n\n00:00:01.000 --> 00:00:03.000\nOlia 2.\n\n",
+		saved["save/id1/result.vtt"])
+	assert.Equal(t, map[string]string{"new/id1/result.mp3": "save/id1/result.mp3", "new/id1/result.srt": "save/id1/result.srt",
+		"new/id1/result.vtt": "save/id1/result.vtt"}, uploaded)
+}
+
+func Test_prepareChaptersFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		chapters  []splitter.Chapter
+		durations []time.Duration
+		offset    time.Duration
+		want      string
+	}{
+		{name: "Skips intro", chapters: []splitter.Chapter{{Title: "One", Part: 1}},
+			durations: []time.Duration{time.Second, time.Second},
+			want:      ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=1000\nEND=2000\ntitle=One\n"},
+		{name: "Skips missing parts", chapters: []splitter.Chapter{{Title: "One", Part: 0}, {Title: "Two", Part: 3}},
+			durations: []time.Duration{time.Second},
+			want:      ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1000\ntitle=One\n"},
+		{name: "Escapes", chapters: []splitter.Chapter{{Title: "a;b#c\\d\ne", Part: 0}},
+			durations: []time.Duration{time.Second},
+			want:      ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1000\ntitle=a\\;b\\#c\\\\d\\\ne\n"},
+		{name: "Offset", chapters: []splitter.Chapter{{Title: "One", Part: 0}, {Title: "Two", Part: 1}},
+			durations: []time.Duration{time.Second, time.Second}, offset: 500 * time.Millisecond,
+			want: ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=500\nEND=1500\ntitle=One\n" +
+				"[CHAPTER]\nTIMEBASE=1/1000\nSTART=1500\nEND=2500\ntitle=Two\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, prepareChaptersFile(tt.chapters, tt.durations, tt.offset))
+		})
 	}
-	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+}
+
+func TestWorker_Do_FailFormat(t *testing.T) {
+	got := newTestWorker(t, nil, 2)
+	err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "aaa"})
 	assert.NotNil(t, err)
 }
 
+func TestWorker_Do_Fail(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(w *Worker)
+	}{
+		{name: "Exists", prepare: func(w *Worker) {
+			w.existsFunc = func(s string) (bool, error) { return false, errors.New("err") }
+		}},
+		{name: "Create dir", prepare: func(w *Worker) {
+			w.createDirFunc = func(s string) error { return errors.New("err") }
+		}},
+		{name: "Download", prepare: func(w *Worker) {
+			w.downloadFunc = func(s, d string) (string, error) { return "", errors.New("err") }
+		}},
+		{name: "Save", prepare: func(w *Worker) {
+			w.saveFunc = func(s string, b []byte) error { return errors.New("err") }
+		}},
+		{name: "Convert", prepare: func(w *Worker) {
+			w.convertFunc = func(s []string) error { return errors.New("err") }
+		}},
+		{name: "Upload", prepare: func(w *Worker) {
+			w.uploadFunc = func(s, f string) error { return errors.New("err") }
+		}},
+		{name: "Load chapters", prepare: func(w *Worker) {
+			_ = w.SetSplitPath("split/{}", false)
+			w.loadFunc = func(s string) ([]byte, error) { return nil, errors.New("err") }
+		}},
+		{name: "Wrong chapters", prepare: func(w *Worker) {
+			_ = w.SetSplitPath("split/{}", false)
+			w.loadFunc = func(s string) ([]byte, error) { return []byte("olia"), nil }
+		}},
+		{name: "Duration", prepare: func(w *Worker) {
+			_ = w.SetSplitPath("split/{}", false)
+			w.durationFunc = func(s string) (time.Duration, error) { return 0, errors.New("err") }
+		}},
+		{name: "Load text", prepare: func(w *Worker) {
+			_ = w.SetSplitPath("split/{}", true)
+			w.existsFunc = func(s string) (bool, error) { return s == "in/id1/0000.mp3", nil }
+			w.loadFunc = func(s string) ([]byte, error) { return nil, errors.New("err") }
+		}},
+		{name: "Align", prepare: func(w *Worker) {
+			_ = w.SetSplitPath("split/{}", true)
+			w.existsFunc = func(s string) (bool, error) { return s == "in/id1/0000.mp3", nil }
+			w.loadFunc = func(s string) ([]byte, error) { return []byte("<speak>olia</spk>"), nil }
+		}},
+		{name: "Upload subtitles", prepare: func(w *Worker) {
+			_ = w.SetSplitPath("split/{}", true)
+			w.existsFunc = func(s string) (bool, error) { return s == "in/id1/0000.mp3", nil }
+			w.loadFunc = func(s string) ([]byte, error) { return []byte("olia"), nil }
+			w.uploadFunc = func(s, f string) error {
+				if strings.HasSuffix(s, ".vtt") {
+					return errors.New("err")
+				}
+				return nil
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTestWorker(t, nil, 2)
+			tt.prepare(got)
+			err := got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+			assert.NotNil(t, err)
+		})
+	}
+}
+
 func Test_runCmd(t *testing.T) {
 	type args struct {
 		cmdArr []string
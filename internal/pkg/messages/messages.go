@@ -1,6 +1,8 @@
 package messages
 
 import (
+	"time"
+
 	amessages "github.com/airenas/async-api/pkg/messages"
 )
 
@@ -18,8 +20,18 @@ const (
 	Fail = st + "Fail"
 	// Inform  queue name
 	Inform = st + "Inform"
+	// Callback queue name, the inform service forwards messages of jobs with a callback URL
+	Callback = st + "Callback"
 )
 
+// InformTypeCanceled is the inform message type of the job canceled by the user
+const InformTypeCanceled = "Canceled"
+
+// StageQueue returns the queue name of the pipeline stage
+func StageQueue(stage string) string {
+	return st + stage
+}
+
 // TTSMessage main message passing through in big tts system
 type TTSMessage struct {
 	amessages.QueueMessage
@@ -29,10 +41,27 @@ type TTSMessage struct {
 	OutputFormat string   `json:"outputFormat,omitempty"`
 	SaveTags     []string `json:"tags,omitempty"`
 	RequestID    string   `json:"requestID,omitempty"`
+	Priority     int      `json:"priority,omitempty"`
+	Bitrate      int      `json:"bitrate,omitempty"`
+	SampleRate   int      `json:"sampleRate,omitempty"`
+	Lexicon      string   `json:"lexicon,omitempty"`
+	// Intro, Outro and Music are the IDs of the audio assets
+	Intro    string            `json:"intro,omitempty"`
+	Outro    string            `json:"outro,omitempty"`
+	Music    string            `json:"music,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// MaxDuration and SplitBy split the result into several files
+	MaxDuration time.Duration `json:"maxDuration,omitempty"`
+	SplitBy     string        `json:"splitBy,omitempty"`
+	// RestorePart is the part of the usage to restore on failure, 0 - all
+	RestorePart float64 `json:"restorePart,omitempty"`
 }
 
 // NewMessageFrom creates a copy of a message
 func NewMessageFrom(m *TTSMessage) *TTSMessage {
 	return &TTSMessage{QueueMessage: m.QueueMessage, Voice: m.Voice, SaveRequest: m.SaveRequest,
-		Speed: m.Speed, SaveTags: m.SaveTags, OutputFormat: m.OutputFormat, RequestID: m.RequestID}
+		Speed: m.Speed, SaveTags: m.SaveTags, OutputFormat: m.OutputFormat, RequestID: m.RequestID,
+		Priority: m.Priority, Bitrate: m.Bitrate, SampleRate: m.SampleRate,
+		RestorePart: m.RestorePart, Lexicon: m.Lexicon, Intro: m.Intro, Outro: m.Outro, Music: m.Music,
+		Metadata: m.Metadata, MaxDuration: m.MaxDuration, SplitBy: m.SplitBy}
 }
@@ -2,11 +2,16 @@ package messages
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewMessageFrom(t *testing.T) {
-	assert.Equal(t, &TTSMessage{SaveRequest: true, RequestID: "rID", Voice: "astra"},
-		NewMessageFrom(&TTSMessage{SaveRequest: true, RequestID: "rID", Voice: "astra"}))
+	assert.Equal(t, &TTSMessage{SaveRequest: true, RequestID: "rID", Voice: "astra", Priority: 10,
+		Bitrate: 64, SampleRate: 8000, Lexicon: "lex", Intro: "i", Outro: "o", Music: "m",
+		Metadata: map[string]string{"title": "t"}, MaxDuration: time.Hour, SplitBy: "chapter"},
+		NewMessageFrom(&TTSMessage{SaveRequest: true, RequestID: "rID", Voice: "astra", Priority: 10,
+			Bitrate: 64, SampleRate: 8000, Lexicon: "lex", Intro: "i", Outro: "o", Music: "m",
+			Metadata: map[string]string{"title": "t"}, MaxDuration: time.Hour, SplitBy: "chapter"}))
 }
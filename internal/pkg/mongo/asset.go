@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"time"
+
+	mng "github.com/airenas/async-api/pkg/mongo"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/status"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mgodr "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Asset provides audio assets persistence
+type Asset struct {
+	SessionProvider *mng.SessionProvider
+}
+
+// NewAsset creates Asset instance
+func NewAsset(sessionProvider *mng.SessionProvider) (*Asset, error) {
+	f := Asset{SessionProvider: sessionProvider}
+	return &f, nil
+}
+
+// Save inserts or replaces the asset
+func (a *Asset) Save(data *persistence.Asset) error {
+	goapp.Log.Infof("Saving asset %s", data.ID)
+
+	c, ctx, cancel, err := mng.NewCollection(a.SessionProvider, AssetTable)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	data.Updated = time.Now()
+	_, err = c.ReplaceOne(ctx, bson.M{"ID": data.ID}, data, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Get loads the asset, returns nil if there is none
+func (a *Asset) Get(id string) (*persistence.Asset, error) {
+	goapp.Log.Infof("Retrieving asset %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(a.SessionProvider, AssetTable)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var res persistence.Asset
+	err = c.FindOne(ctx, bson.M{"ID": mng.Sanitize(id)}).Decode(&res)
+	if err == mgodr.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get asset")
+	}
+	return &res, nil
+}
+
+// Delete removes the asset, returns false if there was none
+func (a *Asset) Delete(id string) (bool, error) {
+	goapp.Log.Infof("Deleting asset %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(a.SessionProvider, AssetTable)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	res, err := c.DeleteOne(ctx, bson.M{"ID": mng.Sanitize(id)})
+	if err != nil {
+		return false, errors.Wrap(err, "can't delete asset")
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// InUse returns true if a not finished job uses the asset: a job without the status is queued,
+// a completed, canceled or failed job is finished
+func (a *Asset) InUse(id string) (bool, error) {
+	goapp.Log.Infof("Checking asset usage %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(a.SessionProvider, RequestTable)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	sid := mng.Sanitize(id)
+	cursor, err := c.Find(ctx, bson.M{"$or": []bson.M{{"intro": sid}, {"outro": sid}, {"music": sid}}},
+		options.Find().SetProjection(bson.M{"ID": 1}))
+	if err != nil {
+		return false, errors.Wrap(err, "can't find requests")
+	}
+	var reqs []persistence.ReqData
+	if err := cursor.All(ctx, &reqs); err != nil {
+		return false, errors.Wrap(err, "can't read requests")
+	}
+	if len(reqs) == 0 {
+		return false, nil
+	}
+	ids := make([]string, len(reqs))
+	for i, r := range reqs {
+		ids[i] = r.ID
+	}
+	finished, err := c.Database().Collection(statusTable).CountDocuments(ctx, bson.M{"ID": bson.M{"$in": ids},
+		"$or": []bson.M{{"status": bson.M{"$in": []string{status.Completed.String(), status.Cancelled.String()}}},
+			{"error": bson.M{"$exists": true}}}})
+	if err != nil {
+		return false, errors.Wrap(err, "can't count statuses")
+	}
+	return finished < int64(len(ids)), nil
+}
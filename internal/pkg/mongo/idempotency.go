@@ -0,0 +1,69 @@
+package mongo
+
+import (
+	"time"
+
+	mng "github.com/airenas/async-api/pkg/mongo"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mgodr "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Idempotency provides idempotency key persistence
+type Idempotency struct {
+	SessionProvider *mng.SessionProvider
+}
+
+// NewIdempotency creates Idempotency instance
+func NewIdempotency(sessionProvider *mng.SessionProvider) (*Idempotency, error) {
+	f := Idempotency{SessionProvider: sessionProvider}
+	return &f, nil
+}
+
+// Reserve maps the key to the ID and the content hash if there is no mapping newer than window,
+// returns the ID and the hash the key is mapped to
+func (i *Idempotency) Reserve(key, id, hash string, window time.Duration) (string, string, error) {
+	goapp.Log.Infof("Reserving idempotency key for %s", id)
+
+	c, ctx, cancel, err := mng.NewCollection(i.SessionProvider, IdempotencyTable)
+	if err != nil {
+		return "", "", err
+	}
+	defer cancel()
+
+	now := time.Now()
+	// updates only an expired mapping, inserts if there is none,
+	// fails on the unique index if a valid mapping exists
+	err = c.FindOneAndUpdate(ctx, bson.M{"key": key, "created": bson.M{"$lt": now.Add(-window)}},
+		bson.M{"$set": bson.M{"ID": id, "hash": hash, "created": now}},
+		options.FindOneAndUpdate().SetUpsert(true)).Err()
+	if err == nil || err == mgodr.ErrNoDocuments {
+		return id, hash, nil
+	}
+	if !mgodr.IsDuplicateKeyError(err) {
+		return "", "", errors.Wrap(err, "can't reserve key")
+	}
+	var res persistence.IdempotencyKey
+	err = c.FindOne(ctx, bson.M{"key": key}).Decode(&res)
+	if err != nil {
+		return "", "", errors.Wrap(err, "can't get key")
+	}
+	return res.ID, res.Hash, nil
+}
+
+// Release removes the mapping of the key to the ID
+func (i *Idempotency) Release(key, id string) error {
+	goapp.Log.Infof("Releasing idempotency key for %s", id)
+
+	c, ctx, cancel, err := mng.NewCollection(i.SessionProvider, IdempotencyTable)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = c.DeleteOne(ctx, bson.M{"key": key, "ID": id})
+	return err
+}
@@ -0,0 +1,77 @@
+package mongo
+
+import (
+	"time"
+
+	mng "github.com/airenas/async-api/pkg/mongo"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mgodr "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Lexicon provides user lexicons persistence
+type Lexicon struct {
+	SessionProvider *mng.SessionProvider
+}
+
+// NewLexicon creates Lexicon instance
+func NewLexicon(sessionProvider *mng.SessionProvider) (*Lexicon, error) {
+	f := Lexicon{SessionProvider: sessionProvider}
+	return &f, nil
+}
+
+// Save inserts or replaces the lexicon
+func (l *Lexicon) Save(data *persistence.Lexicon) error {
+	goapp.Log.Infof("Saving lexicon %s", data.ID)
+
+	c, ctx, cancel, err := mng.NewCollection(l.SessionProvider, LexiconTable)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	data.Updated = time.Now()
+	_, err = c.ReplaceOne(ctx, bson.M{"ID": data.ID}, data, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Get loads the lexicon, returns nil if there is none
+func (l *Lexicon) Get(id string) (*persistence.Lexicon, error) {
+	goapp.Log.Infof("Retrieving lexicon %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(l.SessionProvider, LexiconTable)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var res persistence.Lexicon
+	err = c.FindOne(ctx, bson.M{"ID": mng.Sanitize(id)}).Decode(&res)
+	if err == mgodr.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get lexicon")
+	}
+	return &res, nil
+}
+
+// Delete removes the lexicon, returns false if there was none
+func (l *Lexicon) Delete(id string) (bool, error) {
+	goapp.Log.Infof("Deleting lexicon %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(l.SessionProvider, LexiconTable)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	res, err := c.DeleteOne(ctx, bson.M{"ID": mng.Sanitize(id)})
+	if err != nil {
+		return false, errors.Wrap(err, "can't delete lexicon")
+	}
+	return res.DeletedCount > 0, nil
+}
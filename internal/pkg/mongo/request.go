@@ -38,7 +38,9 @@ func (rm *Request) Save(data *persistence.ReqData) error {
 	err = mng.SkipNoDocErr(c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(data.ID)},
 		bson.M{"$set": bson.M{"email": data.Email, "voice": data.Voice,
 			"speed": data.Speed, "filename": data.Filename, "outputFormat": data.OutputFormat,
-			"saveRequest": data.SaveRequest}},
+			"saveRequest": data.SaveRequest, "callbackURL": data.CallbackURL,
+			"priority": data.Priority, "bitrate": data.Bitrate, "sampleRate": data.SampleRate,
+			"saveTags": data.SaveTags, "requestID": data.RequestID}},
 		options.FindOneAndUpdate().SetUpsert(true)).Err())
 	if err != nil {
 		return err
@@ -77,15 +79,49 @@ func (rm *Request) loadData(id string) (*persistence.ReqData, error) {
 	return &res, nil
 }
 
-//GetEmail returns email by ID
+// Get returns the request by ID, nil if there is no request
+func (rm *Request) Get(id string) (*persistence.ReqData, error) {
+	goapp.Log.Infof("Getting request %s", goapp.Sanitize(id))
+	res, err := rm.loadData(id)
+	if errors.Is(err, mgodr.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.ID = id
+	return res, nil
+}
+
+// SetRequestID updates the request ID used to restore the usage
+func (rm *Request) SetRequestID(id, requestID string) error {
+	goapp.Log.Infof("Setting requestID %s: %s", goapp.Sanitize(id), goapp.Sanitize(requestID))
+
+	c, ctx, cancel, err := mng.NewCollection(rm.SessionProvider, RequestTable)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	return c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(id)},
+		bson.M{"$set": bson.M{"requestID": requestID}}).Err()
+}
+
+//GetEmail returns email by ID, empty if the request has no email
 func (rm *Request) GetEmail(id string) (string, error) {
 	goapp.Log.Infof("Getting email by ID %s", id)
 	m, err := rm.loadData(id)
 	if err != nil {
 		return "", err
 	}
-	if m.Email == "" {
-		return "", errors.New("no email")
-	}
 	return m.Email, nil
 }
+
+//GetCallbackURL returns callback URL by ID, empty if the request has no callback
+func (rm *Request) GetCallbackURL(id string) (string, error) {
+	goapp.Log.Infof("Getting callback URL by ID %s", goapp.Sanitize(id))
+	m, err := rm.loadData(id)
+	if err != nil {
+		return "", err
+	}
+	return m.CallbackURL, nil
+}
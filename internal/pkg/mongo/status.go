@@ -3,6 +3,8 @@ package mongo
 import (
 	mng "github.com/airenas/async-api/pkg/mongo"
 	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/status"
+	"github.com/airenas/big-tts/internal/pkg/utils"
 	"github.com/airenas/go-app/pkg/goapp"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,7 +22,7 @@ func NewStatus(sessionProvider *mng.SessionProvider) (*Status, error) {
 	return &f, nil
 }
 
-// Save saves status to DB
+// Save saves status to DB. A canceled job is not changed, returns utils.ErrJobCanceled then
 func (ss *Status) Save(ID string, st, errStr string) error {
 	goapp.Log.Infof("Saving status %s: %s", ID, st)
 
@@ -40,9 +42,15 @@ func (ss *Status) Save(ID string, st, errStr string) error {
 			bs = bson.M{"error": errStr}
 		}
 	}
-	return mng.SkipNoDocErr(c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(ID)},
+	// the canceled job does not match, so the upsert fails on the unique ID index
+	err = mng.SkipNoDocErr(c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(ID),
+		"status": bson.M{"$ne": status.Cancelled.String()}},
 		bson.M{"$set": bs, "$unset": bu},
 		options.FindOneAndUpdate().SetUpsert(true)).Err())
+	if mongo.IsDuplicateKeyError(err) {
+		return utils.ErrJobCanceled
+	}
+	return err
 }
 
 // Get retrieves status from DB
@@ -62,3 +70,56 @@ func (ss *Status) Get(id string) (*persistence.Status, error) {
 	}
 	return &m, mng.SkipNoDocErr(err)
 }
+
+// IsCanceled checks if the job is canceled
+func (ss *Status) IsCanceled(id string) (bool, error) {
+	m, err := ss.Get(id)
+	if err != nil {
+		return false, err
+	}
+	return m != nil && m.Status == status.Cancelled.String(), nil
+}
+
+// Cancel marks the unfinished job as canceled. Returns the status before the call,
+// nil if there is no job. A completed, canceled or failed job is not changed
+func (ss *Status) Cancel(id string) (*persistence.Status, error) {
+	goapp.Log.Infof("Canceling %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(ss.SessionProvider, statusTable)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var m persistence.Status
+	err = c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(id),
+		"status": bson.M{"$nin": []string{status.Completed.String(), status.Cancelled.String()}},
+		"error":  bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"status": status.Cancelled.String()}}).Decode(&m)
+	if err == mongo.ErrNoDocuments {
+		return ss.Get(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ResetError clears the error of the failed job if the job is still at the stage st.
+// Returns false if the job is not found or changed
+func (ss *Status) ResetError(id, st string) (bool, error) {
+	goapp.Log.Infof("Reset error %s: %s", mng.Sanitize(id), st)
+
+	c, ctx, cancel, err := mng.NewCollection(ss.SessionProvider, statusTable)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	err = c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(id), "status": st, "error": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"error": 1}}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -8,18 +8,34 @@ const (
 	statusTable  = "status"
 	// EmailTable is name for email lock table
 	EmailTable = "emailLock"
+	// UploadSessionTable is a name for resumable upload sessions
+	UploadSessionTable = "uploadSession"
+	// IdempotencyTable is a name for idempotency key to ID mapping
+	IdempotencyTable = "idempotencyKey"
+	// LexiconTable is a name for user lexicons
+	LexiconTable = "lexicon"
+	// AssetTable is a name for audio assets
+	AssetTable = "asset"
 )
 
 // GetIndexes returns indexes for mongo tables
 func GetIndexes() []mng.IndexData {
 	return []mng.IndexData{
 		mng.NewIndexData(RequestTable, "ID", true),
+		mng.NewIndexData(RequestTable, "intro", false),
+		mng.NewIndexData(RequestTable, "outro", false),
+		mng.NewIndexData(RequestTable, "music", false),
 		mng.NewIndexData(statusTable, "ID", true),
 		mng.NewIndexData(EmailTable, "ID", false),
+		mng.NewIndexData(UploadSessionTable, "ID", true),
+		mng.NewIndexData(IdempotencyTable, "key", true),
+		mng.NewIndexData(IdempotencyTable, "ID", false),
+		mng.NewIndexData(LexiconTable, "ID", true),
+		mng.NewIndexData(AssetTable, "ID", true),
 	}
 }
 
-// Tables returns tables for system
+// Tables returns tables with the job data, cleaned by job ID
 func Tables() []string {
-	return []string{RequestTable, statusTable, EmailTable}
+	return []string{RequestTable, statusTable, EmailTable, UploadSessionTable, IdempotencyTable}
 }
@@ -7,5 +7,5 @@ import (
 )
 
 func TestTables(t *testing.T) {
-	assert.Equal(t, []string{"requests", "status", "emailLock"}, Tables())
+	assert.Equal(t, []string{"requests", "status", "emailLock", "uploadSession", "idempotencyKey"}, Tables())
 }
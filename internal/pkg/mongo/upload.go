@@ -0,0 +1,118 @@
+package mongo
+
+import (
+	"time"
+
+	mng "github.com/airenas/async-api/pkg/mongo"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mgodr "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UploadSession provides resumable upload session persistence
+type UploadSession struct {
+	SessionProvider *mng.SessionProvider
+}
+
+// NewUploadSession creates UploadSession instance
+func NewUploadSession(sessionProvider *mng.SessionProvider) (*UploadSession, error) {
+	f := UploadSession{SessionProvider: sessionProvider}
+	return &f, nil
+}
+
+// Create saves new upload session to DB
+func (us *UploadSession) Create(data *persistence.UploadSession) error {
+	goapp.Log.Infof("Creating upload session %s", data.ID)
+
+	c, ctx, cancel, err := mng.NewCollection(us.SessionProvider, UploadSessionTable)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	data.Created = time.Now()
+	_, err = c.InsertOne(ctx, data)
+	return err
+}
+
+// Get loads upload session, returns nil if no session exists
+func (us *UploadSession) Get(id string) (*persistence.UploadSession, error) {
+	goapp.Log.Infof("Retrieving upload session %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(us.SessionProvider, UploadSessionTable)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var res persistence.UploadSession
+	err = c.FindOne(ctx, bson.M{"ID": mng.Sanitize(id)}).Decode(&res)
+	if err == mgodr.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get upload session")
+	}
+	return &res, nil
+}
+
+// AddChunk registers chunk [from, to) saved as file if the session has received exactly from bytes,
+// returns false if the session state does not match
+func (us *UploadSession) AddChunk(id string, from, to int64, file string) (bool, error) {
+	goapp.Log.Infof("Adding chunk %s: %d-%d", mng.Sanitize(id), from, to)
+
+	c, ctx, cancel, err := mng.NewCollection(us.SessionProvider, UploadSessionTable)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	err = c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(id), "received": from, "finished": false},
+		bson.M{"$set": bson.M{"received": to}, "$push": bson.M{"chunks": file}},
+		options.FindOneAndUpdate().SetUpsert(false)).Err()
+	return updated(err)
+}
+
+// Finish marks session as completed, returns false if it was already finished
+func (us *UploadSession) Finish(id string) (bool, error) {
+	goapp.Log.Infof("Finishing upload session %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(us.SessionProvider, UploadSessionTable)
+	if err != nil {
+		return false, err
+	}
+	defer cancel()
+
+	err = c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(id), "finished": false},
+		bson.M{"$set": bson.M{"finished": true}},
+		options.FindOneAndUpdate().SetUpsert(false)).Err()
+	return updated(err)
+}
+
+// Reopen marks session as not finished
+func (us *UploadSession) Reopen(id string) error {
+	goapp.Log.Infof("Reopening upload session %s", mng.Sanitize(id))
+
+	c, ctx, cancel, err := mng.NewCollection(us.SessionProvider, UploadSessionTable)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return mng.SkipNoDocErr(c.FindOneAndUpdate(ctx, bson.M{"ID": mng.Sanitize(id), "finished": true},
+		bson.M{"$set": bson.M{"finished": false}},
+		options.FindOneAndUpdate().SetUpsert(false)).Err())
+}
+
+func updated(err error) (bool, error) {
+	if err == mgodr.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
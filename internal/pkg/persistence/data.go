@@ -14,8 +14,74 @@ type (
 		OutputFormat string
 		Created      time.Time
 		Email        string
-		SaveTags     []string
+		SaveTags     []string `bson:"saveTags,omitempty"`
 		RequestID    string `bson:"requestID,omitempty"`
+		CallbackURL  string `bson:"callbackURL,omitempty"`
+		Priority     int    `bson:"priority"`
+		Bitrate      int    `bson:"bitrate,omitempty"`
+		SampleRate   int    `bson:"sampleRate,omitempty"`
+		Lexicon      string `bson:"lexicon,omitempty"`
+		// Intro, Outro and Music are the IDs of the audio assets
+		Intro    string            `bson:"intro,omitempty"`
+		Outro    string            `bson:"outro,omitempty"`
+		Music    string            `bson:"music,omitempty"`
+		Metadata map[string]string `bson:"metadata,omitempty"`
+		// MaxDuration and SplitBy split the result into several files
+		MaxDuration time.Duration `bson:"maxDuration,omitempty"`
+		SplitBy     string        `bson:"splitBy,omitempty"`
+	}
+
+	//UploadSession keeps resumable upload state
+	UploadSession struct {
+		ID       string    `bson:"ID"`
+		Ext      string    `bson:"ext"`
+		Size     int64     `bson:"size,omitempty"`
+		Received int64     `bson:"received"`
+		Chunks   []string  `bson:"chunks,omitempty"`
+		Request  *ReqData  `bson:"request"`
+		Created  time.Time `bson:"created"`
+		Finished bool      `bson:"finished"`
+	}
+
+	//IdempotencyKey maps a client provided key to the job ID
+	IdempotencyKey struct {
+		Key     string    `bson:"key"`
+		ID      string    `bson:"ID"`
+		Hash    string    `bson:"hash,omitempty"`
+		Created time.Time `bson:"created"`
+	}
+
+	//Lexicon is a user pronunciation dictionary
+	Lexicon struct {
+		ID string `bson:"ID"`
+		// Scope is the owner's scope, the lexicon is usable only in the same scope
+		Scope string `bson:"scope"`
+		// Tag shares the lexicon with all requests having the save tag instead of the scope
+		Tag     string         `bson:"tag,omitempty"`
+		Name    string         `bson:"name,omitempty"`
+		Entries []LexiconEntry `bson:"entries"`
+		Updated time.Time      `bson:"updated"`
+	}
+
+	//LexiconEntry describes how to pronounce the word
+	LexiconEntry struct {
+		Word string `bson:"word"`
+		// Text replaces the word
+		Text      string `bson:"text,omitempty"`
+		Accented  string `bson:"acc,omitempty"`
+		Syllables string `bson:"syll,omitempty"`
+		User      string `bson:"user,omitempty"`
+	}
+
+	//Asset is a reusable audio clip: an intro, an outro or a background music
+	Asset struct {
+		ID string `bson:"ID"`
+		// Scope and Tag limit the usage the same way as for the Lexicon
+		Scope   string    `bson:"scope"`
+		Tag     string    `bson:"tag,omitempty"`
+		Name    string    `bson:"name,omitempty"`
+		Ext     string    `bson:"ext"`
+		Updated time.Time `bson:"updated"`
 	}
 
 	//Status information table
@@ -1,15 +1,23 @@
 package result
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"io"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/facebookgo/grace/gracehttp"
 	"github.com/pkg/errors"
 
 	"github.com/airenas/async-api/pkg/api"
+	"github.com/airenas/big-tts/internal/pkg/audio"
+	"github.com/airenas/big-tts/internal/pkg/joiner"
+	"github.com/airenas/big-tts/internal/pkg/subtitles"
 	"github.com/airenas/go-app/pkg/goapp"
 
 	"github.com/labstack/echo-contrib/prometheus"
@@ -96,6 +104,13 @@ func live(data *Data) func(echo.Context) error {
 	}
 }
 
+const (
+	// typeManifest returns the list of the split result files
+	typeManifest = "manifest"
+	// typeZip returns all the split result files in one archive
+	typeZip = "zip"
+)
+
 func download(data *Data) func(echo.Context) error {
 	return func(c echo.Context) error {
 		defer goapp.Estimate("download method")()
@@ -104,13 +119,42 @@ func download(data *Data) func(echo.Context) error {
 		if id == "" {
 			return echo.NewHTTPError(http.StatusBadRequest, "No ID")
 		}
+		typ := c.QueryParam("type")
+		if typ != "" && typ != typeManifest && typ != typeZip && subtitles.ContentType(typ) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Wrong type")
+		}
+		part := 0
+		if ps := c.QueryParam("part"); ps != "" {
+			var err error
+			if part, err = strconv.Atoi(ps); err != nil || part < 1 {
+				return echo.NewHTTPError(http.StatusBadRequest, "Wrong part")
+			}
+		}
 		fileName, err := data.NameProvider.GetResultFile(id)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, "No file by ID")
 		}
+		// subtitles and the split result files are saved next to the audio
+		dir, ext := filepath.Dir(fileName), filepath.Ext(fileName)
+		switch {
+		case typ == typeZip:
+			return serveZip(c, data, dir)
+		case typ == typeManifest:
+			fileName = filepath.Join(dir, joiner.ManifestFile)
+		case part > 0:
+			fileName = filepath.Join(dir, joiner.PartName(part, strings.TrimPrefix(ext, ".")))
+		case typ != "":
+			fileName = strings.TrimSuffix(fileName, ext) + "." + typ
+		}
 		file, err := data.Reader.Load(fileName)
 		if err != nil {
 			goapp.Log.Error(err)
+			if typ == typeManifest || part > 0 {
+				return echo.NewHTTPError(http.StatusNotFound, "No split result")
+			}
+			if typ != "" {
+				return echo.NewHTTPError(http.StatusNotFound, "No subtitles")
+			}
 			return echo.NewHTTPError(http.StatusInternalServerError, "Can't get file")
 		}
 		defer file.Close()
@@ -123,7 +167,80 @@ func download(data *Data) func(echo.Context) error {
 
 		w := c.Response()
 		w.Header().Set("Content-Disposition", "attachment; filename="+fileInfo.Name())
+		if ct := audio.ContentType(filepath.Ext(fileInfo.Name())); ct != "" {
+			w.Header().Set(echo.HeaderContentType, ct)
+		} else if ct := subtitles.ContentType(typ); ct != "" {
+			w.Header().Set(echo.HeaderContentType, ct)
+		} else if typ == typeManifest {
+			w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		}
 		http.ServeContent(w, c.Request(), fileInfo.Name(), fileInfo.ModTime(), file)
 		return nil
 	}
 }
+
+// serveZip writes the manifest and the files listed in it as a zip archive
+func serveZip(c echo.Context, data *Data, dir string) error {
+	m, err := loadManifest(data, filepath.Join(dir, joiner.ManifestFile))
+	if err != nil {
+		goapp.Log.Error(err)
+		return echo.NewHTTPError(http.StatusNotFound, "No split result")
+	}
+	w := c.Response()
+	w.Header().Set("Content-Disposition", "attachment; filename=result.zip")
+	w.Header().Set(echo.HeaderContentType, "application/zip")
+	w.WriteHeader(http.StatusOK)
+	if c.Request().Method == http.MethodHead {
+		return nil
+	}
+	zw := zip.NewWriter(w)
+	names := []string{joiner.ManifestFile}
+	for _, f := range m.Files {
+		names = append(names, f.Name)
+	}
+	for _, n := range names {
+		if err := addToZip(zw, data, filepath.Join(dir, n), n); err != nil {
+			// the status is already sent, the client gets a broken archive
+			goapp.Log.Error(err)
+			return nil
+		}
+	}
+	if err := zw.Close(); err != nil {
+		goapp.Log.Error(errors.Wrap(err, "can't finish zip"))
+	}
+	return nil
+}
+
+func loadManifest(data *Data, fileName string) (*joiner.Manifest, error) {
+	file, err := data.Reader.Load(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var res joiner.Manifest
+	if err := json.NewDecoder(file).Decode(&res); err != nil {
+		return nil, errors.Wrapf(err, "can't decode %s", fileName)
+	}
+	return &res, nil
+}
+
+// addToZip stores the file as is, the audio is compressed already
+func addToZip(zw *zip.Writer, data *Data, fileName, name string) error {
+	file, err := data.Reader.Load(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	h := &zip.FileHeader{Name: name, Method: zip.Store}
+	if fi, err := file.Stat(); err == nil {
+		h.Modified = fi.ModTime()
+	}
+	zf, err := zw.CreateHeader(h)
+	if err != nil {
+		return errors.Wrapf(err, "can't add %s to zip", name)
+	}
+	if _, err := io.Copy(zf, file); err != nil {
+		return errors.Wrapf(err, "can't write %s to zip", name)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package result
 
 import (
+	"archive/zip"
+	"bytes"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -85,6 +87,50 @@ func Test_Returns(t *testing.T) {
 	assert.Equal(t, "olia", string(bytes))
 }
 
+func Test_Returns_ContentType(t *testing.T) {
+	initTest(t)
+
+	tf, err := os.CreateTemp("", "result*.ogg")
+	_, _ = tf.WriteString("olia")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tf.Name())
+
+	pegomock.When(readerMock.Load(pegomock.Any[string]())).ThenReturn(tf, nil)
+	req := httptest.NewRequest(http.MethodGet, "/result/1", nil)
+	resp := testCode(t, req, 200)
+	assert.Equal(t, "audio/ogg", resp.Header().Get(echo.HeaderContentType))
+}
+
+func Test_Returns_Subtitles(t *testing.T) {
+	initTest(t)
+
+	tf, err := os.CreateTemp("", "result*.vtt")
+	_, _ = tf.WriteString("WEBVTT")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tf.Name())
+
+	pegomock.When(nProviderMock.GetResultFile(pegomock.Any[string]())).ThenReturn("1/result/result.mp3", nil)
+	pegomock.When(readerMock.Load(pegomock.Any[string]())).ThenReturn(tf, nil)
+	req := httptest.NewRequest(http.MethodGet, "/result/1?type=vtt", nil)
+	resp := testCode(t, req, 200)
+	assert.Equal(t, "text/vtt", resp.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "1/result/result.vtt", readerMock.VerifyWasCalledOnce().Load(pegomock.Any[string]()).GetCapturedArguments())
+}
+
+func Test_Fails_Type(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodGet, "/result/1?type=txt", nil)
+	testCode(t, req, http.StatusBadRequest)
+}
+
+func Test_Fails_NoSubtitles(t *testing.T) {
+	initTest(t)
+	pegomock.When(nProviderMock.GetResultFile(pegomock.Any[string]())).ThenReturn("1/result/result.mp3", nil)
+	pegomock.When(readerMock.Load(pegomock.Any[string]())).ThenReturn(nil, errors.New("err"))
+	req := httptest.NewRequest(http.MethodGet, "/result/1?type=srt", nil)
+	testCode(t, req, http.StatusNotFound)
+}
+
 func Test_404(t *testing.T) {
 	initTest(t)
 	req := httptest.NewRequest(http.MethodGet, "/result/", nil)
@@ -117,3 +163,77 @@ func testCode(t *testing.T, req *http.Request, code int) *httptest.ResponseRecor
 	assert.Equal(t, code, tResp.Code)
 	return tResp
 }
+
+func newTestFile(t *testing.T, pattern, data string) *os.File {
+	t.Helper()
+	tf, err := os.CreateTemp("", pattern)
+	assert.Nil(t, err)
+	_, _ = tf.WriteString(data)
+	_, _ = tf.Seek(0, io.SeekStart)
+	t.Cleanup(func() { os.RemoveAll(tf.Name()) })
+	return tf
+}
+
+func Test_Returns_Part(t *testing.T) {
+	initTest(t)
+	pegomock.When(nProviderMock.GetResultFile(pegomock.Any[string]())).ThenReturn("1/result/result.mp3", nil)
+	pegomock.When(readerMock.Load(pegomock.Any[string]())).ThenReturn(newTestFile(t, "result_002*.mp3", "olia"), nil)
+	req := httptest.NewRequest(http.MethodGet, "/result/1?part=2", nil)
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, "audio/mpeg", resp.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "1/result/result_002.mp3", readerMock.VerifyWasCalledOnce().Load(pegomock.Any[string]()).GetCapturedArguments())
+}
+
+func Test_Returns_Manifest(t *testing.T) {
+	initTest(t)
+	pegomock.When(nProviderMock.GetResultFile(pegomock.Any[string]())).ThenReturn("1/result/result.mp3", nil)
+	pegomock.When(readerMock.Load(pegomock.Any[string]())).ThenReturn(newTestFile(t, "manifest*.json", `{"files":[]}`), nil)
+	req := httptest.NewRequest(http.MethodGet, "/result/1?type=manifest", nil)
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, echo.MIMEApplicationJSON, resp.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "1/result/manifest.json", readerMock.VerifyWasCalledOnce().Load(pegomock.Any[string]()).GetCapturedArguments())
+}
+
+func Test_Returns_Zip(t *testing.T) {
+	initTest(t)
+	pegomock.When(nProviderMock.GetResultFile(pegomock.Any[string]())).ThenReturn("1/result/result.mp3", nil)
+	manifest := `{"files":[{"name":"result_001.mp3","start":0,"duration":1},{"name":"result_002.mp3","start":1,"duration":1}]}`
+	pegomock.When(readerMock.Load(pegomock.Eq("1/result/manifest.json"))).ThenReturn(
+		newTestFile(t, "manifest*.json", manifest), nil).ThenReturn(newTestFile(t, "manifest*.json", manifest), nil)
+	pegomock.When(readerMock.Load(pegomock.Eq("1/result/result_001.mp3"))).ThenReturn(newTestFile(t, "r1*.mp3", "olia1"), nil)
+	pegomock.When(readerMock.Load(pegomock.Eq("1/result/result_002.mp3"))).ThenReturn(newTestFile(t, "r2*.mp3", "olia2"), nil)
+	req := httptest.NewRequest(http.MethodGet, "/result/1?type=zip", nil)
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, "application/zip", resp.Header().Get(echo.HeaderContentType))
+	b := resp.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	assert.Nil(t, err)
+	got := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.Nil(t, err)
+		d, _ := io.ReadAll(r)
+		got[f.Name] = string(d)
+	}
+	assert.Equal(t, map[string]string{"manifest.json": manifest, "result_001.mp3": "olia1", "result_002.mp3": "olia2"}, got)
+}
+
+func Test_Fails_NoSplit(t *testing.T) {
+	for _, q := range []string{"type=zip", "type=manifest", "part=1"} {
+		t.Run(q, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(nProviderMock.GetResultFile(pegomock.Any[string]())).ThenReturn("1/result/result.mp3", nil)
+			pegomock.When(readerMock.Load(pegomock.Any[string]())).ThenReturn(nil, errors.New("err"))
+			testCode(t, httptest.NewRequest(http.MethodGet, "/result/1?"+q, nil), http.StatusNotFound)
+		})
+	}
+}
+
+func Test_Fails_Part(t *testing.T) {
+	for _, q := range []string{"part=0", "part=a", "part=-1"} {
+		t.Run(q, func(t *testing.T) {
+			initTest(t)
+			testCode(t, httptest.NewRequest(http.MethodGet, "/result/1?"+q, nil), http.StatusBadRequest)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package splitter
+
+import (
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ChaptersFile is a name of the file in the split dir with the chapters list
+const ChaptersFile = "chapters.json"
+
+// Chapter marks the part a chapter starts at
+type Chapter struct {
+	Title string `json:"title"`
+	Part  int    `json:"part"`
+}
+
+// chapterText is a text of one chapter, the text before the first chapter is not a chapter
+type chapterText struct {
+	chapter bool
+	title   string
+	text    string
+	// offset of the text in the original text, for SSML the root tag is prepended
+	offset  int
+	rootLen int
+}
+
+// chapterLine matches the chapter syntax of a plain text: a line [chapter=Title]
+var chapterLine = regexp.MustCompile(`(?im)^[ \t]*\[chapter[ \t]*=[ \t]*([^\]\n]*?)[ \t]*\][ \t]*\r?$`)
+
+// splitChapters cuts the text into chapters by <mark name="Title"/> for SSML or by [chapter=Title] lines
+func splitChapters(text string) []chapterText {
+	if IsSSML(text) {
+		return splitSSMLChapters(text)
+	}
+	return splitTextChapters(text)
+}
+
+func splitTextChapters(text string) []chapterText {
+	locs := chapterLine.FindAllStringSubmatchIndex(text, -1)
+	if len(locs) == 0 {
+		return []chapterText{{text: text}}
+	}
+	var res []chapterText
+	add := func(chapter bool, title string, from, to int) {
+		if strings.TrimSpace(text[from:to]) != "" {
+			res = append(res, chapterText{chapter: chapter, title: title, text: text[from:to], offset: from})
+		}
+	}
+	add(false, "", 0, locs[0][0])
+	for i, l := range locs {
+		to := len(text)
+		if i+1 < len(locs) {
+			to = locs[i+1][0]
+		}
+		add(true, strings.TrimSpace(text[l[2]:l[3]]), l[1], to)
+	}
+	return res
+}
+
+type markPos struct {
+	title    string
+	from, to int
+}
+
+// splitSSMLChapters cuts SSML by top level <mark/> tags, each chapter is wrapped by the root <speak> tag.
+// Invalid XML or a nested mark leaves the text as is, so the SSML parser reports the error
+func splitSSMLChapters(text string) []chapterText {
+	whole := []chapterText{{text: text}}
+	if !strings.Contains(text, "<mark") {
+		return whole
+	}
+	d := xml.NewDecoder(strings.NewReader(text))
+	depth, contentFrom, contentTo := 0, -1, len(text)
+	var marks []markPos
+	for {
+		prev := int(d.InputOffset())
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return whole
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				contentFrom = int(d.InputOffset())
+			}
+			if t.Name.Local == "mark" {
+				if depth != 2 {
+					return whole
+				}
+				marks = append(marks, markPos{title: strings.TrimSpace(attrValue(t, "name")), from: prev})
+			}
+		case xml.EndElement:
+			if t.Name.Local == "mark" && depth == 2 {
+				marks[len(marks)-1].to = int(d.InputOffset())
+			}
+			if depth == 1 {
+				contentTo = prev
+			}
+			depth--
+		}
+	}
+	if len(marks) == 0 || contentFrom < 0 {
+		return whole
+	}
+	root := text[:contentFrom]
+	var res []chapterText
+	add := func(chapter bool, title string, from, to int) {
+		if strings.TrimSpace(text[from:to]) != "" {
+			res = append(res, chapterText{chapter: chapter, title: title, text: root + text[from:to] + "</speak>",
+				offset: from, rootLen: len(root)})
+		}
+	}
+	add(false, "", contentFrom, marks[0].from)
+	for i, m := range marks {
+		to := contentTo
+		if i+1 < len(marks) {
+			to = marks[i+1].from
+		}
+		add(true, m.title, m.to, to)
+	}
+	return res
+}
+
+// originalPos maps the position in the chapter text to the original text
+func (ch *chapterText) originalPos(pos int, text string) int {
+	if pos < ch.rootLen {
+		return pos
+	}
+	res := ch.offset + pos - ch.rootLen
+	if res > len(text) {
+		return len(text)
+	}
+	return res
+}
+
+func attrValue(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
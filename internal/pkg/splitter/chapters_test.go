@@ -0,0 +1,104 @@
+package splitter
+
+import (
+	"context"
+	"testing"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitChapters(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want []chapterText
+	}{
+		{name: "no chapters", args: "olia olia", want: []chapterText{{text: "olia olia"}}},
+		{name: "text", args: "intro\n[chapter=One]\nolia\n  [Chapter = Two ] \nolia2",
+			want: []chapterText{{text: "intro\n"}, {chapter: true, title: "One", text: "\nolia\n", offset: 19},
+				{chapter: true, title: "Two", text: "\nolia2", offset: 44}}},
+		{name: "text first", args: "[chapter=One]\nolia",
+			want: []chapterText{{chapter: true, title: "One", text: "\nolia", offset: 13}}},
+		{name: "text inline", args: "olia [chapter=One] olia", want: []chapterText{{text: "olia [chapter=One] olia"}}},
+		{name: "ssml no marks", args: "<speak>olia</speak>", want: []chapterText{{text: "<speak>olia</speak>"}}},
+		{name: "ssml", args: `<speak>intro<mark name="One"/>olia<p/><mark name=" Two "></mark>olia2</speak>`,
+			want: []chapterText{{text: "<speak>intro</speak>", offset: 7, rootLen: 7},
+				{chapter: true, title: "One", text: "<speak>olia<p/></speak>", offset: 30, rootLen: 7},
+				{chapter: true, title: "Two", text: "<speak>olia2</speak>", offset: 64, rootLen: 7}}},
+		{name: "ssml empty chapter", args: `<speak><mark name="One"/> <mark name="Two"/>olia</speak>`,
+			want: []chapterText{{chapter: true, title: "Two", text: "<speak>olia</speak>", offset: 44, rootLen: 7}}},
+		{name: "ssml nested mark", args: `<speak><p><mark name="One"/>olia</p></speak>`,
+			want: []chapterText{{text: `<speak><p><mark name="One"/>olia</p></speak>`}}},
+		{name: "ssml invalid", args: `<speak><mark name="One"/>olia</spek>`,
+			want: []chapterText{{text: `<speak><mark name="One"/>olia</spek>`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitChapters(tt.args))
+		})
+	}
+}
+
+func TestWorker_split_Chapters(t *testing.T) {
+	w := &Worker{wantedChars: 20}
+	got, chapters, err := w.split("intro\n[chapter=One]\n0123456789 0123456789 0123456789\n[chapter=]\nolia", "vd", 1, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"intro\n", "\n0123456789 0123456789", " 0123456789\n", "\nolia"}, got)
+	assert.Equal(t, []Chapter{{Title: "One", Part: 1}, {Title: "Chapter 2", Part: 3}}, chapters)
+}
+
+func TestWorker_Do_Chapters(t *testing.T) {
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new/{}")
+	assert.Nil(t, err)
+	got.loadFunc = func(s string) ([]byte, error) {
+		return []byte(`<speak><mark name="One"/>olia<mark name="Two"/>olia2</speak>`), nil
+	}
+	saved := map[string]string{}
+	got.saveFunc = func(s string, b []byte) error {
+		saved[s] = string(b)
+		return nil
+	}
+	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, Voice: "vd", Speed: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(saved))
+	assert.Equal(t, `[{"title":"One","part":0},{"title":"Two","part":1}]`, saved["new/id1/chapters.json"])
+}
+
+func TestWorker_Do_NoChapters(t *testing.T) {
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new/{}")
+	assert.Nil(t, err)
+	got.loadFunc = func(s string) ([]byte, error) {
+		return []byte("olia"), nil
+	}
+	saved := map[string]string{}
+	got.saveFunc = func(s string, b []byte) error {
+		saved[s] = string(b)
+		return nil
+	}
+	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"new/id1/0000.txt": "olia"}, saved)
+}
+
+func TestValidateSSML_Chapters(t *testing.T) {
+	assert.Nil(t, ValidateSSML(`<speak>olia<mark name="One"/>olia</speak>`))
+	err := ValidateSSML("<speak>olia<mark name=\"One\"/>\nolia<foo/></speak>")
+	if assert.NotNil(t, err) {
+		se, ok := err.(*SSMLError)
+		assert.True(t, ok)
+		assert.Equal(t, 2, se.Line)
+		assert.Equal(t, 5, se.Column)
+		assert.Equal(t, "<foo/>", se.Tag)
+	}
+}
+
+func TestEstimator_Estimate_Chapters(t *testing.T) {
+	e := NewEstimator()
+	got, err := e.Estimate("[chapter=One]\nolia\n[chapter=Two]\nolia", "vd", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, got.Parts)
+	assert.Equal(t, 2, got.Chapters)
+}
@@ -0,0 +1,89 @@
+package splitter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Estimation keeps split statistics of a text
+type Estimation struct {
+	Chars    int
+	Parts    int
+	Chapters int
+	Pauses   time.Duration
+	Warnings []string
+}
+
+// Estimator splits text the same way as the Worker does, but saves nothing
+type Estimator struct {
+	w *Worker
+}
+
+// NewEstimator creates estimator instance
+func NewEstimator() *Estimator {
+	return &Estimator{w: &Worker{wantedChars: defaultWantedChars, segmenter: NewSegmenter(DefaultAbbreviations)}}
+}
+
+// SetAbbreviations adds the abbreviations to the default list, see Worker.SetAbbreviations
+func (e *Estimator) SetAbbreviations(abbreviations []string) {
+	e.w.SetAbbreviations(abbreviations)
+}
+
+// SetPauses sets the pauses added to a plain text, see Worker.SetPauses
+func (e *Estimator) SetPauses(p Pauses) error {
+	return e.w.SetPauses(p)
+}
+
+// Estimate splits text and returns statistics.
+// A place where the text is cut in the middle of a word is reported as a warning
+func (e *Estimator) Estimate(text string, voice string, speed float64) (*Estimation, error) {
+	st := &stats{}
+	texts, chapters, err := e.w.split(text, voice, speed, nil, st)
+	if err != nil {
+		return nil, err
+	}
+	return &Estimation{Chars: st.chars, Parts: len(texts), Chapters: len(chapters), Pauses: st.pauses,
+		Warnings: st.warnings}, nil
+}
+
+// stats collects split info, nil value collects nothing
+type stats struct {
+	chars    int
+	pauses   time.Duration
+	warnings []string
+	// done is a count of parts of the previous chapters
+	done int
+}
+
+func (st *stats) addChars(c int) {
+	if st != nil {
+		st.chars += c
+	}
+}
+
+func (st *stats) setDone(c int) {
+	if st != nil {
+		st.done = c
+	}
+}
+
+func (st *stats) addPause(d time.Duration) {
+	if st != nil {
+		st.pauses += d
+	}
+}
+
+func (st *stats) addForcedCut(rns []rune, pos, part int) {
+	if st != nil {
+		st.warnings = append(st.warnings, fmt.Sprintf("part %d: no boundary found, forced cut, text: '...%s'",
+			st.done+part+1, snippet(rns, pos)))
+	}
+}
+
+func snippet(rns []rune, to int) string {
+	from := to - 30
+	if from < 0 {
+		from = 0
+	}
+	return string(rns[from:to])
+}
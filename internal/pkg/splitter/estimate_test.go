@@ -0,0 +1,45 @@
+package splitter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimator_Estimate(t *testing.T) {
+	e := NewEstimator()
+	e.w.wantedChars = 20
+	got, err := e.Estimate("0123456789 0123456789 0123456789", "vd", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &Estimation{Chars: 32, Parts: 2}, got)
+}
+
+func TestEstimator_Estimate_SSML(t *testing.T) {
+	e := NewEstimator()
+	e.w.wantedChars = 20
+	got, err := e.Estimate("<speak>0123456789 0123456789 0123456789<p/></speak>", "vd", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, got.Parts)
+	assert.Equal(t, 1250*time.Millisecond, got.Pauses)
+	assert.Empty(t, got.Warnings)
+}
+
+func TestEstimator_Estimate_Warns(t *testing.T) {
+	e := NewEstimator()
+	e.w.wantedChars = 20
+	got, err := e.Estimate(strings.Repeat("0123456789", 4), "vd", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 40, got.Chars)
+	assert.Equal(t, 2, got.Parts)
+	if assert.Equal(t, 1, len(got.Warnings)) {
+		assert.Contains(t, got.Warnings[0], "part 1: no boundary found, forced cut")
+	}
+}
+
+func TestEstimator_Estimate_Fails(t *testing.T) {
+	e := NewEstimator()
+	_, err := e.Estimate("<speak><intelektika:w acc=\"oli{a/}\" syll=\"oo-lia\">olia</intelektika:w></speak>", "vd", 1)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,104 @@
+package splitter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/tts-line/pkg/ssml"
+	"github.com/pkg/errors"
+)
+
+// MaxLexiconEntries is the max number of words in one lexicon
+const MaxLexiconEntries = 10000
+
+// LexiconLoader loads user lexicons
+type LexiconLoader interface {
+	// Get returns nil if there is no lexicon
+	Get(id string) (*persistence.Lexicon, error)
+}
+
+// lexicon maps a lower case word to its pronunciation
+type lexicon map[string]*persistence.LexiconEntry
+
+var wordRegexp = regexp.MustCompile(`[\p{L}\p{N}]+(?:['’-][\p{L}\p{N}]+)*`)
+
+// ValidateLexicon checks the lexicon entries: one word per entry, no duplicates,
+// each entry has a replacement text, an accented form or a user pronunciation
+func ValidateLexicon(entries []persistence.LexiconEntry) error {
+	if len(entries) == 0 {
+		return errors.New("no entries")
+	}
+	if len(entries) > MaxLexiconEntries {
+		return errors.Errorf("too many entries %d, max %d", len(entries), MaxLexiconEntries)
+	}
+	words := map[string]bool{}
+	for i, e := range entries {
+		if !isWord(e.Word) {
+			return errors.Errorf("entry %d: wrong word '%s'", i+1, e.Word)
+		}
+		if e.Text == "" && e.Accented == "" && e.User == "" {
+			return errors.Errorf("entry %d: no pronunciation for '%s'", i+1, e.Word)
+		}
+		if e.Syllables != "" && e.Accented == "" {
+			return errors.Errorf("entry %d: syllables without accented form for '%s'", i+1, e.Word)
+		}
+		if e.Text != "" && strings.ContainsAny(e.Text, "<>&") {
+			return errors.Errorf("entry %d: wrong text '%s'", i+1, e.Text)
+		}
+		w := strings.ToLower(e.Word)
+		if words[w] {
+			return errors.Errorf("entry %d: duplicate word '%s'", i+1, e.Word)
+		}
+		words[w] = true
+	}
+	return nil
+}
+
+func isWord(s string) bool {
+	loc := wordRegexp.FindStringIndex(s)
+	return loc != nil && loc[0] == 0 && loc[1] == len(s)
+}
+
+func newLexicon(l *persistence.Lexicon) lexicon {
+	res := lexicon{}
+	for i := range l.Entries {
+		res[strings.ToLower(l.Entries[i].Word)] = &l.Entries[i]
+	}
+	return res
+}
+
+// apply cuts the plain text parts at the lexicon words and marks the words with the pronunciation
+func (l lexicon) apply(texts []ssml.TextPart) []ssml.TextPart {
+	if len(l) == 0 {
+		return texts
+	}
+	res := make([]ssml.TextPart, 0, len(texts))
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, ssml.TextPart{Text: s})
+		}
+	}
+	for _, tp := range texts {
+		if tp.Accented != "" || tp.Syllables != "" || tp.UserOEPal != "" {
+			res = append(res, tp)
+			continue
+		}
+		from := 0
+		for _, loc := range wordRegexp.FindAllStringIndex(tp.Text, -1) {
+			e, ok := l[strings.ToLower(tp.Text[loc[0]:loc[1]])]
+			if !ok {
+				continue
+			}
+			add(tp.Text[from:loc[0]])
+			word := tp.Text[loc[0]:loc[1]]
+			if e.Text != "" {
+				word = e.Text
+			}
+			res = append(res, ssml.TextPart{Text: word, Accented: e.Accented, Syllables: e.Syllables, UserOEPal: e.User})
+			from = loc[1]
+		}
+		add(tp.Text[from:])
+	}
+	return res
+}
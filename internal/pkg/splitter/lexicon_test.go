@@ -0,0 +1,153 @@
+package splitter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/airenas/tts-line/pkg/ssml"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLexicons struct {
+	l   *persistence.Lexicon
+	err error
+}
+
+func (t *testLexicons) Get(id string) (*persistence.Lexicon, error) {
+	return t.l, t.err
+}
+
+func TestValidateLexicon(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []persistence.LexiconEntry
+		wantErr bool
+	}{
+		{name: "OK", args: []persistence.LexiconEntry{{Word: "NATO", Text: "nato"}, {Word: "olia", Accented: "oli{a/}", Syllables: "o-li-a"},
+			{Word: "Vilnius-Kaunas", User: "v'i:l'n'u"}}},
+		{name: "Empty", args: []persistence.LexiconEntry{}, wantErr: true},
+		{name: "Too many", args: make([]persistence.LexiconEntry, MaxLexiconEntries+1), wantErr: true},
+		{name: "No word", args: []persistence.LexiconEntry{{Text: "nato"}}, wantErr: true},
+		{name: "Two words", args: []persistence.LexiconEntry{{Word: "olia olia", Text: "nato"}}, wantErr: true},
+		{name: "No pronunciation", args: []persistence.LexiconEntry{{Word: "olia"}}, wantErr: true},
+		{name: "Syllables only", args: []persistence.LexiconEntry{{Word: "olia", Syllables: "o-li-a"}}, wantErr: true},
+		{name: "Wrong text", args: []persistence.LexiconEntry{{Word: "olia", Text: "<olia>"}}, wantErr: true},
+		{name: "Duplicate", args: []persistence.LexiconEntry{{Word: "olia", Text: "a"}, {Word: "Olia", Text: "b"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLexicon(tt.args)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+func Test_lexicon_apply(t *testing.T) {
+	l := newLexicon(&persistence.Lexicon{Entries: []persistence.LexiconEntry{{Word: "NATO", Text: "nato"},
+		{Word: "olia", Accented: "oli{a/}", Syllables: "o-li-a"}, {Word: "ąžuolas", User: "a:Zuolas"}}})
+	tests := []struct {
+		name string
+		args []ssml.TextPart
+		want []ssml.TextPart
+	}{
+		{name: "No words", args: []ssml.TextPart{{Text: "labas rytas"}}, want: []ssml.TextPart{{Text: "labas rytas"}}},
+		{name: "Replaces", args: []ssml.TextPart{{Text: "labas nato, Olia ir ąžuolas."}},
+			want: []ssml.TextPart{{Text: "labas"}, {Text: "nato"}, {Text: ","}, {Text: "Olia", Accented: "oli{a/}", Syllables: "o-li-a"},
+				{Text: "ir"}, {Text: "ąžuolas", UserOEPal: "a:Zuolas"}, {Text: "."}}},
+		{name: "Only word", args: []ssml.TextPart{{Text: "NATO"}}, want: []ssml.TextPart{{Text: "nato"}}},
+		{name: "Not a part of word", args: []ssml.TextPart{{Text: "oliaolia olia-olia"}},
+			want: []ssml.TextPart{{Text: "oliaolia olia-olia"}}},
+		{name: "Keeps marked", args: []ssml.TextPart{{Text: "olia", Accented: "o{/}lia"}},
+			want: []ssml.TextPart{{Text: "olia", Accented: "o{/}lia"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, l.apply(tt.args))
+		})
+	}
+}
+
+func Test_lexicon_apply_Empty(t *testing.T) {
+	var l lexicon
+	assert.Equal(t, []ssml.TextPart{{Text: "olia"}}, l.apply([]ssml.TextPart{{Text: "olia"}}))
+}
+
+func newTestLexiconWorker(t *testing.T, text string) (*Worker, map[string]string) {
+	t.Helper()
+	res, err := NewWorker(&storage.Local{}, "{}.txt", "new/{}")
+	assert.Nil(t, err)
+	res.loadFunc = func(s string) ([]byte, error) {
+		return []byte(text), nil
+	}
+	saved := map[string]string{}
+	res.saveFunc = func(s string, b []byte) error {
+		saved[s] = string(b)
+		return nil
+	}
+	return res, saved
+}
+
+func TestWorker_Do_Lexicon(t *testing.T) {
+	w, saved := newTestLexiconWorker(t, "Labas NATO")
+	lex := &testLexicons{l: &persistence.Lexicon{Entries: []persistence.LexiconEntry{{Word: "nato", Accented: "n{a/}to"}}}}
+	w.SetLexicons(lex)
+	err := w.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, Voice: "vd",
+		Speed: 1, Lexicon: "l1"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"new/id1/0000.txt": `<speak><voice name="vd"><prosody rate="100%">Labas` +
+		`<intelektika:w acc="n{a/}to">NATO</intelektika:w></prosody></voice></speak>`}, saved)
+}
+
+func TestWorker_Do_LexiconSSML(t *testing.T) {
+	w, saved := newTestLexiconWorker(t, `<speak>Labas NATO<intelektika:w acc="n{a/}to">NATO</intelektika:w></speak>`)
+	lex := &testLexicons{l: &persistence.Lexicon{Entries: []persistence.LexiconEntry{{Word: "nato", User: "na:to"}}}}
+	w.SetLexicons(lex)
+	err := w.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, Voice: "vd",
+		Speed: 1, Lexicon: "l1"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"new/id1/0000.txt": `<speak><voice name="vd"><prosody rate="100%">Labas` +
+		`<intelektika:w user="na:to">NATO</intelektika:w><intelektika:w acc="n{a/}to">NATO</intelektika:w>` +
+		`</prosody></voice></speak>`}, saved)
+}
+
+func TestWorker_Do_LexiconLong(t *testing.T) {
+	w, saved := newTestLexiconWorker(t, strings.Repeat("ąžuolas olia. ", 10))
+	w.wantedChars = 40
+	w.SetLexicons(&testLexicons{l: &persistence.Lexicon{Entries: []persistence.LexiconEntry{{Word: "olia", Text: "alia"}}}})
+	err := w.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, Voice: "vd",
+		Speed: 1, Lexicon: "l1"})
+	assert.Nil(t, err)
+	assert.True(t, len(saved) > 1)
+	for _, s := range saved {
+		assert.Nil(t, ValidateSSML(s), s)
+		assert.NotContains(t, s, "olia")
+	}
+}
+
+func TestWorker_Do_LexiconFail(t *testing.T) {
+	tests := []struct {
+		name string
+		lex  LexiconLoader
+	}{
+		{name: "No loader"},
+		{name: "Not found", lex: &testLexicons{}},
+		{name: "Fail", lex: &testLexicons{err: errors.New("olia")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := newTestLexiconWorker(t, "olia")
+			if tt.lex != nil {
+				w.SetLexicons(tt.lex)
+			}
+			err := w.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"},
+				Lexicon: "l1"})
+			assert.NotNil(t, err)
+		})
+	}
+}
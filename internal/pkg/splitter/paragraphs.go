@@ -0,0 +1,152 @@
+package splitter
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/airenas/tts-line/pkg/ssml"
+	"github.com/pkg/errors"
+)
+
+// Pauses are added to a plain text after paragraphs, headings and list items, a zero pause is not added
+type Pauses struct {
+	Paragraph time.Duration
+	Heading   time.Duration
+	ListItem  time.Duration
+}
+
+func (p Pauses) validate() error {
+	if p.Paragraph < 0 || p.Heading < 0 || p.ListItem < 0 {
+		return errors.Errorf("wrong pauses: paragraph %s, heading %s, list item %s", p.Paragraph, p.Heading, p.ListItem)
+	}
+	return nil
+}
+
+func (p Pauses) enabled() bool {
+	return p.Paragraph > 0 || p.Heading > 0 || p.ListItem > 0
+}
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockListItem
+)
+
+// textBlock is a paragraph, a heading or a list item of a plain text
+type textBlock struct {
+	lines []string
+	kind  blockKind
+	// last marks the last block of a paragraph
+	last bool
+}
+
+const maxHeadingLen = 100
+
+var (
+	bulletLine   = regexp.MustCompile(`^[-*•–—][ \t]+\S`)
+	numberedLine = regexp.MustCompile(`^(\d+[.)]|\d+(\.\d+)+\.?|[a-zA-Z]\))[ \t]+\S`)
+)
+
+// toParts converts a plain text into SSML parts with the pauses between the blocks.
+// The pause after the last block is added only if the text ends with a blank line
+func (p Pauses) toParts(text string, voice string, speed float64) []ssml.Part {
+	var res []ssml.Part
+	blocks := textBlocks(text)
+	for i, b := range blocks {
+		res = append(res, &ssml.Text{Texts: []ssml.TextPart{{Text: strings.Join(b.lines, "\n")}}, Voice: voice,
+			Speed: float32(speed)})
+		if i+1 == len(blocks) && !endsWithBlankLine(text) {
+			break
+		}
+		if d := p.after(b); d > 0 {
+			res = append(res, &ssml.Pause{Duration: d})
+		}
+	}
+	return res
+}
+
+// after returns the pause after the block, the longer one is taken at the paragraph end
+func (p Pauses) after(b *textBlock) time.Duration {
+	var res time.Duration
+	switch b.kind {
+	case blockHeading:
+		res = p.Heading
+	case blockListItem:
+		res = p.ListItem
+	}
+	if b.last && p.Paragraph > res {
+		res = p.Paragraph
+	}
+	return res
+}
+
+// textBlocks splits the text by blank lines into paragraphs. A paragraph of a single short numbered line or
+// an upper case line is a heading. A line starting with a bullet or a number starts a list item
+func textBlocks(text string) []*textBlock {
+	var res []*textBlock
+	var current *textBlock
+	endParagraph := func() {
+		if current != nil {
+			current.last = true
+			current = nil
+		}
+	}
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			endParagraph()
+			continue
+		}
+		single := current == nil && (i+1 == len(lines) || strings.TrimSpace(lines[i+1]) == "")
+		switch {
+		case isUpperLine(l) || (single && isNumberedHeading(l)):
+			endParagraph()
+			current = &textBlock{lines: []string{l}, kind: blockHeading}
+			res = append(res, current)
+			endParagraph()
+		case bulletLine.MatchString(l) || numberedLine.MatchString(l):
+			current = &textBlock{lines: []string{l}, kind: blockListItem}
+			res = append(res, current)
+		case current == nil:
+			current = &textBlock{lines: []string{l}, kind: blockParagraph}
+			res = append(res, current)
+		default:
+			current.lines = append(current.lines, l)
+		}
+	}
+	endParagraph()
+	return res
+}
+
+func endsWithBlankLine(text string) bool {
+	return strings.Count(text[len(strings.TrimRightFunc(text, unicode.IsSpace)):], "\n") > 1
+}
+
+// isUpperLine checks for a short line with at least two letters, all of them in upper case
+func isUpperLine(l string) bool {
+	if utf8.RuneCountInString(l) > maxHeadingLen {
+		return false
+	}
+	letters := 0
+	for _, r := range l {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters > 1
+}
+
+// isNumberedHeading checks for a short numbered line not ending as a sentence, e.g. "1.2 Įvadas"
+func isNumberedHeading(l string) bool {
+	return utf8.RuneCountInString(l) <= maxHeadingLen && numberedLine.MatchString(l) &&
+		!strings.ContainsAny(l[len(l)-1:], ".!?;:,")
+}
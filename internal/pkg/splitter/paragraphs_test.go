@@ -0,0 +1,93 @@
+package splitter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/airenas/tts-line/pkg/ssml"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_textBlocks(t *testing.T) {
+	type block struct {
+		text string
+		kind blockKind
+		last bool
+	}
+	tests := []struct {
+		name string
+		text string
+		want []block
+	}{
+		{name: "one", text: " olia\nolia ", want: []block{{text: "olia\nolia", last: true}}},
+		{name: "paragraphs", text: "olia.\n\n \nolia", want: []block{{text: "olia.", last: true}, {text: "olia", last: true}}},
+		{name: "upper heading", text: "ĮVADAS\nOlia olia", want: []block{{text: "ĮVADAS", kind: blockHeading, last: true},
+			{text: "Olia olia", last: true}}},
+		{name: "numbered heading", text: "1.2 Įvadas\n\nOlia", want: []block{{text: "1.2 Įvadas", kind: blockHeading, last: true},
+			{text: "Olia", last: true}}},
+		{name: "numbered sentence", text: "1. Olia olia.\n\nOlia", want: []block{{text: "1. Olia olia.", kind: blockListItem, last: true},
+			{text: "Olia", last: true}}},
+		{name: "list", text: "Olia:\n- vienas\n- du\n  tęsinys\n2) trys", want: []block{{text: "Olia:"},
+			{text: "- vienas", kind: blockListItem}, {text: "- du\ntęsinys", kind: blockListItem},
+			{text: "2) trys", kind: blockListItem, last: true}}},
+		{name: "not heading", text: "A\n2020 metais", want: []block{{text: "A\n2020 metais", last: true}}},
+		{name: "empty", text: " \n ", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []block
+			for _, b := range textBlocks(tt.text) {
+				got = append(got, block{text: strings.Join(b.lines, "\n"), kind: b.kind, last: b.last})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPauses_toParts(t *testing.T) {
+	p := Pauses{Paragraph: time.Second, Heading: 2 * time.Second, ListItem: 100 * time.Millisecond}
+	got := p.toParts("ĮVADAS\nOlia:\n- a\n- b\n\nOlia", "v", 1)
+	assert.Equal(t, []ssml.Part{
+		&ssml.Text{Texts: []ssml.TextPart{{Text: "ĮVADAS"}}, Voice: "v", Speed: 1},
+		&ssml.Pause{Duration: 2 * time.Second},
+		&ssml.Text{Texts: []ssml.TextPart{{Text: "Olia:"}}, Voice: "v", Speed: 1},
+		&ssml.Text{Texts: []ssml.TextPart{{Text: "- a"}}, Voice: "v", Speed: 1},
+		&ssml.Pause{Duration: 100 * time.Millisecond},
+		&ssml.Text{Texts: []ssml.TextPart{{Text: "- b"}}, Voice: "v", Speed: 1},
+		&ssml.Pause{Duration: time.Second},
+		&ssml.Text{Texts: []ssml.TextPart{{Text: "Olia"}}, Voice: "v", Speed: 1},
+	}, got)
+}
+
+func TestWorker_SetPauses(t *testing.T) {
+	w := &Worker{}
+	assert.NotNil(t, w.SetPauses(Pauses{Heading: -time.Second}))
+	assert.Nil(t, w.SetPauses(Pauses{Paragraph: time.Second}))
+	assert.Equal(t, time.Second, w.pauses.Paragraph)
+}
+
+func TestWorker_split_Pauses(t *testing.T) {
+	w := &Worker{wantedChars: 100, pauses: Pauses{Paragraph: 750 * time.Millisecond, Heading: 1250 * time.Millisecond}}
+	got, _, err := w.split("ĮVADAS\n\nOlia & olia.\n\nOlia", "astra", 1, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`<speak><voice name="astra"><prosody rate="100%">ĮVADAS</prosody></voice><break time="1250ms"/>` +
+		`<voice name="astra"><prosody rate="100%">Olia &amp; olia.</prosody></voice><break time="750ms"/>` +
+		`<voice name="astra"><prosody rate="100%">Olia</prosody></voice></speak>`}, got)
+}
+
+func TestWorker_split_NoPauses(t *testing.T) {
+	w := &Worker{wantedChars: 100}
+	got, _, err := w.split("ĮVADAS\n\nOlia", "astra", 1, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ĮVADAS\n\nOlia"}, got)
+}
+
+func TestEstimator_SetPauses(t *testing.T) {
+	e := NewEstimator()
+	assert.Nil(t, e.SetPauses(Pauses{Paragraph: time.Second}))
+	got, err := e.Estimate("Olia\n\nolia\n\nolia", "astra", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Second, got.Pauses)
+	assert.Equal(t, 12, got.Chars)
+}
@@ -0,0 +1,175 @@
+package splitter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultAbbreviations are Lithuanian abbreviations ending with a dot, which do not end a sentence.
+// One letter abbreviations and initials, e.g. "a. a.", "g.", "A. Smetona", are detected without the list
+var DefaultAbbreviations = []string{
+	"pvz", "plg", "žr", "kt", "pan", "šv", "gerb", "prof", "doc", "dr", "habil", "akad", "dir", "pirm",
+	"pav", "pavad", "vad", "vyr", "jaun", "vyresn", "kun", "gen", "plk", "mjr", "kpt", "ltn", "ats", "red", "sud",
+	"vert", "leid", "išl", "psl", "str", "sk", "nr", "tel", "mob", "faks", "el", "pšt", "adr", "pr", "al", "pl",
+	"raj", "sen", "aps", "mst", "km", "kv", "val", "min", "sek", "mėn", "sav", "proc", "tūkst", "mln", "mlrd",
+	"vnt", "apyt", "maž", "daug", "bendr", "liet", "angl", "lot", "vok", "rus", "pranc", "lenk", "dgs", "vns",
+	"kilm", "naud", "įn", "vard", "gal", "ppr", "etc", "vol", "mr", "mrs", "ms", "jr", "sr", "vs",
+}
+
+// split position levels, a higher level is a better place to split
+const (
+	levelNone = iota
+	levelSpace
+	levelClause
+	levelSentence
+	levelSentenceLine
+	levelParagraph
+)
+
+const (
+	closingChars   = `"'»”“’)]}`
+	openingChars   = `"'«„“‘([{–—-`
+	cjkSentenceEnd = "。！？"
+	cjkClauseEnd   = "，、；："
+)
+
+// Segmenter finds split positions at paragraph, sentence and clause boundaries,
+// nil value knows no abbreviations
+type Segmenter struct {
+	abbreviations map[string]bool
+}
+
+// NewSegmenter creates segmenter with the abbreviations, a dot after them does not end a sentence
+func NewSegmenter(abbreviations []string) *Segmenter {
+	res := &Segmenter{abbreviations: map[string]bool{}}
+	for _, a := range abbreviations {
+		a = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(a), "."))
+		// only the last word of a multi word abbreviation precedes the dot
+		if i := strings.LastIndexAny(a, " ."); i >= 0 {
+			a = a[i+1:]
+		}
+		if a != "" {
+			res.abbreviations[a] = true
+		}
+	}
+	return res
+}
+
+// nextSplit returns a split position in the window [start, start+interval): the first paragraph break,
+// else the first position of the best level. If the window has no boundary, the nearest one before
+// the window is taken, else the text is cut at start. Returns true for the forced cut
+func (s *Segmenter) nextSplit(rns []rune, start, interval int) (int, bool) {
+	if len(rns) < start+interval {
+		return len(rns), false
+	}
+	best, bestL := -1, levelNone
+	for i := start; i < start+interval; i++ {
+		l := s.level(rns, i)
+		if l == levelParagraph {
+			return i, false
+		}
+		if l > bestL {
+			best, bestL = i, l
+		}
+	}
+	if best > 0 {
+		return best, false
+	}
+	for i := start - 1; i > start/2; i-- {
+		if s.level(rns, i) > levelNone {
+			return i, false
+		}
+	}
+	return start, true
+}
+
+// level returns how good is to split before rns[i]
+func (s *Segmenter) level(rns []rune, i int) int {
+	if i == 0 || i >= len(rns) {
+		return levelNone
+	}
+	r, p := rns[i], rns[i-1]
+	if strings.ContainsRune(cjkSentenceEnd, p) {
+		return levelSentence
+	}
+	if strings.ContainsRune(cjkClauseEnd, p) {
+		return levelClause
+	}
+	if !unicode.IsSpace(r) {
+		return levelNone
+	}
+	if r == '\n' && p == '\n' {
+		return levelParagraph
+	}
+	if unicode.IsSpace(p) {
+		return levelNone
+	}
+	j := i - 1
+	for j > 0 && strings.ContainsRune(closingChars, rns[j]) {
+		j--
+	}
+	switch {
+	case strings.ContainsRune("!?…", rns[j]) || (rns[j] == '.' && s.isSentenceEnd(rns, j, i)):
+		if r == '\n' {
+			return levelSentenceLine
+		}
+		return levelSentence
+	case strings.ContainsRune(",;:", rns[j]) || r == '\n' || isDash(rns, i+1):
+		return levelClause
+	}
+	return levelSpace
+}
+
+// isSentenceEnd checks if the dot at j ends a sentence, i is the position of the space after the dot
+func (s *Segmenter) isSentenceEnd(rns []rune, j, i int) bool {
+	if j > 0 && rns[j-1] == '.' {
+		return true // ellipsis
+	}
+	from := j
+	for from > 0 && unicode.IsLetter(rns[from-1]) {
+		from--
+	}
+	word := string(rns[from:j])
+	if len([]rune(word)) == 1 || s.isAbbreviation(word) {
+		return false
+	}
+	if word == "" && isEnumeration(rns, j) {
+		return false
+	}
+	if r := rns[i]; r == '\n' {
+		return true
+	}
+	k := i
+	for k < len(rns) && unicode.IsSpace(rns[k]) {
+		k++
+	}
+	if k == len(rns) {
+		return true
+	}
+	n := rns[k]
+	return unicode.IsUpper(n) || unicode.IsDigit(n) || strings.ContainsRune(openingChars, n)
+}
+
+func (s *Segmenter) isAbbreviation(word string) bool {
+	return s != nil && s.abbreviations[strings.ToLower(word)]
+}
+
+// isEnumeration checks if the number before the dot at j starts a line, e.g. "1. Įvadas"
+func isEnumeration(rns []rune, j int) bool {
+	from := j
+	for from > 0 && unicode.IsDigit(rns[from-1]) {
+		from--
+	}
+	if from == j {
+		return false
+	}
+	for from > 0 && (rns[from-1] == ' ' || rns[from-1] == '\t') {
+		from--
+	}
+	return from == 0 || rns[from-1] == '\n'
+}
+
+// isDash checks for a dash followed by a space at i
+func isDash(rns []rune, i int) bool {
+	return i+1 < len(rns) && strings.ContainsRune("–—-", rns[i]) && unicode.IsSpace(rns[i+1])
+}
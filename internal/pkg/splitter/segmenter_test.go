@@ -0,0 +1,78 @@
+package splitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmenter_nextSplit(t *testing.T) {
+	type args struct {
+		str      string
+		start    int
+		interval int
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       int
+		wantForced bool
+	}{
+		{name: "len", args: args{str: "aaa aaa aaa aaa aaa", start: 10, interval: 10}, want: 19},
+		{name: "space", args: args{str: "aaa aaa aaa aaa aaa aaa", start: 10, interval: 10}, want: 11},
+		{name: "sentence", args: args{str: "aaa aaa aaa aaa. Aaa aaa", start: 10, interval: 10}, want: 16},
+		{name: "paragraph", args: args{str: "aaa aaa. Aaa\n\naaa.\n Aaa aaa aaa", start: 5, interval: 20}, want: 13},
+		{name: "sentence line", args: args{str: "aaa aaa. Aaa\naaa.\n Aaa aaa aaa", start: 5, interval: 20}, want: 17},
+		{name: "question", args: args{str: "aaa aaa aaa, aaa? aaa aaa", start: 5, interval: 20}, want: 17},
+		{name: "quoted", args: args{str: "aaa aaa aaa, „aaa.“ Aaa aaa", start: 5, interval: 20}, want: 19},
+		{name: "clause", args: args{str: "aaa aaa aaa aaa, aaa aaa aaa", start: 5, interval: 20}, want: 16},
+		{name: "dash", args: args{str: "aaa aaa aaa aaa – aaa aaa aaa", start: 5, interval: 20}, want: 15},
+		{name: "lower after dot", args: args{str: "aaa aaa aaa aaa. aaa, aaa aaa", start: 5, interval: 20}, want: 21},
+		{name: "abbreviation", args: args{str: "aaa aaa, pvz. Vilnius aaa aaa", start: 5, interval: 20}, want: 8},
+		{name: "abbreviation upper", args: args{str: "aaa aaa, Pvz. Vilnius aaa aaa", start: 5, interval: 20}, want: 8},
+		{name: "initials", args: args{str: "aaa aaa, A. Smetona aaa aaa aa", start: 5, interval: 20}, want: 8},
+		{name: "a. a.", args: args{str: "aaa aaa, a. a. Jonas aaa aaa aa", start: 5, interval: 20}, want: 8},
+		{name: "year", args: args{str: "aaa aaa, 2020 m. Vasario aaa aaa", start: 5, interval: 20}, want: 8},
+		{name: "number", args: args{str: "aaa aaa aaa 2020. Aaa aaa aaa", start: 5, interval: 20}, want: 17},
+		{name: "enumeration", args: args{str: "aaa aaa,\n1. Aaa aaa aaa aaa aa", start: 5, interval: 20}, want: 8},
+		{name: "ellipsis", args: args{str: "aaa aaa aaa... aaa aaa aaa aaa", start: 5, interval: 20}, want: 14},
+		{name: "cjk", args: args{str: "中文中文，中文中文。中文中文中文中文中文", start: 3, interval: 10}, want: 10},
+		{name: "cjk clause", args: args{str: "中文中文，中文中文中文中文中文中文中文", start: 3, interval: 10}, want: 5},
+		{name: "before window", args: args{str: "aaa aaaaaaaaaaa. aa. aaa", start: 5, interval: 2}, want: 3},
+		{name: "forced", args: args{str: "https://olia.lt/" + strings.Repeat("a", 30), start: 10, interval: 5}, want: 10,
+			wantForced: true},
+	}
+	s := NewSegmenter(DefaultAbbreviations)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, forced := s.nextSplit([]rune(tt.args.str), tt.args.start, tt.args.interval)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantForced, forced)
+		})
+	}
+}
+
+func TestNewSegmenter(t *testing.T) {
+	s := NewSegmenter([]string{" Olia. ", "t. sk.", ""})
+	assert.Equal(t, map[string]bool{"olia": true, "sk": true}, s.abbreviations)
+}
+
+func TestWorker_SetAbbreviations(t *testing.T) {
+	rns := []rune("aaa olia. Aaa")
+	w := &Worker{wantedChars: 10}
+	assert.Equal(t, levelSentence, w.segmenter.level(rns, 9))
+	w.SetAbbreviations([]string{"olia"})
+	assert.Equal(t, levelSpace, w.segmenter.level(rns, 9))
+	assert.True(t, w.segmenter.abbreviations["pvz"])
+}
+
+func TestWorker_splitText_NeverFails(t *testing.T) {
+	w := &Worker{wantedChars: 20, segmenter: NewSegmenter(DefaultAbbreviations)}
+	text := strings.Repeat("中", 50) + " " + strings.Repeat("https://olia.lt/", 5)
+	got := w.splitText(text, nil)
+	assert.Equal(t, text, strings.Join(got, ""))
+	for _, s := range got {
+		assert.LessOrEqual(t, len([]rune(s)), 25)
+	}
+}
@@ -0,0 +1,88 @@
+package splitter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/airenas/tts-line/pkg/ssml"
+)
+
+// SSMLError is an SSML parse error with the location of the failure
+type SSMLError struct {
+	Line   int
+	Column int
+	Tag    string
+	Err    error
+}
+
+func (e *SSMLError) Error() string {
+	if e.Tag != "" {
+		return fmt.Sprintf("line %d, column %d, tag '%s': %v", e.Line, e.Column, e.Tag, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *SSMLError) Unwrap() error {
+	return e.Err
+}
+
+// IsSSML returns true if the text is processed as SSML
+func IsSSML(text string) bool {
+	return strings.HasPrefix(text, "<speak")
+}
+
+// ValidateSSML parses the text with the same rules as the Worker,
+// returns *SSMLError on failure
+func ValidateSSML(text string) error {
+	for _, ch := range splitSSMLChapters(text) {
+		r := &countingReader{r: strings.NewReader(ch.text)}
+		_, err := parseSSML(r, "", 1)
+		if err != nil {
+			return newSSMLError(text, ch.originalPos(r.n, text), err)
+		}
+	}
+	return nil
+}
+
+func parseSSML(r io.Reader, voice string, speed float64) ([]ssml.Part, error) {
+	return ssml.Parse(r, &ssml.Text{Voice: voice, Speed: float32(speed)},
+		func(s string) (string, error) { return s, nil })
+}
+
+// countingReader tracks how many bytes the parser has read.
+// It implements io.ByteReader, so xml decoder reads byte by byte without buffering
+type countingReader struct {
+	r *strings.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
+
+// newSSMLError locates the failure: the tag read last or the position the parser stopped at
+func newSSMLError(text string, read int, err error) *SSMLError {
+	pos := read
+	tag := ""
+	if lt := strings.LastIndex(text[:read], "<"); lt >= 0 {
+		gt := strings.LastIndex(text[:read], ">")
+		if gt == read-1 || gt < lt {
+			pos, tag = lt, text[lt:read]
+		}
+	}
+	line := strings.Count(text[:pos], "\n") + 1
+	column := utf8.RuneCountInString(text[strings.LastIndex(text[:pos], "\n")+1:pos]) + 1
+	return &SSMLError{Line: line, Column: column, Tag: tag, Err: err}
+}
@@ -0,0 +1,59 @@
+package splitter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSSML(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		wantErr bool
+		line    int
+		column  int
+		tag     string
+	}{
+		{name: "OK", args: `<speak>olia<break time="1s"/> <p/>olia</speak>`},
+		{name: "Wrong attribute", args: `<speak>olia<break time="1x"/></speak>`, wantErr: true,
+			line: 1, column: 12, tag: `<break time="1x"/>`},
+		{name: "Unknown tag", args: "<speak>\n  olia\n  <foo>olia</foo></speak>", wantErr: true,
+			line: 3, column: 3, tag: "<foo>"},
+		{name: "Wrong close tag", args: "<speak>\nolia</speek>", wantErr: true,
+			line: 2, column: 5, tag: "</speek>"},
+		{name: "Column in runes", args: `<speak>ąčę<foo/></speak>`, wantErr: true,
+			line: 1, column: 11, tag: "<foo/>"},
+		{name: "Unclosed tag", args: "<speak>olia\n<break time=\"1s\"", wantErr: true,
+			line: 2, column: 1, tag: `<break time="1s"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSSML(tt.args)
+			if !tt.wantErr {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.NotNil(t, err) {
+				se, ok := err.(*SSMLError)
+				assert.True(t, ok)
+				assert.Equal(t, tt.line, se.Line)
+				assert.Equal(t, tt.column, se.Column)
+				assert.Equal(t, tt.tag, se.Tag)
+				assert.NotNil(t, se.Err)
+			}
+		})
+	}
+}
+
+func TestSSMLError_Error(t *testing.T) {
+	err := newSSMLError("<speak>\n<foo>", 13, assert.AnError)
+	assert.Equal(t, "line 2, column 1, tag '<foo>': "+assert.AnError.Error(), err.Error())
+	err = &SSMLError{Line: 1, Column: 2, Err: assert.AnError}
+	assert.Equal(t, "line 1, column 2: "+assert.AnError.Error(), err.Error())
+}
+
+func TestIsSSML(t *testing.T) {
+	assert.True(t, IsSSML("<speak>olia</speak>"))
+	assert.False(t, IsSSML("olia <speak>"))
+}
@@ -0,0 +1,98 @@
+package splitter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/airenas/tts-line/pkg/ssml"
+	"github.com/pkg/errors"
+)
+
+// voiceTag matches the voice switch of a plain text: [voice=name]. An empty name switches back to the request voice
+var voiceTag = regexp.MustCompile(`(?i)\[voice[ \t]*=[ \t]*([^\]\n]*?)[ \t]*\]`)
+
+// ssmlVoiceTag matches the voice name of a SSML <voice> tag without parsing the document
+var ssmlVoiceTag = regexp.MustCompile(`(?i)<voice\s[^>]*?\bname\s*=\s*["']([^"']*)["']`)
+
+// voiceText is a part of a plain text read by one voice
+type voiceText struct {
+	voice string
+	text  string
+}
+
+// splitVoices cuts a plain text by the [voice=name] tags. voice is the current voice, def - the request voice.
+// Returns the texts and the voice at the end of the text
+func splitVoices(text, voice, def string) ([]voiceText, string) {
+	locs := voiceTag.FindAllStringSubmatchIndex(text, -1)
+	if len(locs) == 0 {
+		return []voiceText{{voice: voice, text: text}}, voice
+	}
+	var res []voiceText
+	add := func(from, to int) {
+		if strings.TrimSpace(text[from:to]) != "" {
+			res = append(res, voiceText{voice: voice, text: text[from:to]})
+		}
+	}
+	from := 0
+	for _, l := range locs {
+		add(from, l[0])
+		voice = text[l[2]:l[3]]
+		if voice == "" {
+			voice = def
+		}
+		from = l[1]
+	}
+	add(from, len(text))
+	return res, voice
+}
+
+// TextVoices returns the sorted names of the voices the text switches to:
+// by [voice=name] in a plain text or by <voice name="..."> in SSML
+func TextVoices(text string) ([]string, error) {
+	names := map[string]bool{}
+	if IsSSML(text) {
+		for _, ch := range splitSSMLChapters(text) {
+			parts, err := parseSSML(strings.NewReader(ch.text), "", 1)
+			if err != nil {
+				return nil, errors.Wrap(err, "can't parse SSML")
+			}
+			for _, p := range parts {
+				if t, ok := p.(*ssml.Text); ok && t.Voice != "" {
+					names[t.Voice] = true
+				}
+			}
+		}
+	} else {
+		for _, m := range voiceTag.FindAllStringSubmatch(text, -1) {
+			if m[1] != "" {
+				names[m[1]] = true
+			}
+		}
+	}
+	res := make([]string, 0, len(names))
+	for n := range names {
+		res = append(res, n)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// ChunkVoices returns the sorted names of the voices of [voice=name] and <voice name="..."> tags in a piece of text.
+// A tag cut by the piece boundary is not found, so the whole text must be checked by TextVoices later
+func ChunkVoices(text string) []string {
+	names := map[string]bool{}
+	for _, re := range []*regexp.Regexp{voiceTag, ssmlVoiceTag} {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if m[1] != "" {
+				names[m[1]] = true
+			}
+		}
+	}
+	res := make([]string, 0, len(names))
+	for n := range names {
+		res = append(res, n)
+	}
+	sort.Strings(res)
+	return res
+}
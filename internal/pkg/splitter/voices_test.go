@@ -0,0 +1,85 @@
+package splitter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitVoices(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		voice     string
+		want      []voiceText
+		wantVoice string
+	}{
+		{name: "none", text: " olia ", voice: "v1", want: []voiceText{{voice: "v1", text: " olia "}}, wantVoice: "v1"},
+		{name: "switch", text: "olia\n[voice=v2]\nolia2 [Voice = v3 ]olia3", voice: "v1",
+			want:      []voiceText{{voice: "v1", text: "olia\n"}, {voice: "v2", text: "\nolia2 "}, {voice: "v3", text: "olia3"}},
+			wantVoice: "v3"},
+		{name: "back", text: "[voice=v2]olia2[voice=]olia", voice: "v3",
+			want: []voiceText{{voice: "v2", text: "olia2"}, {voice: "def", text: "olia"}}, wantVoice: "def"},
+		{name: "last", text: "olia[voice=v2] ", voice: "v1", want: []voiceText{{voice: "v1", text: "olia"}}, wantVoice: "v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotVoice := splitVoices(tt.text, tt.voice, "def")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantVoice, gotVoice)
+		})
+	}
+}
+
+func TestTextVoices(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", text: "olia", want: []string{}},
+		{name: "text", text: "[voice=v2]olia[voice=v1]olia[voice=]olia[voice=v2]", want: []string{"v1", "v2"}},
+		{name: "SSML", text: `<speak>olia<voice name="v2">olia</voice><mark name="a"/><voice name="v1">olia</voice></speak>`,
+			want: []string{"v1", "v2"}},
+		{name: "SSML fail", text: `<speak><olia/></speak>`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TextVoices(tt.text)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestChunkVoices(t *testing.T) {
+	assert.Equal(t, []string{}, ChunkVoices("olia"))
+	assert.Equal(t, []string{"v1", "v2"}, ChunkVoices("[voice=v2]olia[Voice = v1 ]olia[voice=]olia[voi"))
+	assert.Equal(t, []string{"v1", "v2"}, ChunkVoices(`olia<voice name="v2">olia</voice><VOICE gender="f" name='v1'>`+
+		`<mark name="a"/><voice name="v3`))
+}
+
+func TestWorker_split_Voices(t *testing.T) {
+	w := &Worker{wantedChars: 100}
+	got, chapters, err := w.split("Olia [voice=v2]\"Labas\" [voice=]olia\n[chapter=One]\n[voice=v2]olia\n[chapter=Two]\nolia",
+		"astra", 1, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []Chapter{{Title: "One", Part: 1}, {Title: "Two", Part: 2}}, chapters)
+	assert.Equal(t, []string{`<speak><voice name="astra"><prosody rate="100%">Olia</prosody></voice>` +
+		`<voice name="v2"><prosody rate="100%">&#34;Labas&#34;</prosody></voice>` +
+		`<voice name="astra"><prosody rate="100%">olia</prosody></voice></speak>`,
+		`<speak><voice name="v2"><prosody rate="100%">olia</prosody></voice></speak>`,
+		`<speak><voice name="v2"><prosody rate="100%">olia</prosody></voice></speak>`}, got)
+}
+
+func TestWorker_split_VoicesPauses(t *testing.T) {
+	w := &Worker{wantedChars: 100, pauses: Pauses{Paragraph: time.Second}}
+	got, _, err := w.split("[voice=v2]Olia\n\n[voice=v3]Olia\n\n", "astra", 1, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`<speak><voice name="v2"><prosody rate="100%">Olia</prosody></voice><break time="1000ms"/>` +
+		`<voice name="v3"><prosody rate="100%">Olia</prosody></voice></speak>`}, got)
+}
@@ -2,34 +2,40 @@ package splitter
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
-	"unicode"
 	"unicode/utf8"
 
 	"github.com/airenas/big-tts/internal/pkg/messages"
-	"github.com/airenas/big-tts/internal/pkg/utils"
+	"github.com/airenas/big-tts/internal/pkg/storage"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/airenas/tts-line/pkg/ssml"
 	"github.com/pkg/errors"
 )
 
+const defaultWantedChars = 1900
+
 // Worker for implementing text split
 type Worker struct {
 	loadPath string
 	savePath string
 
-	loadFunc      func(string) ([]byte, error)
-	saveFunc      func(string, []byte) error
-	createDirFunc func(string) error
-	wantedChars   int
+	loadFunc    func(string) ([]byte, error)
+	saveFunc    func(string, []byte) error
+	wantedChars int
+	segmenter   *Segmenter
+	lexicons    LexiconLoader
+	pauses      Pauses
 }
 
 // NewWorker initiates new worker
-func NewWorker(loadPath string, savePath string) (*Worker, error) {
+func NewWorker(st storage.Storage, loadPath string, savePath string) (*Worker, error) {
+	if st == nil {
+		return nil, errors.Errorf("no storage")
+	}
 	if !strings.Contains(loadPath, "{}") {
 		return nil, errors.Errorf("no ID template in load path")
 	}
@@ -37,13 +43,32 @@ func NewWorker(loadPath string, savePath string) (*Worker, error) {
 		return nil, errors.Errorf("no ID template in save path")
 	}
 	res := &Worker{loadPath: loadPath, savePath: savePath}
-	res.loadFunc = os.ReadFile
-	res.saveFunc = utils.WriteFile
-	res.createDirFunc = func(name string) error { return os.MkdirAll(name, os.ModePerm) }
-	res.wantedChars = 1900
+	res.loadFunc = func(name string) ([]byte, error) { return storage.ReadFile(st, name) }
+	res.saveFunc = func(name string, data []byte) error { return storage.WriteFile(st, name, data) }
+	res.wantedChars = defaultWantedChars
+	res.segmenter = NewSegmenter(DefaultAbbreviations)
 	return res, nil
 }
 
+// SetAbbreviations adds the abbreviations to the default list, a dot after them does not end a sentence
+func (w *Worker) SetAbbreviations(abbreviations []string) {
+	w.segmenter = NewSegmenter(append(append([]string{}, DefaultAbbreviations...), abbreviations...))
+}
+
+// SetPauses sets the pauses added to a plain text, the text is converted to SSML if any pause is set
+func (w *Worker) SetPauses(p Pauses) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	w.pauses = p
+	return nil
+}
+
+// SetLexicons sets the loader of user lexicons
+func (w *Worker) SetLexicons(l LexiconLoader) {
+	w.lexicons = l
+}
+
 // Do main worker's method
 func (w *Worker) Do(ctx context.Context, msg *messages.TTSMessage) error {
 	goapp.Log.Infof("Doing split job for %s", msg.ID)
@@ -51,11 +76,15 @@ func (w *Worker) Do(ctx context.Context, msg *messages.TTSMessage) error {
 	if err != nil {
 		return errors.Wrapf(err, "can't load text")
 	}
-	texts, err := w.split(text, msg.Voice, msg.Speed)
+	lex, err := w.loadLexicon(msg.Lexicon)
+	if err != nil {
+		return errors.Wrapf(err, "can't load lexicon")
+	}
+	texts, chapters, err := w.split(text, msg.Voice, msg.Speed, lex, nil)
 	if err != nil {
 		return errors.Wrapf(err, "can't split text")
 	}
-	err = w.save(msg.ID, texts)
+	err = w.save(msg.ID, texts, chapters)
 	if err != nil {
 		return errors.Wrapf(err, "can't save texts")
 	}
@@ -71,20 +100,88 @@ func (w *Worker) load(ID string) (string, error) {
 	return string(bytes), nil
 }
 
-func (w *Worker) split(text string, voice string, speed float64) ([]string, error) {
-	if strings.HasPrefix(text, "<speak") {
-		return w.doSSML(text, voice, speed)
+func (w *Worker) loadLexicon(ID string) (lexicon, error) {
+	if ID == "" {
+		return nil, nil
+	}
+	if w.lexicons == nil {
+		return nil, errors.New("no lexicon loader")
+	}
+	l, err := w.lexicons.Get(ID)
+	if err != nil {
+		return nil, err
 	}
-	return w.splitText(text)
+	if l == nil {
+		return nil, errors.Errorf("no lexicon %s", ID)
+	}
+	return newLexicon(l), nil
 }
 
-func (w *Worker) doSSML(text string, voice string, speed float64) ([]string, error) {
-	parts, err := ssml.Parse(strings.NewReader(text), &ssml.Text{Voice: voice, Speed: float32(speed)},
-		func(s string) (string, error) { return s, nil })
+// split cuts the text into parts, each chapter starts a new part.
+// A voice switched by [voice=name] in a plain text lasts till the next switch, across chapters too
+func (w *Worker) split(text string, voice string, speed float64, lex lexicon, st *stats) ([]string, []Chapter, error) {
+	var res []string
+	var chapters []Chapter
+	textVoice := voice
+	for _, ch := range splitChapters(text) {
+		if ch.chapter {
+			title := ch.title
+			if title == "" {
+				title = fmt.Sprintf("Chapter %d", len(chapters)+1)
+			}
+			chapters = append(chapters, Chapter{Title: title, Part: len(res)})
+		}
+		st.setDone(len(res))
+		var texts []string
+		var err error
+		if IsSSML(ch.text) {
+			texts, err = w.doSSML(ch.text, voice, speed, lex, st)
+		} else {
+			var vts []voiceText
+			vts, textVoice = splitVoices(ch.text, textVoice, voice)
+			texts, err = w.splitPlain(vts, voice, speed, lex, st)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, texts...)
+	}
+	return res, chapters, nil
+}
+
+// splitPlain splits a plain text. The text is converted to SSML if it has pauses, lexicon words or
+// other voices than the request voice
+func (w *Worker) splitPlain(texts []voiceText, voice string, speed float64, lex lexicon, st *stats) ([]string, error) {
+	if len(texts) == 1 && texts[0].voice == voice && !w.pauses.enabled() && len(lex) == 0 {
+		return w.splitText(texts[0].text, st), nil
+	}
+	var parts []ssml.Part
+	for _, t := range texts {
+		if w.pauses.enabled() {
+			parts = append(parts, w.pauses.toParts(t.text, t.voice, speed)...)
+		} else {
+			parts = append(parts, &ssml.Text{Texts: []ssml.TextPart{{Text: strings.TrimSpace(t.text)}}, Voice: t.voice,
+				Speed: float32(speed)})
+		}
+	}
+	for len(parts) > 0 {
+		if _, ok := parts[len(parts)-1].(*ssml.Pause); !ok {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+	return w.splitParts(parts, lex, st)
+}
+
+func (w *Worker) doSSML(text string, voice string, speed float64, lex lexicon, st *stats) ([]string, error) {
+	parts, err := parseSSML(strings.NewReader(text), voice, speed)
 	if err != nil {
 		return nil, fmt.Errorf("can't parse: %v", err)
 	}
+	return w.splitParts(parts, lex, st)
+}
 
+func (w *Worker) splitParts(parts []ssml.Part, lex lexicon, st *stats) ([]string, error) {
 	var res []string
 	var cParts []ssml.Part
 	cLen := 0
@@ -93,10 +190,7 @@ func (w *Worker) doSSML(text string, voice string, speed float64) ([]string, err
 		switch sp := part.(type) {
 		case *ssml.Text:
 			var cPart *ssml.Text
-			txts, err := w.splitTextParts(sp.Texts)
-			if err != nil {
-				return nil, errors.Wrapf(err, "can't split")
-			}
+			txts := w.splitTextParts(lex.apply(sp.Texts), len(res), st)
 			for _, txtParts := range txts {
 				pLen := getRuneCount(txtParts)
 				if cLen+pLen > maxChars {
@@ -112,10 +206,12 @@ func (w *Worker) doSSML(text string, voice string, speed float64) ([]string, err
 				for _, p := range txtParts {
 					cPart.Texts = append(cPart.Texts, *p)
 				}
-				cLen += pLen 
+				cLen += pLen
+				st.addChars(pLen)
 			}
 		case *ssml.Pause:
 			cParts = append(cParts, sp)
+			st.addPause(sp.Duration)
 		default:
 			return nil, fmt.Errorf("unknown type %T", sp)
 		}
@@ -141,7 +237,6 @@ func getRuneCount(txt []*ssml.TextPart) int {
 	return res
 }
 
-
 func saveToSSMLString(cParts []ssml.Part) string {
 	res := strings.Builder{}
 	res.WriteString("<speak>")
@@ -150,10 +245,13 @@ func saveToSSMLString(cParts []ssml.Part) string {
 		case *ssml.Text:
 			res.WriteString(fmt.Sprintf(`<voice name="%s"><prosody rate="%s">`, sp.Voice, toRateStr(sp.Speed)))
 			for _, tp := range sp.Texts {
-				if tp.Accented != "" {
-					res.WriteString(`<intelektika:w acc="`)
-					_ = xml.EscapeText(&res, []byte(tp.Accented))
-					res.WriteString(`"`)
+				if tp.Accented != "" || tp.UserOEPal != "" {
+					res.WriteString(`<intelektika:w`)
+					if tp.Accented != "" {
+						res.WriteString(` acc="`)
+						_ = xml.EscapeText(&res, []byte(tp.Accented))
+						res.WriteString(`"`)
+					}
 					if tp.Syllables != "" {
 						res.WriteString(` syll="`)
 						_ = xml.EscapeText(&res, []byte(tp.Syllables))
@@ -197,18 +295,25 @@ func toRateStr(f float32) string {
 	return fmt.Sprintf("%d%%", p)
 }
 
-func (w *Worker) splitText(text string) ([]string, error) {
+func (w *Worker) splitText(text string, st *stats) []string {
 	var res []string
 	rns := []rune(text)
+	st.addChars(len(rns))
 	for len(rns) > 0 {
-		pos, err := getNextSplit(rns, w.wantedChars, w.wantedChars/4)
-		if err != nil {
-			return nil, err
-		}
+		pos := w.nextSplit(rns, len(res), st)
 		res = append(res, string(rns[:pos]))
 		rns = rns[pos:]
 	}
-	return res, nil
+	return res
+}
+
+// nextSplit returns the split position, a forced cut is reported to the stats
+func (w *Worker) nextSplit(rns []rune, part int, st *stats) int {
+	res, forced := w.segmenter.nextSplit(rns, w.wantedChars, w.wantedChars/4)
+	if forced {
+		st.addForcedCut(rns, res, part)
+	}
+	return res
 }
 
 type partRemaining struct {
@@ -216,7 +321,7 @@ type partRemaining struct {
 	pPart, pText int
 }
 
-func (w *Worker) splitTextParts(texts []ssml.TextPart) ([][]*ssml.TextPart, error) {
+func (w *Worker) splitTextParts(texts []ssml.TextPart, done int, st *stats) [][]*ssml.TextPart {
 	var res [][]*ssml.TextPart
 	tb := strings.Builder{}
 	for _, tp := range texts {
@@ -230,76 +335,15 @@ func (w *Worker) splitTextParts(texts []ssml.TextPart) ([][]*ssml.TextPart, erro
 
 	rns := []rune(tb.String())
 	for len(rns) > 0 {
-		pos, err := getNextSplit(rns, w.wantedChars, w.wantedChars/4)
-		if err != nil {
-			return nil, err
-		}
-
+		pos := w.nextSplit(rns, done+len(res), st)
 		res = append(res, pl.getPartsTo(pos))
 		rns = rns[pos:]
 	}
-	return res, nil
-}
-
-func getNextSplit(rns []rune, start, interval int) (int, error) {
-	l := len(rns)
-	if l < (start + interval) {
-		return l, nil
-	}
-
-	s := "   "
-	best := -1
-	bestV := 0
-	for i := 0; i < interval; i++ {
-		r := rns[start+i]
-		s = getNewPattern(s, r)
-		if s == ".\n\n" || s == "\n\n\n" {
-			return start + i, nil
-		} else if s == ".\nU" {
-			best = start + i - 1
-			bestV = 3
-		} else if (s == ". U") && bestV < 2 {
-			best = start + i - 1
-			bestV = 2
-		} else if (r == ' ') && bestV < 1 {
-			best = start + i
-			bestV = 1
-		}
-	}
-	if best > 0 {
-		return best, nil
-	}
-	return 0, errors.New("no split position found")
-}
-
-func getNewPattern(str string, r rune) string {
-	if r == '\n' && str[1] == '.' && str[2] == ' ' {
-		return str[:2] + "\n"
-	}
-	if r == '\n' {
-		return str[1:] + "\n"
-	}
-	if r == '.' {
-		return str[1:] + "."
-	}
-	if unicode.IsSpace(r) || r == '\t' {
-		if str[2] == ' ' || str[2] == '\n' {
-			return str
-		}
-		return str[1:] + " "
-	}
-	if unicode.IsUpper(r) {
-		return str[1:] + "U"
-	}
-	return str[1:] + "-"
+	return res
 }
 
-func (w *Worker) save(ID string, texts []string) error {
+func (w *Worker) save(ID string, texts []string, chapters []Chapter) error {
 	path := strings.ReplaceAll(w.savePath, "{}", ID)
-	err := w.createDirFunc(path)
-	if err != nil {
-		return errors.Wrapf(err, "can't create %s", path)
-	}
 	for i, s := range texts {
 		fp := filepath.Join(path, fmt.Sprintf("%04d.txt", i))
 		err := w.saveFunc(fp, []byte(s))
@@ -307,6 +351,17 @@ func (w *Worker) save(ID string, texts []string) error {
 			return errors.Wrapf(err, "can't save %s", fp)
 		}
 	}
+	if len(chapters) == 0 {
+		return nil
+	}
+	bytes, err := json.Marshal(chapters)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal chapters")
+	}
+	fp := filepath.Join(path, ChaptersFile)
+	if err := w.saveFunc(fp, bytes); err != nil {
+		return errors.Wrapf(err, "can't save %s", fp)
+	}
 	return nil
 }
 
@@ -321,14 +376,14 @@ func (pl *partRemaining) getPartsTo(pos int) []*ssml.TextPart {
 			if pl.pText == 0 {
 				res = append(res, &p) // add whole part
 			} else {
-				res = append(res, &ssml.TextPart{Text: p.Text[pl.pText:]})
+				res = append(res, &ssml.TextPart{Text: string([]rune(p.Text)[pl.pText:])})
 			}
 			from += cLen - pl.pText + 1 // +1 for end space
 			pl.pText = 0
 			pl.pPart++
 		} else {
 			to := pos - from + pl.pText
-			res = append(res, &ssml.TextPart{Text: p.Text[pl.pText:to]})
+			res = append(res, &ssml.TextPart{Text: string([]rune(p.Text)[pl.pText:to])})
 			from += to - pl.pText
 			pl.pText += to - pl.pText
 		}
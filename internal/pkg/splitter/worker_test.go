@@ -9,87 +9,26 @@ import (
 
 	amessages "github.com/airenas/async-api/pkg/messages"
 	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/storage"
 	"github.com/airenas/tts-line/pkg/ssml"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
-func Test_getNewPattern(t *testing.T) {
-	type args struct {
-		str string
-		r   rune
-	}
-	tests := []struct {
-		name string
-		args args
-		want string
-	}{
-		{name: "space", args: args{str: "   ", r: ' '}, want: "   "},
-		{name: "dot", args: args{str: "   ", r: '.'}, want: "  ."},
-		{name: "newline", args: args{str: "   ", r: '\n'}, want: "  \n"},
-		{name: "lower", args: args{str: "   ", r: 'a'}, want: "  -"},
-		{name: "upper", args: args{str: "   ", r: 'A'}, want: "  U"},
-		{name: "space", args: args{str: "  .", r: ' '}, want: " . "},
-		{name: "space", args: args{str: " . ", r: ' '}, want: " . "},
-		{name: "newline", args: args{str: " . ", r: '\n'}, want: " .\n"},
-		{name: "newline", args: args{str: " .\n", r: '\n'}, want: ".\n\n"},
-		{name: "space", args: args{str: " .\n", r: ' '}, want: " .\n"},
-		{name: "tab", args: args{str: " .\n", r: '\t'}, want: " .\n"},
-		{name: "tab", args: args{str: " ..", r: '\t'}, want: ".. "},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := getNewPattern(tt.args.str, tt.args.r); got != tt.want {
-				t.Errorf("getNewPattern() = '%v', want '%v'", got, tt.want)
-			}
-		})
-	}
-}
-
-func Test_getNextSplit(t *testing.T) {
-	type args struct {
-		str      string
-		start    int
-		interval int
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    int
-		wantErr bool
-	}{
-		{name: "len", args: args{str: "aaa aaa aaa aaa aaa", start: 10, interval: 10}, want: 19, wantErr: false},
-		{name: "space", args: args{str: "aaa aaa aaa aaa aaa aaa", start: 10, interval: 10}, want: 11, wantErr: false},
-		{name: "space", args: args{str: "aaa aaa aaa aaa. Aaa aaa", start: 10, interval: 10}, want: 16, wantErr: false},
-		{name: "space", args: args{str: "aaa aaa. Aaa\n\naaa.\n Aaa aaa aaa", start: 5, interval: 20}, want: 19, wantErr: false},
-		{name: "space", args: args{str: "aaa aaaaaaaaaaa. aa. aaa", start: 5, interval: 2}, want: 0, wantErr: true},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := getNextSplit([]rune(tt.args.str), tt.args.start, tt.args.interval)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("getNextSplit() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("getNextSplit() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestNewWorker(t *testing.T) {
-	got, err := NewWorker("{}.txt", "new{}.txt")
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new{}.txt")
 	assert.Nil(t, err)
 	assert.NotNil(t, got)
-	_, err = NewWorker("{}.txt", "new.txt")
+	_, err = NewWorker(&storage.Local{}, "{}.txt", "new.txt")
+	assert.NotNil(t, err)
+	_, err = NewWorker(&storage.Local{}, "aaa.txt", "new{}.txt")
 	assert.NotNil(t, err)
-	_, err = NewWorker("aaa.txt", "new{}.txt")
+	_, err = NewWorker(nil, "{}.txt", "new{}.txt")
 	assert.NotNil(t, err)
 }
 
 func TestWorker_Do(t *testing.T) {
-	got, err := NewWorker("{}.txt", "new/{}/path")
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new/{}/path")
 	assert.Nil(t, err)
 	got.loadFunc = func(s string) ([]byte, error) {
 		assert.Equal(t, "id1.txt", s)
@@ -98,15 +37,12 @@ func TestWorker_Do(t *testing.T) {
 	got.saveFunc = func(s string, b []byte) error {
 		return nil
 	}
-	got.createDirFunc = func(s string) error {
-		return nil
-	}
 	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}})
 	assert.Nil(t, err)
 }
 
 func TestWorker_Do_Fail(t *testing.T) {
-	got, err := NewWorker("{}.txt", "new/{}/path")
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new/{}/path")
 	assert.Nil(t, err)
 	got.loadFunc = func(s string) ([]byte, error) {
 		return nil, errors.New("err")
@@ -114,22 +50,16 @@ func TestWorker_Do_Fail(t *testing.T) {
 	got.saveFunc = func(s string, b []byte) error {
 		return nil
 	}
-	got.createDirFunc = func(s string) error {
-		return nil
-	}
 	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}})
 	assert.NotNil(t, err)
 }
 
 func TestWorker_Do_FailSave(t *testing.T) {
-	got, err := NewWorker("{}.txt", "new/{}/path")
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new/{}/path")
 	assert.Nil(t, err)
 	got.saveFunc = func(s string, b []byte) error {
 		return errors.New("err")
 	}
-	got.createDirFunc = func(s string) error {
-		return nil
-	}
 	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}})
 	assert.NotNil(t, err)
 }
@@ -182,6 +112,8 @@ func Test_saveToSSMLString(t *testing.T) {
 			want: `<speak><voice name="as"><prosody rate="100%"><intelektika:w acc="oli{a/}" syll="o-li-a">olia</intelektika:w></prosody></voice></speak>`},
 		{name: "Word OE model", args: []ssml.Part{&ssml.Text{Texts: []ssml.TextPart{{Text: "olia", Accented: "oli{a/}", Syllables: "o-li-a", UserOEPal: "O'lia"}}, Speed: 1, Voice: "as"}},
 			want: `<speak><voice name="as"><prosody rate="100%"><intelektika:w acc="oli{a/}" syll="o-li-a" user="O&#39;lia">olia</intelektika:w></prosody></voice></speak>`},
+		{name: "Word OE model only", args: []ssml.Part{&ssml.Text{Texts: []ssml.TextPart{{Text: "olia", UserOEPal: "O'lia"}}, Speed: 1, Voice: "as"}},
+			want: `<speak><voice name="as"><prosody rate="100%"><intelektika:w user="O&#39;lia">olia</intelektika:w></prosody></voice></speak>`},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -249,7 +181,7 @@ func TestWorker_doSSML(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			w := &Worker{wantedChars: tt.wChars}
-			got, err := w.doSSML(tt.args, "vd", 1.5)
+			got, err := w.doSSML(tt.args, "vd", 1.5, nil, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Worker.doSSML() error = %v, wantErr %v", err, tt.wantErr)
 				return
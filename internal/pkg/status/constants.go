@@ -14,13 +14,15 @@ const (
 	Join
 	// Completed - final step
 	Completed
+	// Cancelled - the job is canceled by the user
+	Cancelled
 )
 
 var (
 	statusName = map[Status]string{Uploaded: "UPLOADED", Completed: "COMPLETED",
 		Split: "Split", Synthesize: "Synthesize",
-		Join: "Join"}
-	nameStatus = map[string]Status{"UPLOADED": Uploaded, "COMPLETED": Completed,
+		Join: "Join", Cancelled: "CANCELLED"}
+	nameStatus = map[string]Status{"UPLOADED": Uploaded, "COMPLETED": Completed, "CANCELLED": Cancelled,
 		"Synthesize": Synthesize, "Join": Join,
 		"Split": Split}
 )
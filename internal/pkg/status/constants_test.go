@@ -15,6 +15,7 @@ func TestStatus_String(t *testing.T) {
 		{st: Synthesize, want: "Synthesize"},
 		{st: Split, want: "Split"},
 		{st: Join, want: "Join"},
+		{st: Cancelled, want: "CANCELLED"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -36,6 +37,7 @@ func TestFrom(t *testing.T) {
 		{args: "Join", want: Join},
 		{args: "UPLOADED", want: Uploaded},
 		{args: "Synthesize", want: Synthesize},
+		{args: "CANCELLED", want: Cancelled},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Cleaner removes job files from the bucket by the pattern, e.g. in/{ID}.* or work/{ID}/
+type Cleaner struct {
+	st      *S3
+	pattern string
+}
+
+// NewCleaner creates bucket cleaner
+func NewCleaner(st *S3, pattern string) (*Cleaner, error) {
+	if st == nil {
+		return nil, errors.New("no storage")
+	}
+	if !strings.Contains(pattern, "{ID}") {
+		return nil, errors.Errorf("no {ID} in pattern '%s'", pattern)
+	}
+	return &Cleaner{st: st, pattern: pattern}, nil
+}
+
+// Clean removes the files of the job
+func (c *Cleaner) Clean(ID string) error {
+	p := strings.ReplaceAll(c.pattern, "{ID}", ID)
+	prefix := p
+	glob := false
+	if i := strings.IndexAny(p, "*?["); i >= 0 {
+		prefix, glob = p[:i], true
+	}
+	names, err := c.st.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if !c.match(p, n, glob) {
+			continue
+		}
+		if err := c.st.Remove(n); err != nil {
+			return errors.Wrapf(err, "can't remove %s", n)
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) match(p, name string, glob bool) bool {
+	if glob {
+		ok, _ := path.Match(p, name)
+		return ok
+	}
+	return strings.HasSuffix(p, "/") || p == name
+}
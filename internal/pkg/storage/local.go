@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/airenas/async-api/pkg/api"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+)
+
+// Local keeps files on the local disk, names are relative to the root dir.
+// If root is empty the names are used as paths
+type Local struct {
+	root string
+}
+
+// NewLocal creates local disk storage
+func NewLocal(root string) (*Local, error) {
+	goapp.Log.Infof("Init local storage at: '%s'", root)
+	return &Local{root: root}, nil
+}
+
+func (l *Local) path(name string) (string, error) {
+	// without the root the configured path may start with ../, so only a step back inside is checked
+	if l.root == "" {
+		if climbs(name) {
+			return "", errors.Errorf("wrong path %s", name)
+		}
+		return name, nil
+	}
+	if climbs("x/" + name) {
+		return "", errors.Errorf("wrong path %s", name)
+	}
+	return filepath.Join(l.root, name), nil
+}
+
+// climbs returns true if the path steps back after a named dir, e.g. a/../b
+func climbs(p string) bool {
+	named := false
+	for _, s := range strings.Split(filepath.ToSlash(p), "/") {
+		switch s {
+		case "..":
+			if named {
+				return true
+			}
+		case "", ".":
+		default:
+			named = true
+		}
+	}
+	return false
+}
+
+// Save writes the file, creates the dir if needed
+func (l *Local) Save(name string, r io.Reader) error {
+	fn, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "can't create dir for %s", fn)
+	}
+	goapp.Log.Infof("Save %s", fn)
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "can't create %s", fn)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return errors.Wrapf(err, "can't save %s", fn)
+	}
+	return nil
+}
+
+// Load opens the file
+func (l *Local) Load(name string) (api.FileRead, error) {
+	fn, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open %s", fn)
+	}
+	return f, nil
+}
+
+// Exists checks if the file exists
+func (l *Local) Exists(name string) (bool, error) {
+	fn, err := l.path(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(fn)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, errors.Wrapf(err, "can't check %s", fn)
+}
+
+// Download returns the absolute path of the file, nothing is copied
+func (l *Local) Download(name, dir string) (string, error) {
+	fn, err := l.path(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(fn); err != nil {
+		return "", errors.Wrapf(err, "can't find %s", fn)
+	}
+	return filepath.Abs(fn)
+}
+
+// Remove deletes the file, a missing file is not an error
+func (l *Local) Remove(name string) error {
+	fn, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't remove %s", fn)
+	}
+	return nil
+}
+
+// Upload moves the file into the storage
+func (l *Local) Upload(name, file string) error {
+	fn, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "can't create dir for %s", fn)
+	}
+	goapp.Log.Infof("Move %s to %s", file, fn)
+	if err := os.Rename(file, fn); err == nil {
+		return nil
+	}
+	// may fail on different devices - copy
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Wrapf(err, "can't open %s", file)
+	}
+	defer f.Close()
+	if err := l.Save(name, f); err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	st, err := NewLocal(dir)
+	assert.Nil(t, err)
+	ok, err := st.Exists("a/1.txt")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, WriteFile(st, "a/1.txt", []byte("olia")))
+	ok, err = st.Exists("a/1.txt")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	b, err := ReadFile(st, "a/1.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "olia", string(b))
+	p, err := st.Download("a/1.txt", "work")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "a/1.txt"), p)
+}
+
+func TestLocal_Fail(t *testing.T) {
+	st, _ := NewLocal(t.TempDir())
+	_, err := st.Load("a/1.txt")
+	assert.NotNil(t, err)
+	_, err = st.Download("a/1.txt", "work")
+	assert.NotNil(t, err)
+	assert.NotNil(t, st.Save("../1.txt", strings.NewReader("olia")))
+	_, err = st.Exists("../1.txt")
+	assert.NotNil(t, err)
+	_, err = st.Exists("a/../../1.txt")
+	assert.NotNil(t, err)
+}
+
+func TestLocal_NoRoot_Fail(t *testing.T) {
+	st, _ := NewLocal("")
+	_, err := st.Exists("../work/id/../../1.txt")
+	assert.NotNil(t, err)
+	assert.NotNil(t, st.Remove("work/../1.txt"))
+}
+
+func Test_climbs(t *testing.T) {
+	assert.False(t, climbs("a/b.txt"))
+	assert.False(t, climbs("../../a/b.txt"))
+	assert.False(t, climbs("./a/b..c"))
+	assert.True(t, climbs("a/../b.txt"))
+	assert.True(t, climbs("../a/../../b.txt"))
+}
+
+func TestLocal_NoRoot(t *testing.T) {
+	dir := t.TempDir()
+	st, _ := NewLocal("")
+	fn := filepath.Join(dir, "in", "1.txt")
+	assert.Nil(t, WriteFile(st, fn, []byte("olia")))
+	b, err := os.ReadFile(fn)
+	assert.Nil(t, err)
+	assert.Equal(t, "olia", string(b))
+}
+
+func TestLocal_Upload(t *testing.T) {
+	dir := t.TempDir()
+	st, _ := NewLocal(dir)
+	tmp := filepath.Join(t.TempDir(), "r.mp3")
+	assert.Nil(t, os.WriteFile(tmp, []byte("mp3"), 0600))
+	assert.Nil(t, st.Upload("res/r.mp3", tmp))
+	f, err := st.Load("res/r.mp3")
+	assert.Nil(t, err)
+	defer f.Close()
+	b, _ := io.ReadAll(f)
+	assert.Equal(t, "mp3", string(b))
+	_, err = os.Stat(tmp)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocal_Remove(t *testing.T) {
+	st, _ := NewLocal(t.TempDir())
+	assert.Nil(t, st.Save("a/1.txt", strings.NewReader("olia")))
+	assert.Nil(t, st.Remove("a/1.txt"))
+	ok, err := st.Exists("a/1.txt")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, st.Remove("a/1.txt"))
+	assert.NotNil(t, st.Remove("../1.txt"))
+}
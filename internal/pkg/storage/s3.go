@@ -0,0 +1,470 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/airenas/async-api/pkg/api"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRegion    = "us-east-1"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	defaultTimeout   = 10 * time.Minute
+)
+
+// S3Options keeps the bucket connection settings
+type S3Options struct {
+	// URL of the service, e.g. http://minio:9000
+	URL       string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Prefix is prepended to all keys
+	Prefix string
+	// Timeout limits a request including the body transfer, default 10m
+	Timeout time.Duration
+}
+
+// S3 keeps files in a S3 compatible bucket, uses path style requests signed with AWS signature v4
+type S3 struct {
+	url       *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string
+
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3 creates S3 storage
+func NewS3(opt S3Options) (*S3, error) {
+	if opt.URL == "" {
+		return nil, errors.New("no S3 URL")
+	}
+	u, err := url.Parse(opt.URL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse URL '%s'", opt.URL)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("wrong S3 URL '%s'", opt.URL)
+	}
+	if opt.Bucket == "" {
+		return nil, errors.New("no S3 bucket")
+	}
+	if opt.AccessKey == "" || opt.SecretKey == "" {
+		return nil, errors.New("no S3 credentials")
+	}
+	res := &S3{url: u, bucket: opt.Bucket, region: opt.Region, accessKey: opt.AccessKey, secretKey: opt.SecretKey,
+		prefix: strings.Trim(opt.Prefix, "/")}
+	if res.region == "" {
+		res.region = defaultRegion
+	}
+	timeout := opt.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	res.httpClient = &http.Client{Timeout: timeout, Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		MaxIdleConns:          40,
+		MaxIdleConnsPerHost:   40,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	}}
+	res.now = time.Now
+	goapp.Log.Infof("Init S3 storage at: %s, bucket: %s, prefix: '%s'", goapp.Sanitize(u.String()), res.bucket, res.prefix)
+	return res, nil
+}
+
+// Save uploads the file
+func (s *S3) Save(name string, r io.Reader) error {
+	br, ok := r.(*bytes.Reader)
+	if !ok {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return errors.Wrapf(err, "can't read %s", name)
+		}
+		br = bytes.NewReader(b)
+	}
+	return s.put(name, br, br.Size())
+}
+
+// Load returns a seekable reader of the object, data is fetched on read
+func (s *S3) Load(name string) (api.FileRead, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.call(http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if err := checkResp(resp, key); err != nil {
+		return nil, err
+	}
+	mt, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &s3File{s3: s, key: key, info: &fileInfo{name: path.Base(key), size: resp.ContentLength, modTime: mt}}, nil
+}
+
+// Exists checks if the object exists
+func (s *S3) Exists(name string) (bool, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.call(http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err := checkResp(resp, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Download copies the object into dir
+func (s *S3) Download(name, dir string) (string, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", errors.Wrapf(err, "can't create %s", dir)
+	}
+	resp, err := s.call(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResp(resp, key); err != nil {
+		return "", err
+	}
+	fn := filepath.Join(dir, path.Base(key))
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't create %s", fn)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", errors.Wrapf(err, "can't download %s", key)
+	}
+	return fn, nil
+}
+
+// Upload puts the local file into the bucket and removes it
+func (s *S3) Upload(name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Wrapf(err, "can't open %s", file)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "can't stat %s", file)
+	}
+	if err := s.put(name, f, fi.Size()); err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// Remove deletes the object
+func (s *S3) Remove(name string) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+	resp, err := s.call(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return checkResp(resp, key)
+}
+
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns names of the objects starting with prefix
+func (s *S3) List(prefix string) ([]string, error) {
+	var res []string
+	kp := s.keyPrefix()
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", kp+prefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.call(http.MethodGet, "", q, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var lr listResult
+		err = checkResp(resp, "list "+prefix)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&lr)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't list %s", prefix)
+		}
+		for _, c := range lr.Contents {
+			res = append(res, strings.TrimPrefix(c.Key, kp))
+		}
+		if !lr.IsTruncated || lr.NextContinuationToken == "" {
+			return res, nil
+		}
+		token = lr.NextContinuationToken
+	}
+}
+
+func (s *S3) keyPrefix() string {
+	if s.prefix == "" {
+		return ""
+	}
+	return s.prefix + "/"
+}
+
+func (s *S3) key(name string) (string, error) {
+	n := path.Clean("/" + filepath.ToSlash(name))
+	if n == "/" || strings.Contains(filepath.ToSlash(name), "../") {
+		return "", errors.Errorf("wrong name '%s'", name)
+	}
+	return s.keyPrefix() + n[1:], nil
+}
+
+func (s *S3) put(name string, body io.Reader, size int64) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+	goapp.Log.Infof("Save s3://%s/%s", s.bucket, key)
+	resp, err := s.call(http.MethodPut, key, nil, body, func(r *http.Request) { r.ContentLength = size })
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return checkResp(resp, key)
+}
+
+func (s *S3) call(method, key string, query url.Values, body io.Reader, prepare func(*http.Request)) (*http.Response, error) {
+	u := *s.url
+	u.Path = "/" + s.bucket + "/" + key
+	u.RawPath = "/" + uriEncode(s.bucket, false) + "/" + uriEncode(key, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't prepare request for '%s'", key)
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+	hash := emptyPayloadHash
+	if body != nil {
+		hash = unsignedPayload
+	}
+	s.sign(req, hash)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't call %s '%s'", method, key)
+	}
+	return resp, nil
+}
+
+func checkResp(resp *http.Response, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1000))
+	return errors.Errorf("s3 '%s' resp code %d: %s", key, resp.StatusCode, string(b))
+}
+
+// sign adds AWS signature v4 authorization header
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	t := s.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "range" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	ch := strings.Builder{}
+	for _, k := range names {
+		ch.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), canonicalQuery(req.URL.Query()),
+		ch.String(), signed, payloadHash}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		s.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func canonicalQuery(q url.Values) string {
+	var res []string
+	for k, vs := range q {
+		for _, v := range vs {
+			res = append(res, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(res)
+	return strings.Join(res, "&")
+}
+
+func uriEncode(s string, encodeSlash bool) string {
+	res := strings.Builder{}
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			res.WriteByte(b)
+		} else {
+			res.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return res.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// s3File reads the object lazily, a new range request is made after seek
+type s3File struct {
+	s3     *S3
+	key    string
+	info   *fileInfo
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		resp, err := f.s3.call(http.MethodGet, f.key, nil, nil,
+			func(r *http.Request) { r.Header.Set("Range", fmt.Sprintf("bytes=%d-", f.offset)) })
+		if err != nil {
+			return 0, err
+		}
+		if err := checkResp(resp, f.key); err != nil {
+			resp.Body.Close()
+			return 0, err
+		}
+		f.body = resp.Body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	var n int64
+	switch whence {
+	case io.SeekStart:
+		n = offset
+	case io.SeekCurrent:
+		n = f.offset + offset
+	case io.SeekEnd:
+		n = f.info.size + offset
+	default:
+		return 0, errors.Errorf("wrong whence %d", whence)
+	}
+	if n < 0 {
+		return 0, errors.New("negative position")
+	}
+	if n != f.offset {
+		f.closeBody()
+		f.offset = n
+	}
+	return n, nil
+}
+
+func (f *s3File) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *s3File) Close() error {
+	f.closeBody()
+	return nil
+}
+
+func (f *s3File) closeBody() {
+	if f.body != nil {
+		_ = f.body.Close()
+		f.body = nil
+	}
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return 0444 }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return false }
+func (fi *fileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/airenas/async-api/pkg/api"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// TypeLocal keeps files on the local disk
+	TypeLocal = "local"
+	// TypeS3 keeps files in a S3 compatible bucket
+	TypeS3 = "s3"
+)
+
+// Storage keeps job files on the local disk or in a S3 compatible bucket
+type Storage interface {
+	// Save writes the file
+	Save(name string, r io.Reader) error
+	// Load opens the file for reading
+	Load(name string) (api.FileRead, error)
+	// Exists checks if the file is saved
+	Exists(name string) (bool, error)
+	// Download makes the file available on the local disk for external tools,
+	// copies it into dir if needed. Returns the local path
+	Download(name, dir string) (string, error)
+	// Upload moves the local file into the storage
+	Upload(name, file string) error
+	// Remove deletes the file, a missing file is not an error
+	Remove(name string) error
+}
+
+// ReadFile loads all file
+func ReadFile(st Storage, name string) ([]byte, error) {
+	f, err := st.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile saves data to the file
+func WriteFile(st Storage, name string, data []byte) error {
+	return st.Save(name, bytes.NewReader(data))
+}
+
+// NewFromConfig creates the storage by 'storage.type': local (default) or s3.
+// root is the base dir for the local storage or the key prefix for the bucket
+func NewFromConfig(cfg *viper.Viper, root string) (Storage, error) {
+	typ := strings.ToLower(strings.TrimSpace(cfg.GetString("storage.type")))
+	goapp.Log.Infof("Storage type '%s'", typ)
+	switch typ {
+	case "", TypeLocal:
+		return NewLocal(root)
+	case TypeS3:
+		return NewS3(S3Options{URL: cfg.GetString("storage.s3.url"),
+			Bucket:    cfg.GetString("storage.s3.bucket"),
+			Region:    cfg.GetString("storage.s3.region"),
+			AccessKey: cfg.GetString("storage.s3.accessKey"),
+			SecretKey: cfg.GetString("storage.s3.secretKey"),
+			Prefix:    root,
+			Timeout:   cfg.GetDuration("storage.s3.timeout")})
+	}
+	return nil, errors.Errorf("unknown storage type '%s'", typ)
+}
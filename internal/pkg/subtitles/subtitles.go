@@ -0,0 +1,217 @@
+package subtitles
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TypeSRT is a SubRip subtitles type
+	TypeSRT = "srt"
+	// TypeVTT is a WebVTT subtitles type
+	TypeVTT = "vtt"
+)
+
+// maxCueChars is a length a long sentence is cut at
+const maxCueChars = 84
+
+// Cue is a text shown from Start to End
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+type segment struct {
+	text  string
+	pause time.Duration
+}
+
+// Align makes cues of the part text which starts at start and lasts duration.
+// SSML pauses keep their durations, the remaining time is distributed over sentences by the chars count
+func Align(text string, start, duration time.Duration) ([]Cue, error) {
+	segments, err := toSegments(text)
+	if err != nil {
+		return nil, err
+	}
+	chars, pauses := 0, time.Duration(0)
+	for _, s := range segments {
+		chars += utf8.RuneCountInString(s.text)
+		pauses += s.pause
+	}
+	speech := duration - pauses
+	pauseScale := 1.0
+	if speech < 0 {
+		speech, pauseScale = 0, float64(duration)/float64(pauses)
+	}
+	var res []Cue
+	at, done, pausesDone := start, 0, time.Duration(0)
+	for _, s := range segments {
+		if s.pause > 0 {
+			p := time.Duration(float64(s.pause) * pauseScale)
+			at += p
+			pausesDone += p
+			continue
+		}
+		done += utf8.RuneCountInString(s.text)
+		end := start + time.Duration(float64(speech)*float64(done)/float64(chars)) + pausesDone
+		res = append(res, Cue{Start: at, End: end, Text: s.text})
+		at = end
+	}
+	return res, nil
+}
+
+func toSegments(text string) ([]segment, error) {
+	if !strings.HasPrefix(text, "<speak") {
+		return textSegments(text), nil
+	}
+	var res []segment
+	d := xml.NewDecoder(strings.NewReader(text))
+	sb := strings.Builder{}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "can't parse SSML")
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.EndElement:
+			if t.Name.Local == "w" {
+				sb.WriteString(" ")
+			}
+		case xml.StartElement:
+			if t.Name.Local == "w" {
+				sb.WriteString(" ")
+			}
+			if t.Name.Local != "break" {
+				continue
+			}
+			res = append(res, textSegments(sb.String())...)
+			sb.Reset()
+			p, err := breakDuration(t)
+			if err != nil {
+				return nil, err
+			}
+			if p > 0 {
+				res = append(res, segment{pause: p})
+			}
+		}
+	}
+	return append(res, textSegments(sb.String())...), nil
+}
+
+func breakDuration(e xml.StartElement) (time.Duration, error) {
+	for _, a := range e.Attr {
+		if a.Name.Local == "time" {
+			res, err := time.ParseDuration(strings.TrimSpace(a.Value))
+			if err != nil {
+				return 0, errors.Wrapf(err, "wrong break time '%s'", a.Value)
+			}
+			return res, nil
+		}
+	}
+	return 0, nil
+}
+
+// textSegments cuts the text into sentences, the long ones are cut at spaces
+func textSegments(text string) []segment {
+	var res []segment
+	for _, s := range sentences(text) {
+		for _, c := range cut(s, maxCueChars) {
+			res = append(res, segment{text: c})
+		}
+	}
+	return res
+}
+
+// spaceBeforePunct matches a space left between a word tag and a punctuation
+var spaceBeforePunct = regexp.MustCompile(` ([.,!?;:…])`)
+
+func sentences(text string) []string {
+	var res []string
+	rns := []rune(text)
+	from := 0
+	add := func(to int) {
+		s := strings.Join(strings.Fields(string(rns[from:to])), " ")
+		if s = spaceBeforePunct.ReplaceAllString(s, "$1"); s != "" {
+			res = append(res, s)
+		}
+		from = to
+	}
+	for i, r := range rns {
+		if r == '\n' {
+			add(i + 1)
+		} else if strings.ContainsRune(".!?…", r) && i+1 < len(rns) && unicode.IsSpace(rns[i+1]) {
+			add(i + 1)
+		}
+	}
+	add(len(rns))
+	return res
+}
+
+func cut(s string, max int) []string {
+	var res []string
+	for utf8.RuneCountInString(s) > max {
+		rns := []rune(s)
+		pos := strings.LastIndex(string(rns[:max+1]), " ")
+		if pos <= 0 {
+			pos = len(string(rns[:max]))
+		}
+		res = append(res, strings.TrimSpace(s[:pos]))
+		s = strings.TrimSpace(s[pos:])
+	}
+	if s != "" {
+		res = append(res, s)
+	}
+	return res
+}
+
+// SRT formats cues as SubRip subtitles
+func SRT(cues []Cue) string {
+	res := strings.Builder{}
+	for i, c := range cues {
+		res.WriteString(fmt.Sprintf("%d\n%s --> %s\n%s\n\n", i+1, timeStr(c.Start, ","), timeStr(c.End, ","), c.Text))
+	}
+	return res.String()
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// VTT formats cues as WebVTT subtitles
+func VTT(cues []Cue) string {
+	res := strings.Builder{}
+	res.WriteString("WEBVTT\n\n")
+	for _, c := range cues {
+		res.WriteString(fmt.Sprintf("%s --> %s\n%s\n\n", timeStr(c.Start, "."), timeStr(c.End, "."),
+			vttEscaper.Replace(c.Text)))
+	}
+	return res.String()
+}
+
+// ContentType returns http content type of the subtitles type, empty if unknown
+func ContentType(typ string) string {
+	switch typ {
+	case TypeSRT:
+		return "application/x-subrip"
+	case TypeVTT:
+		return "text/vtt"
+	}
+	return ""
+}
+
+func timeStr(d time.Duration, msSep string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, msSep, ms%1000)
+}
@@ -0,0 +1,78 @@
+package subtitles
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlign(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		start    time.Duration
+		duration time.Duration
+		want     []Cue
+		wantErr  bool
+	}{
+		{name: "Text", text: "Olia olia. Olia!\nOlia olia olia.", start: time.Second, duration: 3 * time.Second,
+			want: []Cue{{Start: time.Second, End: 2 * time.Second, Text: "Olia olia."},
+				{Start: 2 * time.Second, End: 2500 * time.Millisecond, Text: "Olia!"},
+				{Start: 2500 * time.Millisecond, End: 4 * time.Second, Text: "Olia olia olia."}}},
+		{name: "Decimal", text: "1.5 olia", duration: time.Second,
+			want: []Cue{{End: time.Second, Text: "1.5 olia"}}},
+		{name: "SSML", text: `<speak><voice name="a"><prosody rate="100%">Olia olia.</prosody></voice>` +
+			`<break time="1000ms"/><voice name="a"><prosody rate="100%">Olia<intelektika:w acc="o{/}lia">olia</intelektika:w>.` +
+			`</prosody></voice></speak>`, duration: 3 * time.Second,
+			want: []Cue{{End: time.Second, Text: "Olia olia."},
+				{Start: 2 * time.Second, End: 3 * time.Second, Text: "Olia olia."}}},
+		{name: "Pauses too long", text: `<speak>Olia<break time="2s"/></speak>`, duration: time.Second,
+			want: []Cue{{End: 0, Text: "Olia"}}},
+		{name: "Empty", text: " ", duration: time.Second},
+		{name: "Wrong SSML", text: `<speak>Olia</spk>`, wantErr: true},
+		{name: "Wrong break", text: `<speak>Olia<break time="2x"/></speak>`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Align(tt.text, tt.start, tt.duration)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAlign_Long(t *testing.T) {
+	got, err := Align(strings.Repeat("olia ", 30), 0, 10*time.Second)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(got)) {
+		assert.Equal(t, strings.TrimSpace(strings.Repeat("olia ", 17)), got[0].Text)
+		assert.Equal(t, got[0].End, got[1].Start)
+		assert.Equal(t, 10*time.Second, got[1].End)
+	}
+}
+
+func Test_cut(t *testing.T) {
+	assert.Equal(t, []string{"olia", "olia"}, cut("olia olia", 5))
+	assert.Equal(t, []string{"olia olia"}, cut("olia olia", 9))
+	assert.Equal(t, []string{"oli", "a"}, cut("olia", 3))
+	assert.Equal(t, []string{"ąčę", "ąčę"}, cut("ąčę ąčę", 4))
+}
+
+func TestSRT(t *testing.T) {
+	got := SRT([]Cue{{End: 1500 * time.Millisecond, Text: "Olia."},
+		{Start: 1500 * time.Millisecond, End: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, Text: "a < b"}})
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,500\nOlia.\n\n2\n00:00:01,500 --> 01:02:03,004\na < b\n\n", got)
+}
+
+func TestVTT(t *testing.T) {
+	got := VTT([]Cue{{End: 1500 * time.Millisecond, Text: "a < b & c"}})
+	assert.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:01.500\na &lt; b &amp; c\n\n", got)
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "application/x-subrip", ContentType("srt"))
+	assert.Equal(t, "text/vtt", ContentType("vtt"))
+	assert.Equal(t, "", ContentType("mp3"))
+}
@@ -0,0 +1,126 @@
+package synthesize
+
+import (
+	"context"
+	"strings"
+
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/status"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+// Stage is a named step of the pipeline. The stage listens its own queue, saves the status with the stage name,
+// runs the worker and passes the message to the next stage
+type Stage struct {
+	Name   string
+	Queue  string
+	Ch     <-chan amqp.Delivery
+	Worker Worker
+	StageOptions
+}
+
+// StageOptions configures the handling of the canceled jobs by the stage
+type StageOptions struct {
+	// RestorePart is the part of the usage to restore if the job is canceled before the stage
+	RestorePart float64
+	// WatchCancel cancels the worker's context once the job is canceled
+	WatchCancel bool
+}
+
+// WorkerFactory creates the worker of the stage
+type WorkerFactory func() (Worker, error)
+
+// DefaultStages is the default pipeline: Split→Synthesize→Join
+var DefaultStages = []string{status.Split.String(), status.Synthesize.String(), status.Join.String()}
+
+// reserved names are used for the other queues and statuses
+var reservedNames = map[string]bool{"Upload": true, "Fail": true, "Inform": true, "Callback": true,
+	status.Uploaded.String(): true, status.Completed.String(): true, status.Cancelled.String(): true}
+
+// Registry keeps the known stages, the pipeline is made of them by names
+type Registry struct {
+	stages map[string]*registeredStage
+}
+
+type registeredStage struct {
+	factory WorkerFactory
+	options StageOptions
+}
+
+// NewRegistry creates an empty stage registry
+func NewRegistry() *Registry {
+	return &Registry{stages: map[string]*registeredStage{}}
+}
+
+// Register adds the stage, the factory is invoked only if the stage is used in the pipeline
+func (r *Registry) Register(name string, f WorkerFactory, opt StageOptions) error {
+	if name == "" || strings.TrimSpace(name) != name || reservedNames[name] {
+		return errors.Errorf("wrong stage name '%s'", name)
+	}
+	if r.stages[name] != nil {
+		return errors.Errorf("stage '%s' is already registered", name)
+	}
+	if f == nil {
+		return errors.Errorf("no worker factory for stage '%s'", name)
+	}
+	if opt.RestorePart < 0 || opt.RestorePart > 1 {
+		return errors.Errorf("wrong restore part %g for stage '%s', expected [0, 1]", opt.RestorePart, name)
+	}
+	r.stages[name] = &registeredStage{factory: f, options: opt}
+	return nil
+}
+
+// NewStages makes the pipeline of the named registered stages and creates their workers
+func (r *Registry) NewStages(names []string) ([]*Stage, error) {
+	if len(names) == 0 {
+		return nil, errors.New("no stages")
+	}
+	var res []*Stage
+	used := map[string]bool{}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		rs := r.stages[n]
+		if rs == nil || used[n] {
+			return nil, errors.Errorf("wrong stage name '%s'", n)
+		}
+		used[n] = true
+		w, err := rs.factory()
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't init stage '%s'", n)
+		}
+		if w == nil {
+			return nil, errors.Errorf("no worker for stage '%s'", n)
+		}
+		res = append(res, &Stage{Name: n, Queue: messages.StageQueue(n), Worker: w, StageOptions: rs.options})
+	}
+	goapp.Log.Infof("Pipeline: %s", strings.Join(names, "→"))
+	return res, nil
+}
+
+// stageFunc returns the processing function of the stage i
+func stageFunc(stages []*Stage, i int) prFunc {
+	s := stages[i]
+	return func(message *messages.TTSMessage, data *ServiceData) (bool, error) {
+		goapp.Log.Infof("Got %s msg :%s", s.Queue, message.ID)
+		err := data.StatusSaver.Save(message.ID, s.Name, "")
+		if err != nil {
+			return true, canceledErr(err, s.RestorePart)
+		}
+		resMsg := messages.NewMessageFrom(message)
+		ctx, cancelF := context.WithCancelCause(data.StopCtx)
+		defer cancelF(nil)
+		if s.WatchCancel && data.CancelCheckInterval > 0 {
+			go watchCancel(ctx, cancelF, message.ID, data)
+		}
+		err = s.Worker.Do(ctx, message)
+		if err != nil {
+			return true, err
+		}
+		if i+1 < len(stages) {
+			return true, data.MsgSender.Send(resMsg, stages[i+1].Queue, "")
+		}
+		return true, complete(message, data)
+	}
+}
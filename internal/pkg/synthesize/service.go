@@ -25,27 +25,33 @@ type MsgSender interface {
 	Send(msg amessages.Message, queue, replyQueue string) error
 }
 
-//StatusSaver persists data to DB
+//StatusSaver persists data to DB, returns utils.ErrJobCanceled if the job is canceled
 type StatusSaver interface {
 	Save(ID string, status, err string) error
 }
 
+// CancelChecker checks if the job is canceled by the user
+type CancelChecker interface {
+	IsCanceled(ID string) (bool, error)
+}
+
 // ServiceData keeps data required for service work
 type ServiceData struct {
 	MsgSender       MsgSender
 	InformMsgSender MsgSender
 	StatusSaver     StatusSaver
 	UploadCh        <-chan amqp.Delivery
-	SplitCh         <-chan amqp.Delivery
-	SynthesizeCh    <-chan amqp.Delivery
-	JoinCh          <-chan amqp.Delivery
 	RestoreUsageCh  <-chan amqp.Delivery
 
-	Splitter      Worker
-	Synthesizer   Worker
-	Joiner        Worker
+	// Stages of the pipeline in the processing order
+	Stages        []*Stage
 	UsageRestorer Worker
 
+	CancelChecker CancelChecker
+	// CancelCheckInterval is a period to check if the job is canceled while synthesizing,
+	// if zero - the job is checked only at the start of each stage
+	CancelCheckInterval time.Duration
+
 	StopCtx context.Context
 }
 
@@ -68,11 +74,11 @@ func StartWorkerService(ctx context.Context, data *ServiceData) (<-chan struct{}
 		wg.Done()
 	}
 
-	wg.Add(5)
-	go listenQueue(ctxInt, data.UploadCh, listenUpload, data, cf)
-	go listenQueue(ctxInt, data.SplitCh, split, data, cf)
-	go listenQueue(ctxInt, data.SynthesizeCh, synthesize, data, cf)
-	go listenQueue(ctxInt, data.JoinCh, join, data, cf)
+	wg.Add(len(data.Stages) + 2)
+	go listenQueue(ctxInt, data.UploadCh, skipCanceled(listenUpload, 1), data, cf)
+	for i, s := range data.Stages {
+		go listenQueue(ctxInt, s.Ch, skipCanceled(stageFunc(data.Stages, i), s.RestorePart), data, cf)
+	}
 	go listenQueue(ctxInt, data.RestoreUsageCh, restoreUsage, data, cf)
 
 	return prepareCloseCh(wg), nil
@@ -82,14 +88,16 @@ func validate(data *ServiceData) error {
 	if data.UploadCh == nil {
 		return errors.New("no upload channel provided")
 	}
-	if data.SplitCh == nil {
-		return errors.New("no split channel provided")
+	if len(data.Stages) == 0 {
+		return errors.New("no stages provided")
 	}
-	if data.SynthesizeCh == nil {
-		return errors.New("no synthesize channel provided")
-	}
-	if data.JoinCh == nil {
-		return errors.New("no join channel provided")
+	for _, s := range data.Stages {
+		if s.Ch == nil {
+			return errors.Errorf("no %s channel provided", s.Name)
+		}
+		if s.Worker == nil {
+			return errors.Errorf("no %s worker set", s.Name)
+		}
 	}
 	if data.RestoreUsageCh == nil {
 		return errors.New("no restore usage channel provided")
@@ -103,18 +111,12 @@ func validate(data *ServiceData) error {
 	if data.StatusSaver == nil {
 		return errors.New("no statusSaver")
 	}
-	if data.Splitter == nil {
-		return errors.New("no splitter set")
-	}
-	if data.Synthesizer == nil {
-		return errors.New("no synthesizer set")
-	}
-	if data.Joiner == nil {
-		return errors.New("no joiner set")
-	}
 	if data.UsageRestorer == nil {
 		return errors.New("no usage restorer set")
 	}
+	if data.CancelChecker == nil {
+		return errors.New("no cancel checker set")
+	}
 	return nil
 }
 
@@ -157,6 +159,10 @@ func processMsg(d *amqp.Delivery, f prFunc, data *ServiceData) error {
 		return errors.Wrap(err, "can't unmarshal message "+string(d.Body))
 	}
 	redeliver, err := f(&message, data)
+	var errCanceled *utils.ErrCanceled
+	if errors.As(err, &errCanceled) {
+		return dropCanceled(d, &message, errCanceled, data)
+	}
 	if err != nil {
 		goapp.Log.Errorf("Can't process message %s\n%s", d.MessageId, string(d.Body))
 		goapp.Log.Error(err)
@@ -192,6 +198,70 @@ func processMsg(d *amqp.Delivery, f prFunc, data *ServiceData) error {
 	return d.Ack(false)
 }
 
+// dropCanceled removes the canceled job from the queue, informs the user and restores the unspent usage
+func dropCanceled(d *amqp.Delivery, message *messages.TTSMessage, err *utils.ErrCanceled, data *ServiceData) error {
+	goapp.Log.Infof("Job %s is canceled, drop it", message.ID)
+	if errInt := data.InformMsgSender.Send(newInformMessage(message, messages.InformTypeCanceled),
+		messages.Inform, ""); errInt != nil {
+		goapp.Log.Error(errInt)
+	}
+	if err.Part > 0 {
+		failMsg := messages.NewMessageFrom(message)
+		failMsg.Error = err.Error()
+		failMsg.RestorePart = err.Part
+		if errInt := data.MsgSender.Send(failMsg, messages.Fail, ""); errInt != nil {
+			goapp.Log.Error(errInt)
+		}
+	}
+	return d.Ack(false)
+}
+
+// skipCanceled wraps the stage function to check if the job is not canceled.
+// part is the part of the usage to restore if the job is canceled before the stage
+func skipCanceled(f prFunc, part float64) prFunc {
+	return func(message *messages.TTSMessage, data *ServiceData) (bool, error) {
+		canceled, err := data.CancelChecker.IsCanceled(message.ID)
+		if err != nil {
+			return true, errors.Wrap(err, "can't check if canceled")
+		}
+		if canceled {
+			return false, utils.NewErrCanceled(part)
+		}
+		return f(message, data)
+	}
+}
+
+// watchCancel cancels the context with utils.ErrJobCanceled once the job is canceled
+func watchCancel(ctx context.Context, cancelF context.CancelCauseFunc, ID string, data *ServiceData) {
+	ticker := time.NewTicker(data.CancelCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			canceled, err := data.CancelChecker.IsCanceled(ID)
+			if err != nil {
+				goapp.Log.Warn(errors.Wrap(err, "can't check if canceled"))
+				continue
+			}
+			if canceled {
+				goapp.Log.Infof("Job %s is canceled, stopping", ID)
+				cancelF(utils.ErrJobCanceled)
+				return
+			}
+		}
+	}
+}
+
+// canceledErr converts the error of the status saving for the canceled job to utils.ErrCanceled
+func canceledErr(err error, part float64) error {
+	if errors.Is(err, utils.ErrJobCanceled) {
+		return utils.NewErrCanceled(part)
+	}
+	return err
+}
+
 func needToRestoreUsage(err error) bool {
 	var errTest *utils.ErrNonRestorableUsage
 	return !errors.As(err, &errTest)
@@ -201,63 +271,27 @@ func needToRestoreUsage(err error) bool {
 // workflow:
 // 1. set status to WORKING
 // 2. send inform msg
-// 3. Send split msg
+// 3. Send msg to the first stage
 func listenUpload(message *messages.TTSMessage, data *ServiceData) (bool, error) {
 	goapp.Log.Infof("Got %s msg :%s", messages.Upload, message.ID)
 	err := data.StatusSaver.Save(message.ID, status.Uploaded.String(), "")
 	if err != nil {
-		return true, err
+		return true, canceledErr(err, 1)
 	}
 	err = data.InformMsgSender.Send(newInformMessage(message, amessages.InformTypeStarted), messages.Inform, "")
 	if err != nil {
 		return true, err
 	}
-	return true, data.MsgSender.Send(messages.NewMessageFrom(message), messages.Split, "")
-}
-
-func split(message *messages.TTSMessage, data *ServiceData) (bool, error) {
-	goapp.Log.Infof("Got %s msg :%s", messages.Split, message.ID)
-	err := data.StatusSaver.Save(message.ID, status.Split.String(), "")
-	if err != nil {
-		return true, err
-	}
-	resMsg := messages.NewMessageFrom(message)
-	err = data.Splitter.Do(data.StopCtx, message)
-	if err != nil {
-		return true, err
-	}
-	return true, data.MsgSender.Send(resMsg, messages.Synthesize, "")
+	return true, data.MsgSender.Send(messages.NewMessageFrom(message), data.Stages[0].Queue, "")
 }
 
-func synthesize(message *messages.TTSMessage, data *ServiceData) (bool, error) {
-	goapp.Log.Infof("Got %s msg :%s", messages.Synthesize, message.ID)
-	err := data.StatusSaver.Save(message.ID, status.Synthesize.String(), "")
+// complete marks the job as completed after the last stage
+func complete(message *messages.TTSMessage, data *ServiceData) error {
+	err := data.StatusSaver.Save(message.ID, status.Completed.String(), "")
 	if err != nil {
-		return true, err
-	}
-	resMsg := messages.NewMessageFrom(message)
-	err = data.Synthesizer.Do(data.StopCtx, message)
-	if err != nil {
-		return true, err
-	}
-	return true, data.MsgSender.Send(resMsg, messages.Join, "")
-}
-
-func join(message *messages.TTSMessage, data *ServiceData) (bool, error) {
-	goapp.Log.Infof("Got %s msg :%s", messages.Join, message.ID)
-	err := data.StatusSaver.Save(message.ID, status.Join.String(), "")
-	if err != nil {
-		return true, err
-	}
-	err = data.Joiner.Do(data.StopCtx, message)
-	if err != nil {
-		return true, err
-	}
-	err = data.StatusSaver.Save(message.ID, status.Completed.String(), "")
-	if err != nil {
-		return true, err
+		return canceledErr(err, 0)
 	}
-	return true, data.InformMsgSender.Send(newInformMessage(message, amessages.InformTypeFinished), messages.Inform, "")
+	return data.InformMsgSender.Send(newInformMessage(message, amessages.InformTypeFinished), messages.Inform, "")
 }
 
 func restoreUsage(message *messages.TTSMessage, data *ServiceData) (bool, error) {
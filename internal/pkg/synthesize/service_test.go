@@ -34,6 +34,7 @@ var (
 	tSynthesizeWrk *mocks.MockWorker
 	tJoinWrk       *mocks.MockWorker
 	tRestoreWrk    *mocks.MockWorker
+	tCancelMock    *mocks.MockCancelChecker
 )
 
 func initTest(t *testing.T) {
@@ -47,6 +48,7 @@ func initTest(t *testing.T) {
 	tSynthesizeWrk = mocks.NewMockWorker()
 	tJoinWrk = mocks.NewMockWorker()
 	tRestoreWrk = mocks.NewMockWorker()
+	tCancelMock = mocks.NewMockCancelChecker()
 
 	tUploadCh = make(chan amqp.Delivery)
 	tSplitCh = make(chan amqp.Delivery)
@@ -54,11 +56,14 @@ func initTest(t *testing.T) {
 	tJoinCh = make(chan amqp.Delivery)
 	tRestoreCh = make(chan amqp.Delivery)
 
-	tData = &ServiceData{UploadCh: tUploadCh, SplitCh: tSplitCh,
-		SynthesizeCh: tSynthesizeCh, JoinCh: tJoinCh, MsgSender: tMsgSender,
-		InformMsgSender: tInfSender, StatusSaver: tStatusMock, Splitter: tSplitWrk,
-		Synthesizer: tSynthesizeWrk, Joiner: tJoinWrk,
-		RestoreUsageCh: tRestoreCh, UsageRestorer: tRestoreWrk}
+	stages, err := newTestRegistry(t, map[string]Worker{"Split": tSplitWrk, "Synthesize": tSynthesizeWrk,
+		"Join": tJoinWrk}).NewStages(DefaultStages)
+	require.Nil(t, err)
+	stages[0].Ch, stages[1].Ch, stages[2].Ch = tSplitCh, tSynthesizeCh, tJoinCh
+
+	tData = &ServiceData{UploadCh: tUploadCh, Stages: stages, MsgSender: tMsgSender,
+		InformMsgSender: tInfSender, StatusSaver: tStatusMock,
+		RestoreUsageCh: tRestoreCh, UsageRestorer: tRestoreWrk, CancelChecker: tCancelMock}
 	tData.StopCtx = tCtx
 }
 
@@ -101,7 +106,9 @@ func Test_UploadMsg(t *testing.T) {
 	waitT(t, ch)
 
 	tStatusMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())
-	tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+	_, queue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Split, queue)
 	tInfSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
 }
 
@@ -208,8 +215,12 @@ func Test_SplitMsg(t *testing.T) {
 	close(tSplitCh)
 	waitT(t, ch)
 
-	tStatusMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())
-	tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+	_, st, _ := tStatusMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "Split", st)
+	_, queue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Synthesize, queue)
 	tSplitWrk.VerifyWasCalledOnce().Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
 }
 
@@ -243,10 +254,150 @@ func Test_SynthesizeMsg(t *testing.T) {
 	waitT(t, ch)
 
 	tStatusMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())
-	tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+	_, queue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Join, queue)
 	tSynthesizeWrk.VerifyWasCalledOnce().Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
 }
 
+func Test_SynthesizeMsg_Canceled(t *testing.T) {
+	initTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+	pegomock.When(tCancelMock.IsCanceled(pegomock.Any[string]())).ThenReturn(true, nil)
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa", RequestID: "rID"}
+	msgdata, _ := json.Marshal(msg)
+
+	tSynthesizeCh <- amqp.Delivery{Body: msgdata}
+	close(tSynthesizeCh)
+	waitT(t, ch)
+
+	tStatusMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())
+	tSynthesizeWrk.VerifyWasCalled(pegomock.Never()).Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
+	iMsg, iQueue, _ := tInfSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Inform, iQueue)
+	assert.Equal(t, messages.InformTypeCanceled, iMsg.(*amessages.InformMessage).Type)
+	fMsg, fQueue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Fail, fQueue)
+	assert.Equal(t, "rID", fMsg.(*messages.TTSMessage).RequestID)
+	assert.InDelta(t, 1, fMsg.(*messages.TTSMessage).RestorePart, 0.0001)
+}
+
+func Test_SynthesizeMsg_CanceledWhileWorking(t *testing.T) {
+	initTest(t)
+	tData.CancelCheckInterval = time.Millisecond
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+	pegomock.When(tCancelMock.IsCanceled(pegomock.Any[string]())).ThenReturn(false, nil).ThenReturn(true, nil)
+	pegomock.When(tSynthesizeWrk.Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())).Then(
+		func(params []pegomock.Param) pegomock.ReturnValues {
+			ctx := params[0].(context.Context)
+			<-ctx.Done()
+			assert.Equal(t, utils.ErrJobCanceled, context.Cause(ctx))
+			return []pegomock.ReturnValue{utils.NewErrCanceled(0.4)}
+		})
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa"}
+	msgdata, _ := json.Marshal(msg)
+
+	tSynthesizeCh <- amqp.Delivery{Body: msgdata}
+	close(tSynthesizeCh)
+	waitT(t, ch)
+
+	fMsg, fQueue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Fail, fQueue)
+	assert.InDelta(t, 0.4, fMsg.(*messages.TTSMessage).RestorePart, 0.0001)
+}
+
+func Test_SynthesizeMsg_CanceledOnSave(t *testing.T) {
+	initTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+	pegomock.When(tStatusMock.Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())).
+		ThenReturn(utils.ErrJobCanceled)
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa"}
+	msgdata, _ := json.Marshal(msg)
+
+	tSynthesizeCh <- amqp.Delivery{Body: msgdata}
+	close(tSynthesizeCh)
+	waitT(t, ch)
+
+	tStatusMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())
+	tSynthesizeWrk.VerifyWasCalled(pegomock.Never()).Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
+	iMsg, _, _ := tInfSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.InformTypeCanceled, iMsg.(*amessages.InformMessage).Type)
+	fMsg, fQueue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Fail, fQueue)
+	assert.InDelta(t, 1, fMsg.(*messages.TTSMessage).RestorePart, 0.0001)
+}
+
+func Test_JoinMsg_CanceledOnComplete(t *testing.T) {
+	initTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+	pegomock.When(tStatusMock.Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())).
+		ThenReturn(nil).ThenReturn(utils.ErrJobCanceled)
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa"}
+	msgdata, _ := json.Marshal(msg)
+
+	tJoinCh <- amqp.Delivery{Body: msgdata}
+	close(tJoinCh)
+	waitT(t, ch)
+
+	tJoinWrk.VerifyWasCalledOnce().Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
+	iMsg, _, _ := tInfSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.InformTypeCanceled, iMsg.(*amessages.InformMessage).Type)
+	tMsgSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func Test_UploadMsg_CanceledOnSave(t *testing.T) {
+	initTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+	pegomock.When(tStatusMock.Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())).
+		ThenReturn(utils.ErrJobCanceled)
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa"}
+	msgdata, _ := json.Marshal(msg)
+
+	tUploadCh <- amqp.Delivery{Body: msgdata}
+	close(tUploadCh)
+	waitT(t, ch)
+
+	iMsg, _, _ := tInfSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.InformTypeCanceled, iMsg.(*amessages.InformMessage).Type)
+	_, fQueue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Fail, fQueue)
+}
+
+func Test_JoinMsg_Canceled(t *testing.T) {
+	initTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+	pegomock.When(tCancelMock.IsCanceled(pegomock.Any[string]())).ThenReturn(true, nil)
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa"}
+	msgdata, _ := json.Marshal(msg)
+
+	tJoinCh <- amqp.Delivery{Body: msgdata}
+	close(tJoinCh)
+	waitT(t, ch)
+
+	tJoinWrk.VerifyWasCalled(pegomock.Never()).Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
+	tMsgSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+}
+
 func Test_JoinMsg(t *testing.T) {
 	initTest(t)
 	ch, err := StartWorkerService(tCtx, tData)
@@ -259,11 +410,152 @@ func Test_JoinMsg(t *testing.T) {
 	close(tJoinCh)
 	waitT(t, ch)
 
-	tStatusMock.VerifyWasCalled(pegomock.Twice()).Save(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string]())
+	_, sts, _ := tStatusMock.VerifyWasCalled(pegomock.Twice()).Save(pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetAllCapturedArguments()
+	assert.Equal(t, []string{"Join", "COMPLETED"}, sts)
 	tInfSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+	tMsgSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
 	tJoinWrk.VerifyWasCalledOnce().Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
 }
 
+func initCustomStageTest(t *testing.T) (*mocks.MockWorker, chan amqp.Delivery) {
+	t.Helper()
+	initTest(t)
+	wrk := mocks.NewMockWorker()
+	stages, err := newTestRegistry(t, map[string]Worker{"Split": tSplitWrk, "Synthesize": tSynthesizeWrk,
+		"Join": tJoinWrk, "Loudness": wrk}).NewStages([]string{"Split", "Synthesize", "Join", "Loudness"})
+	require.Nil(t, err)
+	loudnessCh := make(chan amqp.Delivery)
+	stages[0].Ch, stages[1].Ch, stages[2].Ch, stages[3].Ch = tSplitCh, tSynthesizeCh, tJoinCh, loudnessCh
+	tData.Stages = stages
+	return wrk, loudnessCh
+}
+
+func Test_CustomStage_Next(t *testing.T) {
+	_, _ = initCustomStageTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa"}
+	msgdata, _ := json.Marshal(msg)
+	tJoinCh <- amqp.Delivery{Body: msgdata}
+	close(tJoinCh)
+	waitT(t, ch)
+
+	_, st, _ := tStatusMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "Join", st)
+	_, queue, _ := tMsgSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "BigTTS/Loudness", queue)
+	tInfSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func Test_CustomStage_Last(t *testing.T) {
+	wrk, loudnessCh := initCustomStageTest(t)
+	ch, err := StartWorkerService(tCtx, tData)
+	require.Nil(t, err)
+
+	msg := messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "olia"}, Voice: "aa"}
+	msgdata, _ := json.Marshal(msg)
+	loudnessCh <- amqp.Delivery{Body: msgdata}
+	close(loudnessCh)
+	waitT(t, ch)
+
+	_, sts, _ := tStatusMock.VerifyWasCalled(pegomock.Twice()).Save(pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetAllCapturedArguments()
+	assert.Equal(t, []string{"Loudness", "COMPLETED"}, sts)
+	wrk.VerifyWasCalledOnce().Do(pegomock.Any[context.Context](), pegomock.Any[*messages.TTSMessage]())
+	tMsgSender.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+	tInfSender.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+}
+
+// newTestRegistry registers the workers, the stages till Synthesize restore the usage, Synthesize watches cancel
+func newTestRegistry(t *testing.T, workers map[string]Worker) *Registry {
+	t.Helper()
+	res := NewRegistry()
+	options := map[string]StageOptions{"Split": {RestorePart: 1}, "Synthesize": {RestorePart: 1, WatchCancel: true}}
+	for n, w := range workers {
+		w := w
+		require.Nil(t, res.Register(n, func() (Worker, error) { return w, nil }, options[n]))
+	}
+	return res
+}
+
+func TestRegistry_NewStages(t *testing.T) {
+	w := mocks.NewMockWorker()
+	r := newTestRegistry(t, map[string]Worker{"Split": w, "Synthesize": w, "Join": w, "Loudness": w})
+	got, err := r.NewStages([]string{"Split", " Synthesize ", "Join", "Loudness"})
+	require.Nil(t, err)
+	require.Equal(t, 4, len(got))
+	assert.Equal(t, []string{"Split", "Synthesize", "Join", "Loudness"}, []string{got[0].Name, got[1].Name, got[2].Name, got[3].Name})
+	assert.Equal(t, []string{messages.Split, messages.Synthesize, messages.Join, "BigTTS/Loudness"},
+		[]string{got[0].Queue, got[1].Queue, got[2].Queue, got[3].Queue})
+	assert.Equal(t, []float64{1, 1, 0, 0}, []float64{got[0].RestorePart, got[1].RestorePart, got[2].RestorePart, got[3].RestorePart})
+	assert.Equal(t, []bool{false, true, false, false}, []bool{got[0].WatchCancel, got[1].WatchCancel, got[2].WatchCancel, got[3].WatchCancel})
+}
+
+func TestRegistry_NewStages_CreatesUsedOnly(t *testing.T) {
+	r := NewRegistry()
+	calls := 0
+	require.Nil(t, r.Register("Split", func() (Worker, error) { calls++; return mocks.NewMockWorker(), nil }, StageOptions{}))
+	require.Nil(t, r.Register("Join", func() (Worker, error) { return nil, errors.New("olia") }, StageOptions{}))
+	got, err := r.NewStages([]string{"Split"})
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, 1, calls)
+	_, err = r.NewStages([]string{"Split", "Join"})
+	assert.NotNil(t, err)
+}
+
+func TestRegistry_NewStages_Fail(t *testing.T) {
+	w := mocks.NewMockWorker()
+	r := newTestRegistry(t, map[string]Worker{"Split": w, "Synthesize": w, "Join": w})
+	require.Nil(t, r.Register("Nil", func() (Worker, error) { return nil, nil }, StageOptions{}))
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "Empty"},
+		{name: "Not registered", args: []string{"Split", "Loudness"}},
+		{name: "Duplicate", args: []string{"Split", "Split"}},
+		{name: "Empty name", args: []string{"Split", " "}},
+		{name: "No worker", args: []string{"Nil"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.NewStages(tt.args)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestRegistry_Register_Fail(t *testing.T) {
+	f := func() (Worker, error) { return mocks.NewMockWorker(), nil }
+	tests := []struct {
+		name  string
+		stage string
+		f     WorkerFactory
+		opt   StageOptions
+	}{
+		{name: "Empty name", stage: "", f: f},
+		{name: "Spaces", stage: " Split", f: f},
+		{name: "Queue name", stage: "Upload", f: f},
+		{name: "Status name", stage: "COMPLETED", f: f},
+		{name: "Registered", stage: "Split", f: f},
+		{name: "No factory", stage: "Loudness"},
+		{name: "Wrong restore part", stage: "Loudness", f: f, opt: StageOptions{RestorePart: 1.1}},
+		{name: "Negative restore part", stage: "Loudness", f: f, opt: StageOptions{RestorePart: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			require.Nil(t, r.Register("Split", f, StageOptions{}))
+			assert.NotNil(t, r.Register(tt.stage, tt.f, tt.opt))
+		})
+	}
+}
+
 func Test_RestoreMsg(t *testing.T) {
 	initTest(t)
 	ch, err := StartWorkerService(tCtx, tData)
@@ -287,26 +579,25 @@ func Test_validate(t *testing.T) {
 	}{
 		{name: "OK", args: func(sd *ServiceData) {}, wantErr: false},
 		{name: "Fail", args: func(sd *ServiceData) { sd.UploadCh = nil }, wantErr: true},
-		{name: "Fail", args: func(sd *ServiceData) { sd.SplitCh = nil }, wantErr: true},
-		{name: "Fail", args: func(sd *ServiceData) { sd.SynthesizeCh = nil }, wantErr: true},
-		{name: "Fail", args: func(sd *ServiceData) { sd.JoinCh = nil }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { sd.Stages = nil }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { sd.Stages[0].Ch = nil }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { sd.Stages[1].Worker = nil }, wantErr: true},
 		{name: "Fail", args: func(sd *ServiceData) { sd.MsgSender = nil }, wantErr: true},
 		{name: "Fail", args: func(sd *ServiceData) { sd.InformMsgSender = nil }, wantErr: true},
 		{name: "Fail", args: func(sd *ServiceData) { sd.StatusSaver = nil }, wantErr: true},
-		{name: "Fail", args: func(sd *ServiceData) { sd.Splitter = nil }, wantErr: true},
-		{name: "Fail", args: func(sd *ServiceData) { sd.Synthesizer = nil }, wantErr: true},
-		{name: "Fail", args: func(sd *ServiceData) { sd.Joiner = nil }, wantErr: true},
 		{name: "Fail", args: func(sd *ServiceData) { sd.RestoreUsageCh = nil }, wantErr: true},
 		{name: "Fail", args: func(sd *ServiceData) { sd.UsageRestorer = nil }, wantErr: true},
+		{name: "Fail", args: func(sd *ServiceData) { sd.CancelChecker = nil }, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			d := &ServiceData{UploadCh: make(<-chan amqp.Delivery), SplitCh: make(<-chan amqp.Delivery),
-				SynthesizeCh: make(<-chan amqp.Delivery), JoinCh: make(<-chan amqp.Delivery),
+			d := &ServiceData{UploadCh: make(<-chan amqp.Delivery),
+				Stages: []*Stage{{Name: "Split", Ch: make(<-chan amqp.Delivery), Worker: mocks.NewMockWorker()},
+					{Name: "Join", Ch: make(<-chan amqp.Delivery), Worker: mocks.NewMockWorker()}},
 				RestoreUsageCh: make(<-chan amqp.Delivery), UsageRestorer: mocks.NewMockWorker(),
 				MsgSender:       mocks.NewMockMsgSender(),
-				InformMsgSender: mocks.NewMockMsgSender(), StatusSaver: mocks.NewMockStatusSaver(), Splitter: mocks.NewMockWorker(),
-				Synthesizer: mocks.NewMockWorker(), Joiner: mocks.NewMockWorker()}
+				InformMsgSender: mocks.NewMockMsgSender(), StatusSaver: mocks.NewMockStatusSaver(),
+				CancelChecker: mocks.NewMockCancelChecker()}
 			tt.args(d)
 			if err := validate(d); (err != nil) != tt.wantErr {
 				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
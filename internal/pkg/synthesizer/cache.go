@@ -0,0 +1,214 @@
+package synthesizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/airenas/big-tts/internal/pkg/audio"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tts_synthesize_cache_requests_total",
+		Help: "Synthesized parts cache requests by result: hit or miss",
+	}, []string{"result"})
+	cacheSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tts_synthesize_cache_size_bytes",
+		Help: "Synthesized parts cache size",
+	})
+)
+
+// CacheCollectors returns the cache metrics, the service registers them
+func CacheCollectors() []prometheus.Collector {
+	return []prometheus.Collector{cacheRequests, cacheSize}
+}
+
+// PartCache keeps synthesized parts to reuse them across jobs
+type PartCache interface {
+	Get(text string, msg *messages.TTSMessage) ([]byte, bool)
+	Put(text string, msg *messages.TTSMessage, data []byte) error
+}
+
+// Cache keeps synthesized parts on the local disk by the hash of the text, voice, speed,
+// format and backend version. The least recently used files are evicted if the cache
+// exceeds maxSize, files not used for ttl are expired
+type Cache struct {
+	dir     string
+	maxSize int64
+	ttl     time.Duration
+	version string
+
+	lock  sync.Mutex
+	items map[string]*cacheItem
+	size  int64
+	now   func() time.Time
+}
+
+type cacheItem struct {
+	size int64
+	used time.Time
+}
+
+// NewCache creates the cache and loads the index of the files in dir
+func NewCache(dir string, maxSize int64, ttl time.Duration, version string) (*Cache, error) {
+	if dir == "" {
+		return nil, errors.New("no cache dir")
+	}
+	if maxSize <= 0 {
+		return nil, errors.New("no cache max size")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Wrapf(err, "can't create %s", dir)
+	}
+	res := &Cache{dir: dir, maxSize: maxSize, ttl: ttl, version: version, items: map[string]*cacheItem{}, now: time.Now}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read %s", dir)
+	}
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil || !fi.Mode().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		res.items[e.Name()] = &cacheItem{size: fi.Size(), used: fi.ModTime()}
+		res.size += fi.Size()
+	}
+	res.lock.Lock()
+	res.evict()
+	res.lock.Unlock()
+	goapp.Log.Infof("Cache dir: %s, files: %d, size: %d, max size: %d, ttl: %s, version: '%s'", dir, len(res.items),
+		res.size, maxSize, ttl, version)
+	return res, nil
+}
+
+// Get returns the cached part, the file is read without the lock
+func (c *Cache) Get(text string, msg *messages.TTSMessage) ([]byte, bool) {
+	key := c.key(text, msg)
+	it, ok := c.use(key)
+	if !ok {
+		cacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	res, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		// the file may be evicted meanwhile
+		goapp.Log.Warn(errors.Wrapf(err, "can't read cached %s", key))
+		c.lock.Lock()
+		if c.items[key] == it {
+			c.remove(key)
+		}
+		c.lock.Unlock()
+		cacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	// keeps the use time for the index loaded on restart
+	now := c.now()
+	_ = os.Chtimes(filepath.Join(c.dir, key), now, now)
+	cacheRequests.WithLabelValues("hit").Inc()
+	return res, true
+}
+
+// use marks the item as used now, drops it if expired
+func (c *Cache) use(key string) (*cacheItem, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	it, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if c.expired(it) {
+		c.remove(key)
+		return nil, false
+	}
+	it.used = c.now()
+	return it, true
+}
+
+// Put saves the part and evicts the old ones
+func (c *Cache) Put(text string, msg *messages.TTSMessage, data []byte) error {
+	key := c.key(text, msg)
+	fn := filepath.Join(c.dir, key)
+	tmp, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "can't create temp file")
+	}
+	_, err = tmp.Write(data)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fn)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return errors.Wrapf(err, "can't save %s", fn)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if it, ok := c.items[key]; ok {
+		c.size -= it.size
+	}
+	c.items[key] = &cacheItem{size: int64(len(data)), used: c.now()}
+	c.size += int64(len(data))
+	c.evict()
+	return nil
+}
+
+func (c *Cache) key(text string, msg *messages.TTSMessage) string {
+	format := audio.PartFormat(msg.OutputFormat)
+	h := sha256.New()
+	for _, s := range []string{c.version, msg.Voice, strconv.FormatFloat(msg.Speed, 'f', -1, 64), format, text} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)) + "." + format
+}
+
+func (c *Cache) expired(it *cacheItem) bool {
+	return c.ttl > 0 && c.now().Sub(it.used) > c.ttl
+}
+
+// evict drops expired files and the least recently used ones to fit into maxSize, expects the lock is taken
+func (c *Cache) evict() {
+	keys := make([]string, 0, len(c.items))
+	for k, it := range c.items {
+		if c.expired(it) {
+			c.remove(k)
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	if c.size > c.maxSize {
+		sort.Slice(keys, func(i, j int) bool { return c.items[keys[i]].used.Before(c.items[keys[j]].used) })
+		for _, k := range keys {
+			if c.size <= c.maxSize {
+				break
+			}
+			c.remove(k)
+		}
+	}
+	cacheSize.Set(float64(c.size))
+}
+
+func (c *Cache) remove(key string) {
+	if it, ok := c.items[key]; ok {
+		c.size -= it.size
+		delete(c.items, key)
+	}
+	if err := os.Remove(filepath.Join(c.dir, key)); err != nil && !os.IsNotExist(err) {
+		goapp.Log.Warn(errors.Wrapf(err, "can't remove cached %s", key))
+	}
+}
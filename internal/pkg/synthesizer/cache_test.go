@@ -0,0 +1,157 @@
+package synthesizer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCache(t *testing.T, maxSize int64, ttl time.Duration) *Cache {
+	t.Helper()
+	c, err := NewCache(t.TempDir(), maxSize, ttl, "1")
+	assert.Nil(t, err)
+	return c
+}
+
+func testMsg(voice string, speed float64, format string) *messages.TTSMessage {
+	return &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, Voice: voice, Speed: speed, OutputFormat: format}
+}
+
+func TestNewCache(t *testing.T) {
+	_, err := NewCache("", 10, 0, "")
+	assert.NotNil(t, err)
+	_, err = NewCache(t.TempDir(), 0, 0, "")
+	assert.NotNil(t, err)
+}
+
+func TestNewCache_LoadsIndex(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := NewCache(dir, 100, 0, "1")
+	assert.Nil(t, c.Put("olia", testMsg("astra", 1, "mp3"), []byte("audio")))
+	c, err := NewCache(dir, 100, 0, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), c.size)
+	got, ok := c.Get("olia", testMsg("astra", 1, "mp3"))
+	assert.True(t, ok)
+	assert.Equal(t, "audio", string(got))
+}
+
+func TestCache_Key(t *testing.T) {
+	c := newTestCache(t, 100, 0)
+	assert.Nil(t, c.Put("olia", testMsg("astra", 1, "mp3"), []byte("audio")))
+	_, ok := c.Get("olia", testMsg("astra", 1, "mp3"))
+	assert.True(t, ok)
+	_, ok = c.Get("olia1", testMsg("astra", 1, "mp3"))
+	assert.False(t, ok)
+	_, ok = c.Get("olia", testMsg("laimis", 1, "mp3"))
+	assert.False(t, ok)
+	_, ok = c.Get("olia", testMsg("astra", 1.1, "mp3"))
+	assert.False(t, ok)
+	_, ok = c.Get("olia", testMsg("astra", 1, "m4a"))
+	assert.False(t, ok)
+	assert.Nil(t, c.Put("olia", testMsg("astra", 1, "m4a"), []byte("audio")))
+	_, ok = c.Get("olia", testMsg("astra", 1, "wav"))
+	assert.True(t, ok, "wav is joined from m4a parts")
+	c.version = "2"
+	_, ok = c.Get("olia", testMsg("astra", 1, "mp3"))
+	assert.False(t, ok)
+}
+
+func TestCache_EvictsBySize(t *testing.T) {
+	c := newTestCache(t, 10, 0)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	assert.Nil(t, c.Put("a", testMsg("astra", 1, "mp3"), []byte("1234")))
+	now = now.Add(time.Second)
+	assert.Nil(t, c.Put("b", testMsg("astra", 1, "mp3"), []byte("1234")))
+	now = now.Add(time.Second)
+	_, ok := c.Get("a", testMsg("astra", 1, "mp3"))
+	assert.True(t, ok)
+	now = now.Add(time.Second)
+	assert.Nil(t, c.Put("c", testMsg("astra", 1, "mp3"), []byte("1234")))
+	assert.Equal(t, int64(8), c.size)
+	_, ok = c.Get("b", testMsg("astra", 1, "mp3"))
+	assert.False(t, ok)
+	_, ok = c.Get("a", testMsg("astra", 1, "mp3"))
+	assert.True(t, ok)
+	files, _ := os.ReadDir(c.dir)
+	assert.Equal(t, 2, len(files))
+}
+
+func TestCache_Expires(t *testing.T) {
+	c := newTestCache(t, 100, time.Hour)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	assert.Nil(t, c.Put("a", testMsg("astra", 1, "mp3"), []byte("1234")))
+	now = now.Add(time.Minute * 59)
+	_, ok := c.Get("a", testMsg("astra", 1, "mp3"))
+	assert.True(t, ok)
+	now = now.Add(time.Minute * 61)
+	_, ok = c.Get("a", testMsg("astra", 1, "mp3"))
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), c.size)
+}
+
+func TestCache_Get_MissingFile(t *testing.T) {
+	c := newTestCache(t, 100, 0)
+	msg := testMsg("astra", 1, "mp3")
+	assert.Nil(t, c.Put("a", msg, []byte("1234")))
+	assert.Nil(t, os.Remove(filepath.Join(c.dir, c.key("a", msg))))
+	_, ok := c.Get("a", msg)
+	assert.False(t, ok)
+	assert.Equal(t, 0, len(c.items))
+}
+
+func TestCacheCollectors(t *testing.T) {
+	assert.Equal(t, 2, len(CacheCollectors()))
+}
+
+type testCache struct {
+	data map[string][]byte
+}
+
+func (c *testCache) Get(text string, msg *messages.TTSMessage) ([]byte, bool) {
+	res, ok := c.data[text]
+	return res, ok
+}
+
+func (c *testCache) Put(text string, msg *messages.TTSMessage, data []byte) error {
+	c.data[text] = data
+	return nil
+}
+
+func TestWorker_Do_Cache(t *testing.T) {
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
+	assert.Nil(t, err)
+	cache := &testCache{data: map[string][]byte{"in/id1/0000.txt": []byte("cached")}}
+	got.SetCache(cache)
+	got.existsFunc = func(s string) (bool, error) {
+		return s == "in/id1/0000.txt" || s == "in/id1/0001.txt", nil
+	}
+	got.loadFunc = func(s string) ([]byte, error) {
+		return []byte(s), nil
+	}
+	calls := 0
+	got.callFunc = func(s string, tm *messages.TTSMessage) ([]byte, error) {
+		assert.Equal(t, "in/id1/0001.txt", s)
+		calls++
+		return []byte("synthesized"), nil
+	}
+	saved := map[string]string{}
+	got.saveFunc = func(s string, b []byte) error {
+		saved[s] = string(b)
+		return nil
+	}
+	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, map[string]string{"new/id1/0000.mp3": "cached", "new/id1/0001.mp3": "synthesized"}, saved)
+	assert.Equal(t, "synthesized", string(cache.data["in/id1/0001.txt"]))
+}
@@ -0,0 +1,72 @@
+package synthesizer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/airenas/big-tts/internal/pkg/audio"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/pkg/errors"
+)
+
+// Progress inspects split and synthesized parts of a job
+type Progress struct {
+	inDir  string
+	outDir string
+
+	loadFunc   func(string) ([]byte, error)
+	existsFunc func(string) (bool, error)
+}
+
+// NewProgress creates progress inspector, the templates are the same as for the Worker
+func NewProgress(st storage.Storage, inTemplate, outTemplate string) (*Progress, error) {
+	if st == nil {
+		return nil, errors.Errorf("no storage")
+	}
+	if !strings.Contains(inTemplate, "{}") {
+		return nil, errors.Errorf("no ID template in inTemplate")
+	}
+	if !strings.Contains(outTemplate, "{}") {
+		return nil, errors.Errorf("no ID template in outTemplate")
+	}
+	goapp.Log.Infof("Progress in dir: %s, out dir: %s", inTemplate, outTemplate)
+	return &Progress{inDir: inTemplate, outDir: outTemplate,
+		loadFunc:   func(name string) ([]byte, error) { return storage.ReadFile(st, name) },
+		existsFunc: st.Exists}, nil
+}
+
+// Remaining returns the chars count of the parts not synthesized yet
+func (p *Progress) Remaining(id, outputFormat string) (int, error) {
+	inDir := strings.ReplaceAll(p.inDir, "{}", id)
+	outDir := strings.ReplaceAll(p.outDir, "{}", id)
+	res := 0
+	for i := 0; ; i++ {
+		inFile := filepath.Join(inDir, fmt.Sprintf("%04d.txt", i))
+		ok, err := p.existsFunc(inFile)
+		if err != nil {
+			return 0, errors.Wrapf(err, "can't check %s", inFile)
+		}
+		if !ok {
+			if i == 0 {
+				return 0, errors.Errorf("no split parts for %s", id)
+			}
+			return res, nil
+		}
+		outFile := filepath.Join(outDir, fmt.Sprintf("%04d.%s", i, audio.PartFormat(outputFormat)))
+		ok, err = p.existsFunc(outFile)
+		if err != nil {
+			return 0, errors.Wrapf(err, "can't check %s", outFile)
+		}
+		if ok {
+			continue
+		}
+		b, err := p.loadFunc(inFile)
+		if err != nil {
+			return 0, errors.Wrapf(err, "can't load %s", inFile)
+		}
+		res += utf8.RuneCount(b)
+	}
+}
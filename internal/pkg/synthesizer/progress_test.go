@@ -0,0 +1,50 @@
+package synthesizer
+
+import (
+	"testing"
+
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProgress(t *testing.T) {
+	got, err := NewProgress(&storage.Local{}, "in/{}", "out/{}")
+	assert.Nil(t, err)
+	assert.NotNil(t, got)
+	_, err = NewProgress(&storage.Local{}, "in", "out/{}")
+	assert.NotNil(t, err)
+	_, err = NewProgress(&storage.Local{}, "in/{}", "out")
+	assert.NotNil(t, err)
+	_, err = NewProgress(nil, "in/{}", "out/{}")
+	assert.NotNil(t, err)
+}
+
+func TestProgress_Remaining(t *testing.T) {
+	got, _ := NewProgress(&storage.Local{}, "in/{}", "out/{}")
+	got.existsFunc = func(s string) (bool, error) {
+		return s < "in/id1/0003.txt" || s == "out/id1/0001.m4a", nil
+	}
+	got.loadFunc = func(s string) ([]byte, error) {
+		return []byte("ąčę"), nil
+	}
+	res, err := got.Remaining("id1", "ogg")
+	assert.Nil(t, err)
+	assert.Equal(t, 6, res)
+}
+
+func TestProgress_Remaining_Fail(t *testing.T) {
+	got, _ := NewProgress(&storage.Local{}, "in/{}", "out/{}")
+	got.existsFunc = func(s string) (bool, error) { return false, nil }
+	_, err := got.Remaining("id1", "mp3")
+	assert.NotNil(t, err)
+	got.existsFunc = func(s string) (bool, error) { return s == "in/id1/0000.txt", nil }
+	got.loadFunc = func(s string) ([]byte, error) {
+		return nil, errors.New("olia")
+	}
+	_, err = got.Remaining("id1", "mp3")
+	assert.NotNil(t, err)
+	got.existsFunc = func(s string) (bool, error) { return false, errors.New("olia") }
+	_, err = got.Remaining("id1", "mp3")
+	assert.NotNil(t, err)
+}
@@ -8,13 +8,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/airenas/big-tts/internal/pkg/audio"
 	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/storage"
 	"github.com/airenas/big-tts/internal/pkg/upload"
 	"github.com/airenas/big-tts/internal/pkg/utils"
 	"github.com/airenas/go-app/pkg/goapp"
@@ -29,15 +30,20 @@ type Worker struct {
 	workerCount int
 	httpClient  http.Client
 
-	loadFunc      func(string) ([]byte, error)
-	saveFunc      func(string, []byte) error
-	createDirFunc func(string) error
-	existsFunc    func(string) bool
-	callFunc      func(string, *messages.TTSMessage) ([]byte, error)
+	loadFunc   func(string) ([]byte, error)
+	saveFunc   func(string, []byte) error
+	existsFunc func(string) (bool, error)
+	callFunc   func(string, *messages.TTSMessage) ([]byte, error)
+	cache      PartCache
+	// defaultPriority is sent for messages without a priority
+	defaultPriority int
 }
 
 // NewWorker creates new synthesize worker
-func NewWorker(inTemplate, outTemplate string, url string, workerCount int) (*Worker, error) {
+func NewWorker(st storage.Storage, inTemplate, outTemplate string, url string, workerCount int) (*Worker, error) {
+	if st == nil {
+		return nil, errors.Errorf("no storage")
+	}
 	if !strings.Contains(inTemplate, "{}") {
 		return nil, errors.Errorf("no ID template in inTemplate")
 	}
@@ -51,12 +57,12 @@ func NewWorker(inTemplate, outTemplate string, url string, workerCount int) (*Wo
 		return nil, errors.Errorf("no workerCount provided")
 	}
 	res := &Worker{inDir: inTemplate, outDir: outTemplate, serviceURL: url}
-	res.loadFunc = os.ReadFile
-	res.saveFunc = utils.WriteFile
-	res.existsFunc = utils.FileExists
-	res.createDirFunc = func(name string) error { return os.MkdirAll(name, os.ModePerm) }
+	res.loadFunc = func(name string) ([]byte, error) { return storage.ReadFile(st, name) }
+	res.saveFunc = func(name string, data []byte) error { return storage.WriteFile(st, name, data) }
+	res.existsFunc = st.Exists
 	res.callFunc = res.invokeService
 	res.workerCount = workerCount
+	res.defaultPriority = upload.DefaultPriority
 	res.httpClient = http.Client{Transport: &http.Transport{
 		MaxIdleConns:        40,
 		MaxIdleConnsPerHost: 40,
@@ -71,22 +77,39 @@ func NewWorker(inTemplate, outTemplate string, url string, workerCount int) (*Wo
 	return res, nil
 }
 
+// SetCache sets the cache of synthesized parts, nil disables it
+func (w *Worker) SetCache(c PartCache) {
+	w.cache = c
+}
+
+// SetDefaultPriority sets the priority sent for messages without a priority
+func (w *Worker) SetDefaultPriority(p int) error {
+	if p < 0 {
+		return errors.Errorf("wrong default priority %d", p)
+	}
+	goapp.Log.Infof("Synthesizer default priority: %d", p)
+	w.defaultPriority = p
+	return nil
+}
+
 // Do synthesizes one part of a text
 func (w *Worker) Do(ctx context.Context, msg *messages.TTSMessage) error {
 	goapp.Log.Infof("Doing synthesize job for %s", msg.ID)
-	outDir := strings.ReplaceAll(w.outDir, "{}", msg.ID)
-	if err := w.createDirFunc(outDir); err != nil {
-		return errors.Wrapf(err, "can't create %s", outDir)
-	}
 
 	errCh := make(chan error, w.workerCount+1)
 	syncCh := make(chan struct{}, w.workerCount)
 	stop := false
 	wg := &sync.WaitGroup{}
 	var inF, outF string
+	var err error
 out:
 	for i := 0; !stop; i++ {
-		stop, inF, outF = w.getFiles(i, msg)
+		stop, inF, outF, err = w.getFiles(i, msg)
+		if err != nil {
+			goapp.Log.Infof("Error occured, waiting to complete all jobs")
+			wg.Wait()
+			return err
+		}
 		if inF != "" {
 			// make sure we exit in case of error or cancelling before
 			// --- case syncCh <- struct{}{}: ---
@@ -129,21 +152,61 @@ out:
 	}
 	goapp.Log.Infof("Waiting to complete all jobs")
 	wg.Wait()
+	if errors.Is(context.Cause(ctx), utils.ErrJobCanceled) {
+		return w.canceledErr(msg)
+	}
 	errCh <- nil
 	return <-errCh
 }
 
-func (w *Worker) getFiles(num int, msg *messages.TTSMessage) (bool, string, string) {
+// canceledErr returns the canceled error with the part of not synthesized files
+func (w *Worker) canceledErr(msg *messages.TTSMessage) error {
+	inDir := strings.ReplaceAll(w.inDir, "{}", msg.ID)
+	outDir := strings.ReplaceAll(w.outDir, "{}", msg.ID)
+	all, done := 0, 0
+	for ; ; all++ {
+		ok, err := w.existsFunc(filepath.Join(inDir, fmt.Sprintf("%04d.txt", all)))
+		if err != nil {
+			goapp.Log.Error(err)
+			break
+		}
+		if !ok {
+			break
+		}
+		ok, err = w.existsFunc(filepath.Join(outDir, fmt.Sprintf("%04d.%s", all, audio.PartFormat(msg.OutputFormat))))
+		if err != nil {
+			goapp.Log.Error(err)
+		}
+		if ok {
+			done++
+		}
+	}
+	goapp.Log.Infof("Canceled %s, synthesized %d of %d", msg.ID, done, all)
+	if all == 0 {
+		return utils.NewErrCanceled(1)
+	}
+	return utils.NewErrCanceled(float64(all-done) / float64(all))
+}
+
+func (w *Worker) getFiles(num int, msg *messages.TTSMessage) (bool, string, string, error) {
 	inFile := filepath.Join(strings.ReplaceAll(w.inDir, "{}", msg.ID), fmt.Sprintf("%04d.txt", num))
-	if !w.existsFunc(inFile) {
-		return true, "", ""
+	ok, err := w.existsFunc(inFile)
+	if err != nil {
+		return true, "", "", errors.Wrapf(err, "can't check %s", inFile)
+	}
+	if !ok {
+		return true, "", "", nil
 	}
 	outDir := strings.ReplaceAll(w.outDir, "{}", msg.ID)
-	outFile := filepath.Join(outDir, fmt.Sprintf("%04d.%s", num, msg.OutputFormat))
-	if w.existsFunc(outFile) {
-		return false, "", ""
+	outFile := filepath.Join(outDir, fmt.Sprintf("%04d.%s", num, audio.PartFormat(msg.OutputFormat)))
+	ok, err = w.existsFunc(outFile)
+	if err != nil {
+		return true, "", "", errors.Wrapf(err, "can't check %s", outFile)
+	}
+	if ok {
+		return false, "", "", nil
 	}
-	return false, inFile, outFile
+	return false, inFile, outFile, nil
 }
 
 func (w *Worker) invoke(inFile string, outFile string, msg *messages.TTSMessage) error {
@@ -151,10 +214,21 @@ func (w *Worker) invoke(inFile string, outFile string, msg *messages.TTSMessage)
 	if err != nil {
 		return err
 	}
+	if w.cache != nil {
+		if b, ok := w.cache.Get(string(text), msg); ok {
+			goapp.Log.Infof("Cache hit for %s", inFile)
+			return w.saveFunc(outFile, b)
+		}
+	}
 	bytes, err := w.callFunc(string(text), msg)
 	if err != nil {
 		return err
 	}
+	if w.cache != nil {
+		if err := w.cache.Put(string(text), msg, bytes); err != nil {
+			goapp.Log.Warn(errors.Wrap(err, "can't cache part"))
+		}
+	}
 	return w.saveFunc(outFile, bytes)
 }
 
@@ -177,11 +251,11 @@ type (
 )
 
 func (w *Worker) invokeService(data string, msg *messages.TTSMessage) ([]byte, error) {
-	inp := input{Text: data, OutputFormat: msg.OutputFormat,
+	inp := input{Text: data, OutputFormat: audio.PartFormat(msg.OutputFormat),
 		Voice:            msg.Voice,
 		Speed:            float32(msg.Speed),
 		AllowCollectData: &msg.SaveRequest,
-		Priority:         300} // will indicate 300s wait on high load comparing to priority=0
+		Priority:         w.priority(msg)}
 	var out result
 	err := w.invokeRemote(inp, &out, msg.SaveTags)
 	if err != nil {
@@ -190,6 +264,14 @@ func (w *Worker) invokeService(data string, msg *messages.TTSMessage) ([]byte, e
 	return base64.StdEncoding.DecodeString(out.AudioAsString)
 }
 
+// priority returns the message priority, messages sent before the priority was added have 0
+func (w *Worker) priority(msg *messages.TTSMessage) int {
+	if msg.Priority == 0 {
+		return w.defaultPriority
+	}
+	return msg.Priority
+}
+
 func (w *Worker) invokeRemote(dataIn input, dataOut *result, saveTags []string) error {
 	b := new(bytes.Buffer)
 	enc := json.NewEncoder(b)
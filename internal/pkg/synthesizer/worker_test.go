@@ -13,43 +13,43 @@ import (
 
 	amessages "github.com/airenas/async-api/pkg/messages"
 	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/storage"
+	"github.com/airenas/big-tts/internal/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewWorker(t *testing.T) {
-	got, err := NewWorker("{}.txt", "new{}.txt", "url", 1)
+	got, err := NewWorker(&storage.Local{}, "{}.txt", "new{}.txt", "url", 1)
 	assert.Nil(t, err)
 	assert.NotNil(t, got)
-	_, err = NewWorker(".txt", "new{}.txt", "url", 1)
+	_, err = NewWorker(&storage.Local{}, ".txt", "new{}.txt", "url", 1)
 	assert.NotNil(t, err)
-	_, err = NewWorker("{}.txt", "new.txt", "url", 1)
+	_, err = NewWorker(&storage.Local{}, "{}.txt", "new.txt", "url", 1)
 	assert.NotNil(t, err)
-	_, err = NewWorker("{}.txt", "new{}.txt", "", 1)
+	_, err = NewWorker(&storage.Local{}, "{}.txt", "new{}.txt", "", 1)
 	assert.NotNil(t, err)
-	_, err = NewWorker("{}.txt", "new{}.txt", "url", 0)
+	_, err = NewWorker(&storage.Local{}, "{}.txt", "new{}.txt", "url", 0)
+	assert.NotNil(t, err)
+	_, err = NewWorker(nil, "{}.txt", "new{}.txt", "url", 1)
 	assert.NotNil(t, err)
 }
 
 func TestWorker_Do(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "url", 1)
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
 	assert.Nil(t, err)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
 		files++
 		if files == 1 {
 			assert.Equal(t, "in/id1/0000.txt", s)
-			return true
+			return true, nil
 		}
 		if files == 2 {
 			assert.Equal(t, "new/id1/0000.mp3", s)
-			return false
+			return false, nil
 		}
-		return false
-	}
-	got.createDirFunc = func(s string) error {
-		assert.Equal(t, "new/id1/", s)
-		return nil
+		return false, nil
 	}
 	got.loadFunc = func(s string) ([]byte, error) {
 		assert.Equal(t, "in/id1/0000.txt", s)
@@ -69,23 +69,20 @@ func TestWorker_Do(t *testing.T) {
 }
 
 func TestWorker_Do_Exists_Skip(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "url", 1)
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
 	assert.Nil(t, err)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
 		files++
 		if files == 1 {
 			assert.Equal(t, "in/id1/0000.txt", s)
-			return true
+			return true, nil
 		}
 		if files == 2 {
 			assert.Equal(t, "new/id1/0000.mp3", s)
-			return true
+			return true, nil
 		}
-		return false
-	}
-	got.createDirFunc = func(s string) error {
-		return nil
+		return false, nil
 	}
 	got.loadFunc = func(s string) ([]byte, error) {
 		t.Error("not expected")
@@ -104,15 +101,12 @@ func TestWorker_Do_Exists_Skip(t *testing.T) {
 }
 
 func TestWorker_Do_Fail_Invoke(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "url", 1)
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
 	assert.Nil(t, err)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
 		files++
-		return files < 2
-	}
-	got.createDirFunc = func(s string) error {
-		return nil
+		return files < 2, nil
 	}
 	got.loadFunc = func(s string) ([]byte, error) {
 		return nil, errors.New("err")
@@ -130,15 +124,12 @@ func TestWorker_Do_Fail_Invoke(t *testing.T) {
 }
 
 func TestWorker_Do_Fail_Calc(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "url", 1)
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
 	assert.Nil(t, err)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
 		files++
-		return files < 2
-	}
-	got.createDirFunc = func(s string) error {
-		return nil
+		return files < 2, nil
 	}
 	got.loadFunc = func(s string) ([]byte, error) {
 		return []byte("in"), nil
@@ -155,15 +146,12 @@ func TestWorker_Do_Fail_Calc(t *testing.T) {
 }
 
 func TestWorker_Do_Exit_OnCancel(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "url", 1)
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
 	assert.Nil(t, err)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
 		files++
-		return files < 2
-	}
-	got.createDirFunc = func(s string) error {
-		return nil
+		return files < 2, nil
 	}
 	got.loadFunc = func(s string) ([]byte, error) {
 		return []byte("in"), nil
@@ -177,14 +165,45 @@ func TestWorker_Do_Exit_OnCancel(t *testing.T) {
 	assert.Equal(t, context.Canceled, err)
 }
 
-func TestWorker_Do_Exit_OnFailure(t *testing.T) {
-	got, err := NewWorker("in/{}", "new/{}/", "url", 10)
+func TestWorker_Do_Exit_OnJobCancel(t *testing.T) {
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
 	assert.Nil(t, err)
-	got.existsFunc = func(s string) bool {
-		return strings.HasSuffix(s, ".txt")
+	got.existsFunc = func(s string) (bool, error) {
+		// 4 parts, 0001 is synthesized
+		return s < "in/id1/0004.txt" || s == "new/id1/0001.mp3", nil
 	}
-	got.createDirFunc = func(s string) error {
-		return nil
+	got.callFunc = func(s string, tm *messages.TTSMessage) ([]byte, error) {
+		t.Error("not expected")
+		return nil, nil
+	}
+	ctx, cFunc := context.WithCancelCause(context.Background())
+	cFunc(utils.ErrJobCanceled)
+	err = got.Do(ctx, &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	var errTest *utils.ErrCanceled
+	if assert.True(t, errors.As(err, &errTest)) {
+		assert.InDelta(t, 0.75, errTest.Part, 0.0001)
+	}
+}
+
+func TestWorker_Do_Fail_Exists(t *testing.T) {
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 1)
+	assert.Nil(t, err)
+	got.existsFunc = func(s string) (bool, error) {
+		return false, errors.New("olia")
+	}
+	got.callFunc = func(s string, tm *messages.TTSMessage) ([]byte, error) {
+		t.Error("not expected")
+		return nil, nil
+	}
+	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	assert.NotNil(t, err)
+}
+
+func TestWorker_Do_Exit_OnFailure(t *testing.T) {
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "url", 10)
+	assert.Nil(t, err)
+	got.existsFunc = func(s string) (bool, error) {
+		return strings.HasSuffix(s, ".txt"), nil
 	}
 	got.loadFunc = func(s string) ([]byte, error) {
 		return []byte("in"), nil
@@ -205,7 +224,9 @@ func TestWorker_Do_Exit_OnFailure(t *testing.T) {
 }
 
 func TestWorker_Do_WithRealInvoke(t *testing.T) {
+	var inp input
 	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&inp)
 		rw.WriteHeader(http.StatusOK)
 		res := result{}
 		res.AudioAsString = base64.StdEncoding.EncodeToString([]byte("audio data"))
@@ -213,15 +234,12 @@ func TestWorker_Do_WithRealInvoke(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	got, err := NewWorker("in/{}", "new/{}/", srv.URL, 1)
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", srv.URL, 1)
 	assert.Nil(t, err)
 	files := 0
-	got.existsFunc = func(s string) bool {
+	got.existsFunc = func(s string) (bool, error) {
 		files++
-		return files < 2
-	}
-	got.createDirFunc = func(s string) error {
-		return nil
+		return files < 2, nil
 	}
 	got.loadFunc = func(s string) ([]byte, error) {
 		return []byte("in"), nil
@@ -230,8 +248,21 @@ func TestWorker_Do_WithRealInvoke(t *testing.T) {
 		assert.Equal(t, "audio data", string(b))
 		return nil
 	}
-	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "mp3"})
+	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1"}, OutputFormat: "ogg",
+		Priority: 100})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, inp.Priority)
+	assert.Equal(t, "m4a", inp.OutputFormat)
+}
+
+func TestWorker_SetDefaultPriority(t *testing.T) {
+	got, err := NewWorker(&storage.Local{}, "in/{}", "new/{}/", "http://olia", 1)
 	assert.Nil(t, err)
+	assert.Equal(t, 300, got.priority(&messages.TTSMessage{}))
+	assert.NotNil(t, got.SetDefaultPriority(-1))
+	assert.Nil(t, got.SetDefaultPriority(50))
+	assert.Equal(t, 50, got.priority(&messages.TTSMessage{}))
+	assert.Equal(t, 10, got.priority(&messages.TTSMessage{Priority: 10}))
 }
 
 // func TestWorker_Do_WithRealInvokeFail(t *testing.T) {
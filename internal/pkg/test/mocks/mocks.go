@@ -14,6 +14,28 @@ import (
 
 //go:generate pegomock generate --package=mocks --output=requestSaver.go github.com/airenas/big-tts/internal/pkg/upload RequestSaver
 
+//go:generate pegomock generate --package=mocks --output=textExtractor.go github.com/airenas/big-tts/internal/pkg/upload TextExtractor
+
+//go:generate pegomock generate --package=mocks --output=uploadSessionStore.go github.com/airenas/big-tts/internal/pkg/upload UploadSessionStore
+
+//go:generate pegomock generate --package=mocks --output=fileRemover.go github.com/airenas/big-tts/internal/pkg/upload FileRemover
+
+//go:generate pegomock generate --package=mocks --output=textEstimator.go github.com/airenas/big-tts/internal/pkg/upload TextEstimator
+
+//go:generate pegomock generate --package=mocks --output=idempotencyStore.go github.com/airenas/big-tts/internal/pkg/upload IdempotencyStore
+
+//go:generate pegomock generate --package=mocks --output=jobCanceler.go github.com/airenas/big-tts/internal/pkg/upload JobCanceler
+
+//go:generate pegomock generate --package=mocks --output=statusStore.go github.com/airenas/big-tts/internal/pkg/upload StatusStore
+
+//go:generate pegomock generate --package=mocks --output=requestStore.go github.com/airenas/big-tts/internal/pkg/upload RequestStore
+
+//go:generate pegomock generate --package=mocks --output=progressProvider.go github.com/airenas/big-tts/internal/pkg/upload ProgressProvider
+
+//go:generate pegomock generate --package=mocks --output=lexiconStore.go github.com/airenas/big-tts/internal/pkg/upload LexiconStore
+
+//go:generate pegomock generate --package=mocks --output=assetStore.go github.com/airenas/big-tts/internal/pkg/upload AssetStore
+
 //go:generate pegomock generate --package=mocks --output=fileReader.go github.com/airenas/big-tts/internal/pkg/result FileReader
 
 //go:generate pegomock generate --package=mocks --output=fileNameProvider.go github.com/airenas/big-tts/internal/pkg/result FileNameProvider
@@ -22,6 +44,8 @@ import (
 
 //go:generate pegomock generate --package=mocks --output=statusSaver.go github.com/airenas/big-tts/internal/pkg/synthesize StatusSaver
 
+//go:generate pegomock generate --package=mocks --output=cancelChecker.go github.com/airenas/big-tts/internal/pkg/synthesize CancelChecker
+
 //go:generate pegomock generate --package=mocks --output=cleaner.go github.com/airenas/big-tts/internal/pkg/clean Cleaner
 
 //go:generate pegomock generate --package=mocks --output=emailSender.go github.com/airenas/big-tts/internal/pkg/inform Sender
@@ -32,6 +56,10 @@ import (
 
 //go:generate pegomock generate --package=mocks --output=locker.go github.com/airenas/big-tts/internal/pkg/inform Locker
 
+//go:generate pegomock generate --package=mocks --output=callbackRetriever.go github.com/airenas/big-tts/internal/pkg/inform CallbackRetriever
+
+//go:generate pegomock generate --package=mocks --output=callbackSender.go github.com/airenas/big-tts/internal/pkg/inform CallbackSender
+
 // AttachMockToTest register pegomock verification to be passed to testing engine
 func AttachMockToTest(t *testing.T) {
 	pegomock.RegisterMockFailHandler(handleByTest(t))
@@ -0,0 +1,128 @@
+package textnorm
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// ToUTF8 detects text encoding and converts data to UTF-8.
+// Supported: UTF-8, UTF-16 (with BOM or detected by zero bytes), Windows-1257 and ISO-8859-13
+func ToUTF8(data []byte) ([]byte, error) {
+	res, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(res) {
+		return nil, errors.New("binary data, not a text")
+	}
+	return res, nil
+}
+
+func decode(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return data[len(bomUTF8):], nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), data, "UTF-16LE",
+			countUTF16(data, true, utf8.RuneError))
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.UseBOM), data, "UTF-16BE",
+			countUTF16(data, false, utf8.RuneError))
+	}
+	if le, ok := guessUTF16(data); ok {
+		if le {
+			return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), data, "UTF-16LE",
+				countUTF16(data, true, utf8.RuneError))
+		}
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), data, "UTF-16BE",
+			countUTF16(data, false, utf8.RuneError))
+	}
+	if utf8.Valid(data) {
+		return data, nil
+	}
+	if hasC1Bytes(data) {
+		// ISO-8859-13 has control codes in 0x80-0x9F, Windows-1257 has punctuation there
+		return decodeWith(charmap.Windows1257, data, "Windows-1257", 0)
+	}
+	return decodeWith(charmap.ISO8859_13, data, "ISO-8859-13", 0)
+}
+
+// decodeWith converts data, the decoder marks undecodable input with utf8.RuneError.
+// The text may have only the genuine count of them
+func decodeWith(enc encoding.Encoding, data []byte, name string, genuine int) ([]byte, error) {
+	res, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't decode text as %s", name)
+	}
+	if bytes.Count(res, []byte(string(utf8.RuneError))) > genuine {
+		return nil, errors.Errorf("can't decode text as %s", name)
+	}
+	return res, nil
+}
+
+// countUTF16 counts the code unit r in UTF-16 data
+func countUTF16(data []byte, le bool, r rune) int {
+	res := 0
+	for i := 0; i+1 < len(data); i += 2 {
+		v := rune(data[i])<<8 | rune(data[i+1])
+		if le {
+			v = rune(data[i+1])<<8 | rune(data[i])
+		}
+		if v == r {
+			res++
+		}
+	}
+	return res
+}
+
+// guessUTF16 checks if zero bytes dominate in odd or even positions, returns true for little endian
+func guessUTF16(data []byte) (bool, bool) {
+	l := len(data)
+	if l < 4 || l%2 != 0 {
+		return false, false
+	}
+	if l > 4000 {
+		l = 4000
+	}
+	even, odd := 0, 0
+	for i := 0; i < l; i += 2 {
+		if data[i] == 0 {
+			even++
+		}
+		if data[i+1] == 0 {
+			odd++
+		}
+	}
+	half := l / 2
+	if odd > half*4/10 && even < half/20 {
+		return true, true
+	}
+	if even > half*4/10 && odd < half/20 {
+		return false, true
+	}
+	return false, false
+}
+
+func hasC1Bytes(data []byte) bool {
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9F {
+			return true
+		}
+	}
+	return false
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) >= 0
+}
@@ -0,0 +1,38 @@
+package textnorm
+
+import (
+	"strings"
+)
+
+var replacer = strings.NewReplacer(
+	"\r\n", "\n",
+	"\r", "\n",
+	"\f", "\n",
+	"\v", "\n",
+	"\u00a0", " ", // no-break space
+	"\u2007", " ", // figure space
+	"\u202f", " ", // narrow no-break space
+	"\u00ad", "", // soft hyphen
+	"\u200b", "", // zero width space
+	"\u200c", "", // zero width non-joiner
+	"\u200d", "", // zero width joiner
+	"\u2060", "", // word joiner
+	"\ufeff", "", // zero width no-break space, BOM
+	"\u2028", "\n", // line separator
+	"\u2029", "\n\n", // paragraph separator
+)
+
+// Normalize fixes line endings and replaces or drops invisible unicode symbols
+func Normalize(text []byte) []byte {
+	return []byte(strings.Map(dropControl, replacer.Replace(string(text))))
+}
+
+func dropControl(r rune) rune {
+	if r == '\n' || r == '\t' {
+		return r
+	}
+	if r < 0x20 || (r >= 0x7F && r <= 0x9F) {
+		return -1
+	}
+	return r
+}
@@ -0,0 +1,68 @@
+package textnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestToUTF8(t *testing.T) {
+	txt := "Ąžuolas – „gražus“ medis"
+	enc := func(e interface{ Bytes([]byte) ([]byte, error) }) []byte {
+		res, _ := e.Bytes([]byte(txt))
+		return res
+	}
+	tests := []struct {
+		name    string
+		args    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "UTF-8", args: []byte(txt), want: txt},
+		{name: "UTF-8 BOM", args: append([]byte{0xEF, 0xBB, 0xBF}, []byte(txt)...), want: txt},
+		{name: "UTF-16LE BOM", args: enc(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()), want: txt},
+		{name: "UTF-16BE BOM", args: enc(unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder()), want: txt},
+		{name: "UTF-16LE", args: enc(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()), want: txt},
+		{name: "Windows-1257", args: enc(charmap.Windows1257.NewEncoder()), want: txt},
+		{name: "ISO-8859-13", args: []byte{0xC0, 0xFE, 'u', 'o', 'l', 'a', 's', ' ', 0xA5, 'g', 'r', 'a', 0xFE, 'u', 's', 0xB4},
+			want: "Ąžuolas „gražus“"},
+		{name: "Empty", args: []byte{}, want: ""},
+		{name: "Binary", args: []byte{'a', 0, 'b', 'c', 'd'}, wantErr: true},
+		{name: "Undefined", args: []byte{'a', 0xA1, 0x81}, wantErr: true},
+		{name: "UTF-8 replacement char", args: []byte("a\uFFFDb"), want: "a\uFFFDb"},
+		{name: "UTF-16LE replacement char", args: []byte{0xFF, 0xFE, 'a', 0, 0xFD, 0xFF}, want: "a\uFFFD"},
+		{name: "UTF-16LE broken surrogate", args: []byte{0xFF, 0xFE, 'a', 0, 0x00, 0xD8, 'b', 0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToUTF8(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ToUTF8() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{name: "CRLF", args: "a\r\nb\rc", want: "a\nb\nc"},
+		{name: "NBSP", args: "a\u00a0b\u202fc", want: "a b c"},
+		{name: "Soft hyphen", args: "ne\u00adpri\u00adklau\u00adso\u00admy\u00adbė", want: "nepriklausomybė"},
+		{name: "Zero width", args: "\ufeffa\u200bb\u200dc", want: "abc"},
+		{name: "Control", args: "a\x01b\tc\x7F", want: "ab\tc"},
+		{name: "Same", args: "Ąžuolas", want: "Ąžuolas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(Normalize([]byte(tt.args))))
+		})
+	}
+}
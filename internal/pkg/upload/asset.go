@@ -0,0 +1,159 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/airenas/big-tts/internal/pkg/audio"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// AssetStore keeps the audio assets info
+type AssetStore interface {
+	Save(data *persistence.Asset) error
+	// Get returns nil if there is no asset
+	Get(id string) (*persistence.Asset, error)
+	Delete(id string) (bool, error)
+	// InUse returns true if a queued or running job uses the asset
+	InUse(id string) (bool, error)
+}
+
+type assetData struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Tag  string `json:"tag,omitempty"`
+	Ext  string `json:"ext"`
+}
+
+// assetCreate saves the uploaded audio file, the file is saved by the asset ID
+func assetCreate(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("asset create method")()
+
+		scope, err := callerScope(c)
+		if err != nil {
+			return err
+		}
+		a := &persistence.Asset{ID: uuid.New().String(), Scope: scope,
+			Name: strings.TrimSpace(c.FormValue("name")), Tag: strings.TrimSpace(c.FormValue("tag"))}
+		if a.Tag != "" && !hasSaveTag(c.Request(), a.Tag) {
+			return echo.NewHTTPError(http.StatusBadRequest, "wrong tag '"+a.Tag+"'")
+		}
+		file, err := c.FormFile("file")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "no file")
+		}
+		a.Ext = strings.ToLower(filepath.Ext(file.Filename))
+		if _, ok := audio.Get(strings.TrimPrefix(a.Ext, ".")); !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "wrong file type: "+a.Ext)
+		}
+		src, err := file.Open()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "can't read file")
+		}
+		defer src.Close()
+		if err := saveAsset(data, a, src); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't save asset")
+		}
+		goapp.Log.Infof("Saved asset %s", a.ID)
+		return c.JSON(http.StatusOK, result{ID: a.ID})
+	}
+}
+
+func saveAsset(data *Data, a *persistence.Asset, src io.Reader) error {
+	if err := data.AssetSaver.Save(a.ID, src); err != nil {
+		return errors.Wrap(err, "can't save asset file")
+	}
+	return data.AssetStore.Save(a)
+}
+
+func assetGet(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("asset get method")()
+
+		a, err := getAsset(c, data)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, assetData{ID: a.ID, Name: a.Name, Tag: a.Tag, Ext: a.Ext})
+	}
+}
+
+// assetDelete removes the asset info and the file, the asset used by a not finished job is not deleted.
+// The info is deleted first so no new job gets the asset
+func assetDelete(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("asset delete method")()
+
+		if _, err := callerScope(c); err != nil {
+			return err
+		}
+		a, err := getAsset(c, data)
+		if err != nil {
+			return err
+		}
+		used, err := data.AssetStore.InUse(a.ID)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't check asset usage")
+		}
+		if used {
+			return echo.NewHTTPError(http.StatusConflict, "asset is used by a not finished job")
+		}
+		ok, err := data.AssetStore.Delete(a.ID)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't delete asset")
+		}
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "no asset by ID")
+		}
+		if err := data.AssetRemover.Remove(a.ID); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't delete asset file")
+		}
+		return c.JSON(http.StatusOK, result{ID: a.ID})
+	}
+}
+
+// getAsset loads the asset by the ID param, the asset of another caller is not found
+func getAsset(c echo.Context, data *Data) (*persistence.Asset, error) {
+	id := c.Param("id")
+	if id == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "no ID")
+	}
+	a, err := data.AssetStore.Get(id)
+	if err != nil {
+		goapp.Log.Error(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "can't get asset")
+	}
+	if a == nil || !canUse(c.Request(), a.Scope, a.Tag) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "no asset by ID")
+	}
+	return a, nil
+}
+
+// checkAssets verifies the intro, outro and music assets of the request exist and are available to the caller
+func checkAssets(c echo.Context, data *Data, inData *persistence.ReqData) error {
+	for _, id := range []string{inData.Intro, inData.Outro, inData.Music} {
+		if id == "" {
+			continue
+		}
+		a, err := data.AssetStore.Get(id)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't get asset")
+		}
+		if a == nil || !canUse(c.Request(), a.Scope, a.Tag) {
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown asset '"+id+"'")
+		}
+	}
+	return nil
+}
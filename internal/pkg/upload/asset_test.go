@@ -0,0 +1,207 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/petergtz/pegomock/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAssetRequest(file string, params [][2]string) *http.Request {
+	req := newTestRequest("file", file, "audio", params)
+	req.URL.Path = "/asset"
+	return req
+}
+
+func Test_AssetCreate(t *testing.T) {
+	initTest(t)
+	req := newTestAssetRequest("jingle.MP3", [][2]string{{"name", " jingle "}, {"tag", "t1"}})
+	req.Header.Set(HeaderSaveTags, "t1")
+	resp := testCode(t, req, http.StatusOK)
+	assert.Contains(t, resp.Body.String(), `"id":"`)
+	a := assetMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.Asset]()).GetCapturedArguments()
+	assert.NotEmpty(t, a.ID)
+	assert.Equal(t, "m", a.Scope)
+	assert.Equal(t, "jingle", a.Name)
+	assert.Equal(t, "t1", a.Tag)
+	assert.Equal(t, ".mp3", a.Ext)
+	name, r := aSaverMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[io.Reader]()).GetCapturedArguments()
+	assert.Equal(t, a.ID, name)
+	b, _ := io.ReadAll(r)
+	assert.Equal(t, "audio", string(b))
+}
+
+func Test_AssetCreate_Fail(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *http.Request
+		err      error
+		fileErr  error
+		wantCode int
+	}{
+		{name: "No file", req: newTestAssetRequest("", nil), wantCode: http.StatusBadRequest},
+		{name: "Wrong type", req: newTestAssetRequest("a.txt", nil), wantCode: http.StatusBadRequest},
+		{name: "Wrong tag", req: newTestAssetRequest("a.wav", [][2]string{{"tag", "t1"}}), wantCode: http.StatusBadRequest},
+		{name: "Fail file", req: newTestAssetRequest("a.wav", nil), fileErr: errors.New("olia"),
+			wantCode: http.StatusInternalServerError},
+		{name: "Fail", req: newTestAssetRequest("a.wav", nil), err: errors.New("olia"),
+			wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(aSaverMock.Save(pegomock.Any[string](), pegomock.Any[io.Reader]())).ThenReturn(tt.fileErr)
+			pegomock.When(assetMock.Save(pegomock.Any[*persistence.Asset]())).ThenReturn(tt.err)
+			testCode(t, tt.req, tt.wantCode)
+		})
+	}
+}
+
+func Test_AssetGet(t *testing.T) {
+	initTest(t)
+	pegomock.When(assetMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Asset{ID: "1", Scope: "m", Name: "n",
+		Ext: ".mp3"}, nil)
+	resp := testCode(t, newTestLexiconRequest(http.MethodGet, "/asset/1", ""), http.StatusOK)
+	assert.Equal(t, `{"id":"1","name":"n","ext":".mp3"}`+"\n", resp.Body.String())
+}
+
+func Test_AssetGet_NotFound(t *testing.T) {
+	initTest(t)
+	pegomock.When(assetMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Asset{ID: "1", Scope: "m1"}, nil)
+	testCode(t, newTestLexiconRequest(http.MethodGet, "/asset/1", ""), http.StatusNotFound)
+}
+
+func Test_AssetDelete(t *testing.T) {
+	initTest(t)
+	pegomock.When(assetMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Asset{ID: "1", Scope: "m"}, nil)
+	pegomock.When(assetMock.Delete(pegomock.Any[string]())).ThenReturn(true, nil)
+	resp := testCode(t, newTestLexiconRequest(http.MethodDelete, "/asset/1", ""), http.StatusOK)
+	assert.Equal(t, `{"id":"1"}`+"\n", resp.Body.String())
+	assert.Equal(t, "1", assetMock.VerifyWasCalledOnce().Delete(pegomock.Any[string]()).GetCapturedArguments())
+	assert.Equal(t, "1", aRemMock.VerifyWasCalledOnce().Remove(pegomock.Any[string]()).GetCapturedArguments())
+	assert.Equal(t, "1", assetMock.VerifyWasCalledOnce().InUse(pegomock.Any[string]()).GetCapturedArguments())
+}
+
+func Test_AssetDelete_Fail(t *testing.T) {
+	tests := []struct {
+		name    string
+		used    bool
+		usedErr error
+		deleted bool
+		remErr  error
+		code    int
+	}{
+		{name: "Not found", deleted: false, code: http.StatusNotFound},
+		{name: "Remove", deleted: true, remErr: errors.New("err"), code: http.StatusInternalServerError},
+		{name: "In use", used: true, deleted: true, code: http.StatusConflict},
+		{name: "Usage fail", usedErr: errors.New("err"), deleted: true, code: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(assetMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Asset{ID: "1", Scope: "m"}, nil)
+			pegomock.When(assetMock.InUse(pegomock.Any[string]())).ThenReturn(tt.used, tt.usedErr)
+			pegomock.When(assetMock.Delete(pegomock.Any[string]())).ThenReturn(tt.deleted, nil)
+			pegomock.When(aRemMock.Remove(pegomock.Any[string]())).ThenReturn(tt.remErr)
+			testCode(t, newTestLexiconRequest(http.MethodDelete, "/asset/1", ""), tt.code)
+			if tt.used || tt.usedErr != nil {
+				assetMock.VerifyWasCalled(pegomock.Never()).Delete(pegomock.Any[string]())
+			}
+			if !tt.deleted || tt.used || tt.usedErr != nil {
+				aRemMock.VerifyWasCalled(pegomock.Never()).Remove(pegomock.Any[string]())
+			}
+		})
+	}
+}
+
+func Test_Asset_NoScope(t *testing.T) {
+	initTest(t)
+	pegomock.When(assetMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Asset{ID: "1"}, nil)
+	req := newTestAssetRequest("jingle.mp3", nil)
+	req.Header.Del(requestIDHEader)
+	testCode(t, req, http.StatusForbidden)
+	req = newTestLexiconRequest(http.MethodDelete, "/asset/1", "")
+	req.Header.Del(requestIDHEader)
+	testCode(t, req, http.StatusForbidden)
+	assetMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[*persistence.Asset]())
+	assetMock.VerifyWasCalled(pegomock.Never()).Delete(pegomock.Any[string]())
+	aSaverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_Synthesize_Assets(t *testing.T) {
+	initTest(t)
+	pegomock.When(assetMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Asset{ID: "a", Scope: "m"}, nil)
+	testCode(t, newTestJSONRequest(`{"text":"olia","intro":"a1","outro":"a2","music":"a3",
+		"metadata":{"title":" Olia ","author":""}}`), http.StatusOK)
+	assert.Equal(t, []string{"a1", "a2", "a3"},
+		assetMock.VerifyWasCalled(pegomock.Times(3)).Get(pegomock.Any[string]()).GetAllCapturedArguments())
+	rd := rSaverMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.ReqData]()).GetCapturedArguments()
+	assert.Equal(t, "a1", rd.Intro)
+	assert.Equal(t, map[string]string{"title": "Olia"}, rd.Metadata)
+	msg, _, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	tm := msg.(*messages.TTSMessage)
+	assert.Equal(t, []string{"a1", "a2", "a3"}, []string{tm.Intro, tm.Outro, tm.Music})
+	assert.Equal(t, map[string]string{"title": "Olia"}, tm.Metadata)
+}
+
+func Test_Upload_Assets(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        *persistence.Asset
+		err      error
+		wantCode int
+	}{
+		{name: "OK", a: &persistence.Asset{ID: "a1", Scope: "m"}, wantCode: http.StatusOK},
+		{name: "Not found", wantCode: http.StatusBadRequest},
+		{name: "Other scope", a: &persistence.Asset{ID: "a1", Scope: "m1"}, wantCode: http.StatusBadRequest},
+		{name: "Fail", err: errors.New("olia"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(assetMock.Get(pegomock.Any[string]())).ThenReturn(tt.a, tt.err)
+			resp := testCode(t, newTestRequest("file", "file.txt", "olia",
+				[][2]string{{"music", "a1"}, {"metadata.title", "Olia"}}), tt.wantCode)
+			if tt.wantCode == http.StatusBadRequest {
+				b, _ := io.ReadAll(resp.Body)
+				assert.True(t, strings.Contains(string(b), "unknown asset 'a1'"))
+			}
+			if tt.wantCode == http.StatusOK {
+				rd := rSaverMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.ReqData]()).GetCapturedArguments()
+				assert.Equal(t, "a1", rd.Music)
+				assert.Equal(t, map[string]string{"title": "Olia"}, rd.Metadata)
+			}
+		})
+	}
+}
+
+func Test_getMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", in: nil, want: nil},
+		{name: "empty values", in: map[string]string{"a": " "}, want: nil},
+		{name: "ok", in: map[string]string{"title": " t ", "a_1": "b"}, want: map[string]string{"title": "t", "a_1": "b"}},
+		{name: "wrong key", in: map[string]string{"1a": "b"}, wantErr: true},
+		{name: "wrong key symbols", in: map[string]string{"a.b": "b"}, wantErr: true},
+		{name: "long", in: map[string]string{"a": strings.Repeat("a", 1001)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getMetadata(tt.in)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
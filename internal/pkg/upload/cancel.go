@@ -0,0 +1,50 @@
+package upload
+
+import (
+	"net/http"
+
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/status"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/labstack/echo/v4"
+)
+
+// JobCanceler marks the job as canceled
+type JobCanceler interface {
+	// Cancel returns the status before the call, nil if there is no job
+	Cancel(id string) (*persistence.Status, error)
+}
+
+type cancelResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func cancel(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("cancel method")()
+
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "no ID")
+		}
+		st, err := data.Canceler.Cancel(id)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't cancel")
+		}
+		if st == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "no job by ID")
+		}
+		switch {
+		case st.Status == status.Cancelled.String():
+			return echo.NewHTTPError(http.StatusConflict, "job is already canceled")
+		case st.Status == status.Completed.String():
+			return echo.NewHTTPError(http.StatusConflict, "job is completed")
+		case st.Error != "":
+			return echo.NewHTTPError(http.StatusConflict, "job is failed")
+		}
+		goapp.Log.Infof("Canceled %s", id)
+		return c.JSON(http.StatusOK, cancelResult{ID: id, Status: status.Cancelled.String()})
+	}
+}
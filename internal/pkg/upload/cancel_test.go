@@ -0,0 +1,44 @@
+package upload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/petergtz/pegomock/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cancel(t *testing.T) {
+	initTest(t)
+	pegomock.When(cancelMock.Cancel(pegomock.Any[string]())).ThenReturn(&persistence.Status{ID: "1", Status: "Synthesize"}, nil)
+	req := httptest.NewRequest(http.MethodPost, "/cancel/1", nil)
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, `{"id":"1","status":"CANCELLED"}`+"\n", resp.Body.String())
+	assert.Equal(t, "1", cancelMock.VerifyWasCalledOnce().Cancel(pegomock.Any[string]()).GetCapturedArguments())
+}
+
+func Test_Cancel_Fail(t *testing.T) {
+	tests := []struct {
+		name     string
+		st       *persistence.Status
+		err      error
+		wantCode int
+	}{
+		{name: "Not found", wantCode: http.StatusNotFound},
+		{name: "Fail", err: errors.New("olia"), wantCode: http.StatusInternalServerError},
+		{name: "Completed", st: &persistence.Status{Status: "COMPLETED"}, wantCode: http.StatusConflict},
+		{name: "Canceled", st: &persistence.Status{Status: "CANCELLED"}, wantCode: http.StatusConflict},
+		{name: "Failed", st: &persistence.Status{Status: "Split", Error: "err"}, wantCode: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(cancelMock.Cancel(pegomock.Any[string]())).ThenReturn(tt.st, tt.err)
+			req := httptest.NewRequest(http.MethodPost, "/cancel/1", nil)
+			testCode(t, req, tt.wantCode)
+		})
+	}
+}
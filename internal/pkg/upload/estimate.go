@@ -0,0 +1,138 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/big-tts/internal/pkg/textnorm"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// TextEstimator splits the text without queuing anything
+type TextEstimator interface {
+	Estimate(text string, voice string, speed float64) (*splitter.Estimation, error)
+}
+
+// SpeechRate keeps average voice speed in characters per second
+type SpeechRate struct {
+	def    float64
+	voices map[string]float64
+}
+
+// NewSpeechRate creates speech rate from the default value and 'voice:rate' values
+func NewSpeechRate(def float64, voiceRates []string) (*SpeechRate, error) {
+	if def <= 0 {
+		return nil, errors.Errorf("wrong default chars per second %f", def)
+	}
+	res := &SpeechRate{def: def, voices: map[string]float64{}}
+	for _, s := range voiceRates {
+		strs := strings.Split(s, ":")
+		if len(strs) != 2 || strings.TrimSpace(strs[0]) == "" {
+			return nil, errors.Errorf("wrong voice rate '%s', expected 'voice:rate'", s)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(strs[1]), 64)
+		if err != nil || v <= 0 {
+			return nil, errors.Errorf("wrong voice rate '%s'", s)
+		}
+		res.voices[strings.TrimSpace(strs[0])] = v
+	}
+	goapp.Log.Infof("Speech rate: default %.2f chars/s, voices: %v", res.def, res.voices)
+	return res, nil
+}
+
+// Duration returns approximate audio duration for the chars count
+func (sr *SpeechRate) Duration(voice string, chars int, speed float64) time.Duration {
+	rate, ok := sr.voices[voice]
+	if !ok {
+		rate = sr.def
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+	return time.Duration(float64(chars) / rate * speed * float64(time.Second))
+}
+
+type estimateResult struct {
+	Chars    int      `json:"chars"`
+	Parts    int      `json:"parts"`
+	Chapters int      `json:"chapters,omitempty"`
+	Duration float64  `json:"duration"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func estimate(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("estimate method")()
+
+		text, input, err := getEstimateInput(c, data)
+		if err != nil {
+			return err
+		}
+		inData, err := data.Configurator.Configure(c.Request(), input)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := checkTextVoices(data, text, inData.Speed); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		est, err := data.Estimator.Estimate(string(text), inData.Voice, inData.Speed)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusBadRequest, "can't split text: "+err.Error())
+		}
+		d := data.SpeechRate.Duration(inData.Voice, est.Chars, inData.Speed) + est.Pauses
+		return c.JSON(http.StatusOK, estimateResult{Chars: est.Chars, Parts: est.Parts, Chapters: est.Chapters,
+			Duration: d.Round(time.Second).Seconds(), Warnings: est.Warnings})
+	}
+}
+
+// getEstimateInput reads a text from a multipart file or from a JSON
+func getEstimateInput(c echo.Context, data *Data) ([]byte, *Input, error) {
+	if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		var input Input
+		if err := decodeJSON(c, &input); err != nil {
+			return nil, nil, err
+		}
+		if strings.TrimSpace(input.Text) == "" {
+			return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "no text")
+		}
+		return textnorm.Normalize([]byte(input.Text)), &input, nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "no multipart form data")
+	}
+	defer cleanFiles(form)
+	files, ok := form.File["file"]
+	if !ok || len(files) != 1 {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "no file")
+	}
+	ext := strings.ToLower(filepath.Ext(files[0].Filename))
+	if !checkFileExtension(ext) {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "wrong file type: "+ext)
+	}
+	src, err := files[0].Open()
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "can't read file")
+	}
+	defer src.Close()
+	b, err := io.ReadAll(src)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "can't read file")
+	}
+	res, err := toText(data, ext, b)
+	if err != nil {
+		goapp.Log.Error(err)
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return res, getFormInput(c), nil
+}
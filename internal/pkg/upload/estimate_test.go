@@ -0,0 +1,129 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/labstack/echo/v4"
+	"github.com/petergtz/pegomock/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSpeechRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     float64
+		voices  []string
+		wantErr bool
+	}{
+		{name: "OK", def: 10, voices: []string{"astra:12.5", " vyt : 14 "}, wantErr: false},
+		{name: "No voices", def: 10, wantErr: false},
+		{name: "Fail default", def: 0, wantErr: true},
+		{name: "Fail format", def: 10, voices: []string{"astra"}, wantErr: true},
+		{name: "Fail voice", def: 10, voices: []string{":10"}, wantErr: true},
+		{name: "Fail rate", def: 10, voices: []string{"astra:aa"}, wantErr: true},
+		{name: "Fail negative", def: 10, voices: []string{"astra:-1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSpeechRate(tt.def, tt.voices)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestSpeechRate_Duration(t *testing.T) {
+	sr, err := NewSpeechRate(10, []string{"vyt:20"})
+	assert.Nil(t, err)
+	assert.Equal(t, 10*time.Second, sr.Duration("astra", 100, 1))
+	assert.Equal(t, 5*time.Second, sr.Duration("vyt", 100, 1))
+	assert.Equal(t, 10*time.Second, sr.Duration("vyt", 100, 2))
+	assert.Equal(t, 10*time.Second, sr.Duration("astra", 100, 0))
+}
+
+func TestEstimate_JSON(t *testing.T) {
+	initTest(t)
+	pegomock.When(estMock.Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]())).
+		ThenReturn(&splitter.Estimation{Chars: 100, Parts: 2, Pauses: time.Second, Warnings: []string{"olia"}}, nil)
+	req := newTestEstimateRequest(`{"text":"olia","voice":"vyt"}`)
+
+	resp := testCode(t, req, http.StatusOK)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"chars":100,"parts":2,"duration":6,"warnings":["olia"]}`, strings.TrimSpace(string(b)))
+	txt, voice, _ := estMock.VerifyWasCalledOnce().Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]()).GetCapturedArguments()
+	assert.Equal(t, "olia", txt)
+	assert.Equal(t, "vyt", voice)
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+	senderMock.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func TestEstimate_File(t *testing.T) {
+	initTest(t)
+	pegomock.When(estMock.Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]())).
+		ThenReturn(&splitter.Estimation{Chars: 100, Parts: 1}, nil)
+	req := newTestRequest("file", "file.txt", "ol\r\nia", [][2]string{{"speed", "2"}})
+	req.URL.Path = "/estimate"
+
+	resp := testCode(t, req, http.StatusOK)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"chars":100,"parts":1,"duration":20}`, strings.TrimSpace(string(b)))
+	txt, voice, speed := estMock.VerifyWasCalledOnce().Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]()).GetCapturedArguments()
+	assert.Equal(t, "ol\nia", txt)
+	assert.Equal(t, "astra", voice)
+	assert.Equal(t, 2.0, speed)
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func TestEstimate_400(t *testing.T) {
+	tests := []struct {
+		name string
+		req  func() *http.Request
+	}{
+		{name: "No text", req: func() *http.Request { return newTestEstimateRequest(`{"text":" "}`) }},
+		{name: "Wrong JSON", req: func() *http.Request { return newTestEstimateRequest(`{"text":`) }},
+		{name: "Wrong voice", req: func() *http.Request { return newTestEstimateRequest(`{"text":"olia","voice":"aaa"}`) }},
+		{name: "Wrong text voice", req: func() *http.Request { return newTestEstimateRequest(`{"text":"[voice=aaa]olia"}`) }},
+		{name: "Wrong file", req: func() *http.Request {
+			res := newTestRequest("file", "file.wav", "olia", nil)
+			res.URL.Path = "/estimate"
+			return res
+		}},
+		{name: "No file", req: func() *http.Request {
+			res := newTestRequest("file1", "file.txt", "olia", nil)
+			res.URL.Path = "/estimate"
+			return res
+		}},
+		{name: "Wrong encoding", req: func() *http.Request {
+			res := newTestRequest("file", "file.txt", "ol\x00ia", nil)
+			res.URL.Path = "/estimate"
+			return res
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			testCode(t, tt.req(), http.StatusBadRequest)
+			estMock.VerifyWasCalled(pegomock.Never()).Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]())
+		})
+	}
+}
+
+func TestEstimate_Fails(t *testing.T) {
+	initTest(t)
+	pegomock.When(estMock.Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]())).
+		ThenReturn(nil, errors.New("err"))
+	testCode(t, newTestEstimateRequest(`{"text":"olia"}`), http.StatusBadRequest)
+}
+
+func newTestEstimateRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/estimate", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req
+}
@@ -0,0 +1,105 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// IdempotencyStore keeps idempotency key to ID mapping
+type IdempotencyStore interface {
+	// Reserve returns the ID and the content hash the key is mapped to
+	Reserve(key, id, hash string, window time.Duration) (string, string, error)
+	Release(key, id string) error
+}
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// reserveKey maps the request key to the id. Returns the ID of an earlier job
+// and the reserved key, the key is empty if the request has no key.
+// A key reused with a different request fails with 422
+func reserveKey(c echo.Context, data *Data, id string, content []byte, inData *persistence.ReqData) (string, string, error) {
+	if data.KeyWindow <= 0 {
+		return "", "", nil
+	}
+	hash := contentHash(content, inData)
+	key := getIdempotencyKey(c, data, hash)
+	if key == "" {
+		return "", "", nil
+	}
+	res, resHash, err := data.KeyStore.Reserve(key, id, hash, data.KeyWindow)
+	if err != nil {
+		goapp.Log.Error(err)
+		return "", "", errors.Wrap(err, "can't reserve idempotency key")
+	}
+	if res != id {
+		// the keys saved without a hash are not checked
+		if resHash != "" && resHash != hash {
+			return "", "", echo.NewHTTPError(http.StatusUnprocessableEntity,
+				"idempotency key is used with a different request")
+		}
+		goapp.Log.Infof("Found job %s for the idempotency key", res)
+		return res, "", nil
+	}
+	return "", key, nil
+}
+
+// releaseKey frees the key if the job was not started
+func releaseKey(data *Data, key, id string) {
+	if key == "" {
+		return
+	}
+	if err := data.KeyStore.Release(key, id); err != nil {
+		goapp.Log.Error(errors.Wrap(err, "can't release idempotency key"))
+	}
+}
+
+func getIdempotencyKey(c echo.Context, data *Data, hash string) string {
+	scope := requestScope(extractRequestID(c.Request().Header))
+	if scope == "" {
+		// keys of unknown callers would be shared by all of them
+		return ""
+	}
+	if k := strings.TrimSpace(c.Request().Header.Get(idempotencyKeyHeader)); k != "" {
+		return scope + ":key:" + k
+	}
+	if !data.KeyFromContent {
+		return ""
+	}
+	return scope + ":hash:" + hash
+}
+
+// contentHash returns the hash of the text and the synthesis parameters
+func contentHash(content []byte, inData *persistence.ReqData) string {
+	h := sha256.New()
+	h.Write(content)
+	fmt.Fprintf(h, "\n%s\n%g\n%s\n%d\n%d", inData.Voice, inData.Speed, inData.OutputFormat,
+		inData.Bitrate, inData.SampleRate)
+	if inData.Lexicon != "" {
+		fmt.Fprintf(h, "\n%s", inData.Lexicon)
+	}
+	if inData.Intro != "" || inData.Outro != "" || inData.Music != "" {
+		fmt.Fprintf(h, "\n%s\n%s\n%s", inData.Intro, inData.Outro, inData.Music)
+	}
+	if inData.MaxDuration > 0 || inData.SplitBy != "" {
+		fmt.Fprintf(h, "\n%s\n%s", inData.MaxDuration, inData.SplitBy)
+	}
+	keys := make([]string, 0, len(inData.Metadata))
+	for k := range inData.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "\n%s=%s", k, inData.Metadata[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
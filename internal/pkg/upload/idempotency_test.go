@@ -0,0 +1,174 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/petergtz/pegomock/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency_NewJob(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).Then(
+		func(params []pegomock.Param) pegomock.ReturnValues {
+			return []pegomock.ReturnValue{params[1], params[2], nil}
+		})
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+
+	testCode(t, req, http.StatusOK)
+	key, _, _, window := keyMock.VerifyWasCalledOnce().Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]()).GetCapturedArguments()
+	assert.Equal(t, "m:key:k1", key)
+	assert.Equal(t, time.Hour, window)
+	senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+	keyMock.VerifyWasCalled(pegomock.Never()).Release(pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func TestIdempotency_ReturnsOld(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).
+		ThenReturn("oldID", "", nil)
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+
+	resp := testCode(t, req, http.StatusOK)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"id":"oldID"}`, strings.TrimSpace(string(b)))
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+	rSaverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[*persistence.ReqData]())
+	senderMock.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func TestIdempotency_SameContent(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).Then(
+		func(params []pegomock.Param) pegomock.ReturnValues {
+			return []pegomock.ReturnValue{"oldID", params[2], nil}
+		})
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+
+	resp := testCode(t, req, http.StatusOK)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"id":"oldID"}`, strings.TrimSpace(string(b)))
+}
+
+func TestIdempotency_OtherContent(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).ThenReturn("oldID", "otherHash", nil)
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+
+	testCode(t, req, http.StatusUnprocessableEntity)
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+	keyMock.VerifyWasCalled(pegomock.Never()).Release(pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func TestIdempotency_Synthesize(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).
+		ThenReturn("oldID", "", nil)
+	req := newTestJSONRequest(`{"text":"olia"}`)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+
+	testCode(t, req, http.StatusOK)
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func TestIdempotency_Releases(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).Then(
+		func(params []pegomock.Param) pegomock.ReturnValues {
+			return []pegomock.ReturnValue{params[1], params[2], nil}
+		})
+	pegomock.When(senderMock.Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())).
+		ThenReturn(errors.New("err"))
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+
+	testCode(t, req, http.StatusInternalServerError)
+	key, _ := keyMock.VerifyWasCalledOnce().Release(pegomock.Any[string](), pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "m:key:k1", key)
+}
+
+func TestIdempotency_Fails(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).
+		ThenReturn("", "", errors.New("err"))
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+
+	testCode(t, req, http.StatusInternalServerError)
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func TestIdempotency_NoKey(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	testCode(t, newTestRequest("file", "file.txt", "olia", nil), http.StatusOK)
+	keyMock.VerifyWasCalled(pegomock.Never()).Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())
+}
+
+func TestIdempotency_Disabled(t *testing.T) {
+	initTest(t)
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+	testCode(t, req, http.StatusOK)
+	keyMock.VerifyWasCalled(pegomock.Never()).Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())
+}
+
+func TestIdempotency_ContentHash(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	tData.KeyFromContent = true
+	pegomock.When(keyMock.Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())).
+		ThenReturn("oldID", "", nil)
+	testCode(t, newTestRequest("file", "file.txt", "olia", nil), http.StatusOK)
+	key1, _, _, _ := keyMock.VerifyWasCalledOnce().Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]()).GetCapturedArguments()
+	assert.True(t, strings.HasPrefix(key1, "m:hash:"))
+}
+
+func TestIdempotency_NoScope(t *testing.T) {
+	initTest(t)
+	tData.KeyWindow = time.Hour
+	tData.KeyFromContent = true
+	req := newTestRequest("file", "file.txt", "olia", nil)
+	req.Header.Del(requestIDHEader)
+	req.Header.Set(idempotencyKeyHeader, "k1")
+	testCode(t, req, http.StatusOK)
+	keyMock.VerifyWasCalled(pegomock.Never()).Reserve(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[string](),
+		pegomock.Any[time.Duration]())
+}
+
+func Test_requestScope(t *testing.T) {
+	assert.Equal(t, "", requestScope(""))
+	assert.Equal(t, "srv", requestScope("srv"))
+	assert.Equal(t, "srv", requestScope("srv:1"))
+	assert.Equal(t, "srv:key", requestScope("srv:key:1"))
+}
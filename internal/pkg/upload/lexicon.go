@@ -0,0 +1,190 @@
+package upload
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// LexiconStore keeps user lexicons
+type LexiconStore interface {
+	Save(data *persistence.Lexicon) error
+	// Get returns nil if there is no lexicon
+	Get(id string) (*persistence.Lexicon, error)
+	Delete(id string) (bool, error)
+}
+
+type lexiconEntry struct {
+	Word      string `json:"word"`
+	Text      string `json:"text,omitempty"`
+	Accented  string `json:"acc,omitempty"`
+	Syllables string `json:"syll,omitempty"`
+	User      string `json:"user,omitempty"`
+}
+
+type lexiconData struct {
+	ID      string         `json:"id,omitempty"`
+	Name    string         `json:"name,omitempty"`
+	Tag     string         `json:"tag,omitempty"`
+	Entries []lexiconEntry `json:"entries"`
+}
+
+func lexiconCreate(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("lexicon create method")()
+
+		return saveLexicon(c, data, uuid.New().String())
+	}
+}
+
+func lexiconUpdate(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("lexicon update method")()
+
+		if _, err := callerScope(c); err != nil {
+			return err
+		}
+		if _, err := getLexicon(c, data); err != nil {
+			return err
+		}
+		return saveLexicon(c, data, c.Param("id"))
+	}
+}
+
+func lexiconGet(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("lexicon get method")()
+
+		l, err := getLexicon(c, data)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, toLexiconData(l))
+	}
+}
+
+func lexiconDelete(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("lexicon delete method")()
+
+		if _, err := callerScope(c); err != nil {
+			return err
+		}
+		l, err := getLexicon(c, data)
+		if err != nil {
+			return err
+		}
+		ok, err := data.LexiconStore.Delete(l.ID)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't delete lexicon")
+		}
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "no lexicon by ID")
+		}
+		return c.JSON(http.StatusOK, result{ID: l.ID})
+	}
+}
+
+func saveLexicon(c echo.Context, data *Data, id string) error {
+	var input lexiconData
+	if err := decodeJSON(c, &input); err != nil {
+		return err
+	}
+	scope, err := callerScope(c)
+	if err != nil {
+		return err
+	}
+	l := &persistence.Lexicon{ID: id, Scope: scope,
+		Name: strings.TrimSpace(input.Name), Tag: strings.TrimSpace(input.Tag)}
+	if l.Tag != "" && !hasSaveTag(c.Request(), l.Tag) {
+		return echo.NewHTTPError(http.StatusBadRequest, "wrong tag '"+l.Tag+"'")
+	}
+	for _, e := range input.Entries {
+		l.Entries = append(l.Entries, persistence.LexiconEntry{Word: strings.TrimSpace(e.Word),
+			Text: strings.TrimSpace(e.Text), Accented: strings.TrimSpace(e.Accented),
+			Syllables: strings.TrimSpace(e.Syllables), User: strings.TrimSpace(e.User)})
+	}
+	if err := splitter.ValidateLexicon(l.Entries); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "wrong lexicon: "+err.Error())
+	}
+	if err := data.LexiconStore.Save(l); err != nil {
+		goapp.Log.Error(err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "can't save lexicon")
+	}
+	goapp.Log.Infof("Saved lexicon %s, entries %d", l.ID, len(l.Entries))
+	return c.JSON(http.StatusOK, result{ID: l.ID})
+}
+
+// getLexicon loads the lexicon by the ID param, the lexicon of another caller is not found
+func getLexicon(c echo.Context, data *Data) (*persistence.Lexicon, error) {
+	id := c.Param("id")
+	if id == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "no ID")
+	}
+	l, err := data.LexiconStore.Get(id)
+	if err != nil {
+		goapp.Log.Error(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "can't get lexicon")
+	}
+	if l == nil || !canUse(c.Request(), l.Scope, l.Tag) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "no lexicon by ID")
+	}
+	return l, nil
+}
+
+// checkLexicon verifies the lexicon of the synthesis request exists and is available to the caller
+func checkLexicon(c echo.Context, data *Data, inData *persistence.ReqData) error {
+	if inData.Lexicon == "" {
+		return nil
+	}
+	l, err := data.LexiconStore.Get(inData.Lexicon)
+	if err != nil {
+		goapp.Log.Error(err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "can't get lexicon")
+	}
+	if l == nil || !canUse(c.Request(), l.Scope, l.Tag) {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown lexicon '"+inData.Lexicon+"'")
+	}
+	return nil
+}
+
+// canUse checks the caller's scope of a lexicon or an asset, a tagged one is shared by the save tag.
+// An untagged one without a scope is not available to anyone
+func canUse(r *http.Request, scope, tag string) bool {
+	if tag != "" {
+		return hasSaveTag(r, tag)
+	}
+	return scope != "" && scope == requestScope(extractRequestID(r.Header))
+}
+
+// callerScope returns the caller's scope required to create, change or delete a lexicon or an asset
+func callerScope(c echo.Context) (string, error) {
+	res := requestScope(extractRequestID(c.Request().Header))
+	if res == "" {
+		return "", echo.NewHTTPError(http.StatusForbidden, "no caller ID")
+	}
+	return res, nil
+}
+
+func hasSaveTag(r *http.Request, tag string) bool {
+	for _, t := range getSaveTags(getHeader(r, HeaderSaveTags)) {
+		if strings.TrimSpace(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func toLexiconData(l *persistence.Lexicon) *lexiconData {
+	res := &lexiconData{ID: l.ID, Name: l.Name, Tag: l.Tag, Entries: make([]lexiconEntry, len(l.Entries))}
+	for i, e := range l.Entries {
+		res.Entries[i] = lexiconEntry{Word: e.Word, Text: e.Text, Accented: e.Accented, Syllables: e.Syllables, User: e.User}
+	}
+	return res
+}
@@ -0,0 +1,212 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/petergtz/pegomock/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLexiconRequest(method, path, body string) *http.Request {
+	req := newTestJSONRequest(body)
+	req.Method, req.URL.Path = method, path
+	return req
+}
+
+func Test_LexiconCreate(t *testing.T) {
+	initTest(t)
+	req := newTestLexiconRequest(http.MethodPost, "/lexicon", `{"name":"olia","entries":[{"word":" NATO ","text":"nato"},
+		{"word":"olia","acc":"oli{a/}","syll":"o-li-a"}]}`)
+	resp := testCode(t, req, http.StatusOK)
+	assert.Contains(t, resp.Body.String(), `"id":"`)
+	l := lexMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.Lexicon]()).GetCapturedArguments()
+	assert.NotEmpty(t, l.ID)
+	assert.Equal(t, "m", l.Scope)
+	assert.Equal(t, "olia", l.Name)
+	assert.Equal(t, []persistence.LexiconEntry{{Word: "NATO", Text: "nato"},
+		{Word: "olia", Accented: "oli{a/}", Syllables: "o-li-a"}}, l.Entries)
+}
+
+func Test_LexiconCreate_Tag(t *testing.T) {
+	initTest(t)
+	req := newTestLexiconRequest(http.MethodPost, "/lexicon", `{"tag":"t2","entries":[{"word":"NATO","text":"nato"}]}`)
+	req.Header.Set(HeaderSaveTags, "t1,t2")
+	testCode(t, req, http.StatusOK)
+	l := lexMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.Lexicon]()).GetCapturedArguments()
+	assert.Equal(t, "t2", l.Tag)
+}
+
+func Test_LexiconCreate_Fail(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		err      error
+		wantCode int
+	}{
+		{name: "No JSON", body: `olia`, wantCode: http.StatusBadRequest},
+		{name: "No entries", body: `{"entries":[]}`, wantCode: http.StatusBadRequest},
+		{name: "Wrong word", body: `{"entries":[{"word":"olia olia","text":"a"}]}`, wantCode: http.StatusBadRequest},
+		{name: "Wrong tag", body: `{"tag":"t1","entries":[{"word":"olia","text":"a"}]}`, wantCode: http.StatusBadRequest},
+		{name: "Fail", body: `{"entries":[{"word":"olia","text":"a"}]}`, err: errors.New("olia"),
+			wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(lexMock.Save(pegomock.Any[*persistence.Lexicon]())).ThenReturn(tt.err)
+			testCode(t, newTestLexiconRequest(http.MethodPost, "/lexicon", tt.body), tt.wantCode)
+		})
+	}
+}
+
+func Test_LexiconGet(t *testing.T) {
+	initTest(t)
+	pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Lexicon{ID: "1", Scope: "m", Name: "n",
+		Entries: []persistence.LexiconEntry{{Word: "olia", Accented: "oli{a/}", User: "o'lia"}}}, nil)
+	resp := testCode(t, newTestLexiconRequest(http.MethodGet, "/lexicon/1", ""), http.StatusOK)
+	assert.Equal(t, `{"id":"1","name":"n","entries":[{"word":"olia","acc":"oli{a/}","user":"o'lia"}]}`+"\n", resp.Body.String())
+}
+
+func Test_LexiconGet_Fail(t *testing.T) {
+	tests := []struct {
+		name     string
+		l        *persistence.Lexicon
+		err      error
+		wantCode int
+	}{
+		{name: "Not found", wantCode: http.StatusNotFound},
+		{name: "Other scope", l: &persistence.Lexicon{ID: "1", Scope: "m1"}, wantCode: http.StatusNotFound},
+		{name: "Other tag", l: &persistence.Lexicon{ID: "1", Scope: "m", Tag: "t1"}, wantCode: http.StatusNotFound},
+		{name: "Fail", err: errors.New("olia"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(tt.l, tt.err)
+			testCode(t, newTestLexiconRequest(http.MethodGet, "/lexicon/1", ""), tt.wantCode)
+		})
+	}
+}
+
+func Test_LexiconUpdate(t *testing.T) {
+	initTest(t)
+	pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Lexicon{ID: "1", Scope: "m"}, nil)
+	testCode(t, newTestLexiconRequest(http.MethodPut, "/lexicon/1", `{"entries":[{"word":"olia","text":"a"}]}`), http.StatusOK)
+	l := lexMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.Lexicon]()).GetCapturedArguments()
+	assert.Equal(t, "1", l.ID)
+	assert.Equal(t, []persistence.LexiconEntry{{Word: "olia", Text: "a"}}, l.Entries)
+}
+
+func Test_LexiconUpdate_NotFound(t *testing.T) {
+	initTest(t)
+	pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Lexicon{ID: "1", Scope: "m1"}, nil)
+	testCode(t, newTestLexiconRequest(http.MethodPut, "/lexicon/1", `{"entries":[{"word":"olia","text":"a"}]}`),
+		http.StatusNotFound)
+	lexMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[*persistence.Lexicon]())
+}
+
+func Test_LexiconDelete(t *testing.T) {
+	initTest(t)
+	pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Lexicon{ID: "1", Scope: "m1", Tag: "t1"}, nil)
+	pegomock.When(lexMock.Delete(pegomock.Any[string]())).ThenReturn(true, nil)
+	req := newTestLexiconRequest(http.MethodDelete, "/lexicon/1", "")
+	req.Header.Set(HeaderSaveTags, "t1")
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, `{"id":"1"}`+"\n", resp.Body.String())
+	assert.Equal(t, "1", lexMock.VerifyWasCalledOnce().Delete(pegomock.Any[string]()).GetCapturedArguments())
+}
+
+func Test_LexiconDelete_Fail(t *testing.T) {
+	initTest(t)
+	pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Lexicon{ID: "1", Scope: "m"}, nil)
+	pegomock.When(lexMock.Delete(pegomock.Any[string]())).ThenReturn(false, errors.New("olia"))
+	testCode(t, newTestLexiconRequest(http.MethodDelete, "/lexicon/1", ""), http.StatusInternalServerError)
+}
+
+func Test_Synthesize_Lexicon(t *testing.T) {
+	initTest(t)
+	pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Lexicon{ID: "l1", Scope: "m"}, nil)
+	testCode(t, newTestJSONRequest(`{"text":"olia","lexicon":"l1"}`), http.StatusOK)
+	assert.Equal(t, "l1", lexMock.VerifyWasCalledOnce().Get(pegomock.Any[string]()).GetCapturedArguments())
+	rd := rSaverMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.ReqData]()).GetCapturedArguments()
+	assert.Equal(t, "l1", rd.Lexicon)
+	msg, _, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "l1", msg.(*messages.TTSMessage).Lexicon)
+}
+
+func Test_Upload_Lexicon(t *testing.T) {
+	tests := []struct {
+		name     string
+		l        *persistence.Lexicon
+		err      error
+		wantCode int
+	}{
+		{name: "OK", l: &persistence.Lexicon{ID: "l1", Scope: "m"}, wantCode: http.StatusOK},
+		{name: "Not found", wantCode: http.StatusBadRequest},
+		{name: "Other scope", l: &persistence.Lexicon{ID: "l1", Scope: "m1"}, wantCode: http.StatusBadRequest},
+		{name: "Fail", err: errors.New("olia"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(tt.l, tt.err)
+			resp := testCode(t, newTestRequest("file", "file.txt", "olia", [][2]string{{"lexicon", "l1"}}), tt.wantCode)
+			if tt.wantCode == http.StatusBadRequest {
+				b, _ := io.ReadAll(resp.Body)
+				assert.True(t, strings.Contains(string(b), "unknown lexicon 'l1'"))
+			}
+		})
+	}
+}
+
+func Test_Lexicon_NoScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "Create", method: http.MethodPost, path: "/lexicon", body: `{"entries":[{"word":"olia","text":"a"}]}`},
+		{name: "Update", method: http.MethodPut, path: "/lexicon/1", body: `{"entries":[{"word":"olia","text":"a"}]}`},
+		{name: "Delete", method: http.MethodDelete, path: "/lexicon/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(lexMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Lexicon{ID: "1", Tag: "t1"}, nil)
+			req := newTestLexiconRequest(tt.method, tt.path, tt.body)
+			req.Header.Del(requestIDHEader)
+			req.Header.Set(HeaderSaveTags, "t1")
+			testCode(t, req, http.StatusForbidden)
+			lexMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[*persistence.Lexicon]())
+			lexMock.VerifyWasCalled(pegomock.Never()).Delete(pegomock.Any[string]())
+		})
+	}
+}
+
+func Test_canUse(t *testing.T) {
+	req := newTestJSONRequest("")
+	req.Header.Set(HeaderSaveTags, "t1")
+	assert.True(t, canUse(req, "m", ""))
+	assert.False(t, canUse(req, "m1", ""))
+	assert.True(t, canUse(req, "m1", "t1"))
+	assert.False(t, canUse(req, "m", "t2"))
+	req.Header.Del(requestIDHEader)
+	assert.False(t, canUse(req, "", ""))
+	assert.True(t, canUse(req, "", "t1"))
+}
+
+func Test_LexiconRoutes(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPatch, "/lexicon/1", nil)
+	testCode(t, req, http.StatusMethodNotAllowed)
+}
@@ -1,11 +1,19 @@
 package upload
 
 import (
+	"encoding/json"
+	"math"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/airenas/big-tts/internal/pkg/audio"
 	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/utils"
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/labstack/echo/v4"
 
@@ -15,17 +23,29 @@ import (
 const (
 	headerDefaultFormat = "x-tts-default-output-format"
 	headerCollectData   = "x-tts-collect-data"
+	headerPriorityRange = "x-tts-priority-range"
 	//HeaderSaveTags http header name for providing tags for saving to DB
-	HeaderSaveTags      = "x-tts-save-tags"
+	HeaderSaveTags = "x-tts-save-tags"
 )
 
 //TTSConfigutaror tts request configuration
 type TTSConfigutaror struct {
 	defaultOutputFormat string
-	defaultVoice        string
-	availableVoices     map[string]bool
+	voices              *VoiceCatalog
+	defaultPriority     int
+	priorityRanges      map[string]priorityRange
 }
 
+type priorityRange struct {
+	from, to int
+}
+
+const (
+	// DefaultPriority is sent to the TTS backend if no priority is configured
+	DefaultPriority = 300
+	anyCaller       = "*"
+)
+
 //NewTTSConfigurator creates the initial request configuration
 func NewTTSConfigurator(format, voice string, voices []string) (*TTSConfigutaror, error) {
 	res := &TTSConfigutaror{}
@@ -35,20 +55,75 @@ func NewTTSConfigurator(format, voice string, voices []string) (*TTSConfigutaror
 		return nil, errors.Wrap(err, "can't init default format")
 	}
 	goapp.Log.Infof("Default output format: %s", res.defaultOutputFormat)
-	res.defaultVoice, res.availableVoices, err = initVoices(voice, voices)
+	res.voices, err = NewVoiceCatalog(voice, voices)
 	if err != nil {
 		return nil, errors.Wrap(err, "can't init voices")
 	}
-	goapp.Log.Infof("Voices. Default: %s, all: %v", res.defaultVoice, res.availableVoices)
+	goapp.Log.Infof("Voices. Default: %s, all: %v", res.voices.Default(), voiceNames(res.voices.List()))
+	res.defaultPriority = DefaultPriority
+	res.priorityRanges = map[string]priorityRange{anyCaller: {from: 0, to: math.MaxInt32}}
 	return res, nil
 }
 
+// Voices returns the voice catalog
+func (c *TTSConfigutaror) Voices() *VoiceCatalog {
+	return c.voices
+}
+
+//InitPriority sets the default priority and the allowed ranges per caller.
+//Ranges are 'caller:from-to', caller '*' is used if there is no caller specific range
+func (c *TTSConfigutaror) InitPriority(def int, ranges []string) error {
+	res := map[string]priorityRange{}
+	for _, s := range ranges {
+		i := strings.LastIndex(s, ":")
+		if i < 1 {
+			return errors.Errorf("wrong priority range '%s', expected 'caller:from-to'", s)
+		}
+		pr, err := parsePriorityRange(s[i+1:])
+		if err != nil {
+			return err
+		}
+		res[strings.TrimSpace(s[:i])] = pr
+	}
+	if _, ok := res[anyCaller]; !ok {
+		res[anyCaller] = priorityRange{from: 0, to: math.MaxInt32}
+	}
+	if def < 0 {
+		return errors.Errorf("wrong default priority %d", def)
+	}
+	c.defaultPriority, c.priorityRanges = def, res
+	goapp.Log.Infof("Priority. Default: %d, ranges: %v", c.defaultPriority, c.priorityRanges)
+	return nil
+}
+
+// Input keeps user provided synthesis parameters
+type Input struct {
+	Text         string      `json:"text,omitempty"`
+	Voice        string      `json:"voice,omitempty"`
+	Speed        json.Number `json:"speed,omitempty"`
+	OutputFormat string      `json:"outputFormat,omitempty"`
+	Email        string      `json:"email,omitempty"`
+	SaveRequest  *bool       `json:"saveRequest,omitempty"`
+	CallbackURL  string      `json:"callbackURL,omitempty"`
+	Priority     json.Number `json:"priority,omitempty"`
+	Bitrate      json.Number `json:"bitrate,omitempty"`
+	SampleRate   json.Number `json:"sampleRate,omitempty"`
+	Lexicon      string      `json:"lexicon,omitempty"`
+	// Intro, Outro and Music are the IDs of the audio assets
+	Intro    string            `json:"intro,omitempty"`
+	Outro    string            `json:"outro,omitempty"`
+	Music    string            `json:"music,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// MaxDuration is a Go duration, e.g. 1h30m
+	MaxDuration string `json:"maxDuration,omitempty"`
+	SplitBy     string `json:"splitBy,omitempty"`
+}
+
 //Configure prepares request configuration
-func (c *TTSConfigutaror) Configure(e echo.Context) (*persistence.ReqData, error) {
+func (c *TTSConfigutaror) Configure(r *http.Request, in *Input) (*persistence.ReqData, error) {
 	res := &persistence.ReqData{}
 	var err error
-	r := e.Request()
-	res.OutputFormat, err = getOutputAudioFormat(defaultS(e.FormValue("outputFormat"), getHeader(r, headerDefaultFormat)))
+	res.OutputFormat, err = getOutputAudioFormat(defaultS(in.OutputFormat, getHeader(r, headerDefaultFormat)))
 	if err != nil {
 		return nil, err
 	}
@@ -56,24 +131,76 @@ func (c *TTSConfigutaror) Configure(e echo.Context) (*persistence.ReqData, error
 		res.OutputFormat = c.defaultOutputFormat
 	}
 
-	res.SaveRequest, err = getAllowCollect(getBool(e.FormValue("saveRequest")), getHeader(r, headerCollectData))
+	res.Bitrate, res.SampleRate, err = getEncoding(res.OutputFormat, in.Bitrate.String(), in.SampleRate.String())
+	if err != nil {
+		return nil, err
+	}
+
+	res.SaveRequest, err = getAllowCollect(in.SaveRequest, getHeader(r, headerCollectData))
 	if err != nil {
 		return nil, err
 	}
 	res.SaveTags = getSaveTags(getHeader(r, HeaderSaveTags))
 
-	res.Speed, err = getSpeed(e.FormValue("speed"))
+	res.Speed, err = getSpeed(in.Speed.String())
+	if err != nil {
+		return nil, err
+	}
+	res.Voice, err = c.getVoice(in.Voice, res.Speed)
+	if err != nil {
+		return nil, err
+	}
+	res.Email = in.Email
+	res.Lexicon = strings.TrimSpace(in.Lexicon)
+	res.Intro, res.Outro, res.Music = strings.TrimSpace(in.Intro), strings.TrimSpace(in.Outro), strings.TrimSpace(in.Music)
+	res.Metadata, err = getMetadata(in.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	res.MaxDuration, res.SplitBy, err = getResultSplit(in.MaxDuration, in.SplitBy)
+	if err != nil {
+		return nil, err
+	}
+	res.CallbackURL, err = getCallbackURL(in.CallbackURL)
 	if err != nil {
 		return nil, err
 	}
-	res.Voice, err = c.getVoice(e.FormValue("voice"))
+	res.Priority, err = c.getPriority(in.Priority.String(), getHeader(r, headerPriorityRange),
+		getHeader(r, requestIDHEader))
 	if err != nil {
 		return nil, err
 	}
-	res.Email = e.FormValue("email")
 	return res, nil
 }
 
+func getFormInput(e echo.Context) *Input {
+	return &Input{Voice: e.FormValue("voice"), Speed: json.Number(e.FormValue("speed")),
+		OutputFormat: e.FormValue("outputFormat"), Email: e.FormValue("email"),
+		SaveRequest: getBool(e.FormValue("saveRequest")), CallbackURL: e.FormValue("callbackURL"),
+		Priority: json.Number(e.FormValue("priority")), Bitrate: json.Number(e.FormValue("bitrate")),
+		SampleRate: json.Number(e.FormValue("sampleRate")), Lexicon: e.FormValue("lexicon"),
+		Intro: e.FormValue("intro"), Outro: e.FormValue("outro"), Music: e.FormValue("music"),
+		Metadata: getFormMetadata(e), MaxDuration: e.FormValue("maxDuration"), SplitBy: e.FormValue("splitBy")}
+}
+
+// getFormMetadata collects the form fields 'metadata.<key>'
+func getFormMetadata(e echo.Context) map[string]string {
+	params, err := e.FormParams()
+	if err != nil {
+		return nil
+	}
+	var res map[string]string
+	for k, v := range params {
+		if key, ok := strings.CutPrefix(k, metadataFormPrefix); ok && len(v) > 0 {
+			if res == nil {
+				res = map[string]string{}
+			}
+			res[key] = v[0]
+		}
+	}
+	return res
+}
+
 func getBool(s string) *bool {
 	if s == "" {
 		return nil
@@ -101,36 +228,203 @@ func getAllowCollect(v *bool, s string) (bool, error) {
 
 func getOutputAudioFormat(s string) (string, error) {
 	st := strings.TrimSpace(s)
-	if st == "mp3" || st == "m4a" || st == "" {
+	if _, ok := audio.Get(st); ok || st == "" {
 		return st, nil
 	}
 	return "", errors.Errorf("unknown audio format '%s'", s)
 }
 
-func initVoices(def string, all []string) (string, map[string]bool, error) {
-	resVoice := strings.TrimSpace(def)
-	if resVoice == "" {
-		return "", nil, errors.New("no default voice")
+// getEncoding validates bitrate (kbps) and sample rate (Hz) for the format
+func getEncoding(format, bitrate, sampleRate string) (int, int, error) {
+	br, err := getOptionalInt(bitrate, "bitrate")
+	if err != nil {
+		return 0, 0, err
+	}
+	sr, err := getOptionalInt(sampleRate, "sampleRate")
+	if err != nil {
+		return 0, 0, err
+	}
+	f, ok := audio.Get(format)
+	if !ok {
+		return 0, 0, errors.Errorf("unknown audio format '%s'", format)
+	}
+	if err := f.ValidateBitrate(br); err != nil {
+		return 0, 0, err
+	}
+	if err := f.ValidateSampleRate(sr); err != nil {
+		return 0, 0, err
+	}
+	return br, sr, nil
+}
+
+func getOptionalInt(s, name string) (int, error) {
+	st := strings.TrimSpace(s)
+	if st == "" {
+		return 0, nil
 	}
-	resAll := make(map[string]bool)
-	resAll[resVoice] = true
-	for _, s := range all {
-		s = strings.TrimSpace(s)
-		if s != "" {
-			resAll[s] = true
-		}
+	res, err := strconv.Atoi(st)
+	if err != nil || res <= 0 {
+		return 0, errors.Errorf("wrong %s value %s.", name, s)
 	}
-	return resVoice, resAll, nil
+	return res, nil
 }
 
-func (c *TTSConfigutaror) getVoice(voice string) (string, error) {
+func (c *TTSConfigutaror) getVoice(voice string, speed float64) (string, error) {
 	if voice == "" {
-		return c.defaultVoice, nil
+		voice = c.voices.Default()
+	}
+	v, ok := c.voices.Get(voice)
+	if !ok {
+		return "", errors.Errorf("unknown voice '%s'", voice)
+	}
+	if err := v.checkSpeed(speed); err != nil {
+		return "", err
+	}
+	return voice, nil
+}
+
+// CheckVoices checks the voices used inside the text, speed 0 skips the speed check
+func (c *TTSConfigutaror) CheckVoices(voices []string, speed float64) error {
+	for _, v := range voices {
+		if _, err := c.getVoice(v, speed); err != nil {
+			return errors.Wrap(err, "wrong text voice")
+		}
+	}
+	return nil
+}
+
+func voiceNames(voices []*Voice) []string {
+	res := make([]string, len(voices))
+	for i, v := range voices {
+		res[i] = v.Name
+	}
+	return res
+}
+
+const (
+	metadataFormPrefix = "metadata."
+	maxMetadataKeys    = 20
+	maxMetadataValue   = 1000
+)
+
+var metadataKey = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,29}$`)
+
+// getMetadata validates the values for the joiner's metadata templates, empty values are dropped
+func getMetadata(in map[string]string) (map[string]string, error) {
+	if len(in) > maxMetadataKeys {
+		return nil, errors.Errorf("too many metadata values, max %d", maxMetadataKeys)
+	}
+	var res map[string]string
+	for k, v := range in {
+		if !metadataKey.MatchString(k) {
+			return nil, errors.Errorf("wrong metadata key '%s'", k)
+		}
+		v = strings.TrimSpace(v)
+		if utf8.RuneCountInString(v) > maxMetadataValue {
+			return nil, errors.Errorf("too long metadata value '%s', max %d", k, maxMetadataValue)
+		}
+		if v == "" {
+			continue
+		}
+		if res == nil {
+			res = map[string]string{}
+		}
+		res[k] = v
+	}
+	return res, nil
+}
+
+const (
+	splitByChapter = "chapter"
+	minMaxDuration = time.Minute
+)
+
+// getResultSplit validates the max duration of a result file and the split mode
+func getResultSplit(maxDuration, splitBy string) (time.Duration, string, error) {
+	var res time.Duration
+	if st := strings.TrimSpace(maxDuration); st != "" {
+		var err error
+		res, err = time.ParseDuration(st)
+		if err != nil || res < minMaxDuration {
+			return 0, "", errors.Errorf("wrong maxDuration '%s', expected at least %s", maxDuration, minMaxDuration)
+		}
+	}
+	by := strings.ToLower(strings.TrimSpace(splitBy))
+	if by != "" && by != splitByChapter {
+		return 0, "", errors.Errorf("wrong splitBy '%s', expected '%s'", splitBy, splitByChapter)
+	}
+	return res, by, nil
+}
+
+func getCallbackURL(s string) (string, error) {
+	st := strings.TrimSpace(s)
+	if st == "" {
+		return "", nil
+	}
+	u, err := url.Parse(st)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.Errorf("wrong callbackURL '%s'", s)
+	}
+	if utils.IsInternalHost(u.Hostname()) {
+		return "", errors.Errorf("wrong callbackURL host '%s'", u.Hostname())
+	}
+	return st, nil
+}
+
+// getPriority validates the priority against the range from the header
+// or the range configured for the caller
+func (c *TTSConfigutaror) getPriority(vs, rangeHeader, requestID string) (int, error) {
+	pr, err := c.getPriorityRange(rangeHeader, requestID)
+	if err != nil {
+		return 0, err
+	}
+	if strings.TrimSpace(vs) == "" {
+		return pr.clamp(c.defaultPriority), nil
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(vs))
+	if err != nil {
+		return 0, errors.Errorf("wrong priority value %s.", vs)
+	}
+	if v < pr.from || v > pr.to {
+		return 0, errors.Errorf("priority value (%d) must be in [%d,%d].", v, pr.from, pr.to)
+	}
+	return v, nil
+}
+
+func (c *TTSConfigutaror) getPriorityRange(rangeHeader, requestID string) (priorityRange, error) {
+	if strings.TrimSpace(rangeHeader) != "" {
+		return parsePriorityRange(rangeHeader)
+	}
+	if pr, ok := c.priorityRanges[requestScope(requestID)]; ok {
+		return pr, nil
+	}
+	return c.priorityRanges[anyCaller], nil
+}
+
+func parsePriorityRange(s string) (priorityRange, error) {
+	strs := strings.Split(strings.TrimSpace(s), "-")
+	if len(strs) != 2 {
+		return priorityRange{}, errors.Errorf("wrong priority range '%s', expected 'from-to'", s)
+	}
+	from, err := strconv.Atoi(strings.TrimSpace(strs[0]))
+	if err != nil {
+		return priorityRange{}, errors.Errorf("wrong priority range '%s'", s)
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(strs[1]))
+	if err != nil || from < 0 || to < from {
+		return priorityRange{}, errors.Errorf("wrong priority range '%s'", s)
+	}
+	return priorityRange{from: from, to: to}, nil
+}
+
+func (pr priorityRange) clamp(v int) int {
+	if v < pr.from {
+		return pr.from
 	}
-	if c.availableVoices[voice] {
-		return voice, nil
+	if v > pr.to {
+		return pr.to
 	}
-	return "", errors.Errorf("unknown voice '%s'", voice)
+	return v
 }
 
 func getHeader(r *http.Request, key string) string {
@@ -2,6 +2,7 @@ package upload
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -76,8 +77,11 @@ func Test_getOutputAudioFormat(t *testing.T) {
 	}{
 		{name: "mp3", args: "mp3", want: "mp3", wantErr: false},
 		{name: "m4a", args: "m4a", want: "m4a", wantErr: false},
+		{name: "wav", args: "wav", want: "wav", wantErr: false},
+		{name: "ogg", args: "ogg", want: "ogg", wantErr: false},
+		{name: "flac", args: "flac", want: "flac", wantErr: false},
 		{name: "Empty", args: "", want: "", wantErr: false},
-		{name: "Err", args: "wav", want: "", wantErr: true},
+		{name: "Err", args: "aac", want: "", wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -92,3 +96,153 @@ func Test_getOutputAudioFormat(t *testing.T) {
 		})
 	}
 }
+
+func Test_getCallbackURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{name: "Empty", args: " ", want: "", wantErr: false},
+		{name: "http", args: "http://host/cb", want: "http://host/cb", wantErr: false},
+		{name: "https", args: " https://host:8080/cb?a=1 ", want: "https://host:8080/cb?a=1", wantErr: false},
+		{name: "No scheme", args: "host/cb", want: "", wantErr: true},
+		{name: "Wrong scheme", args: "ftp://host/cb", want: "", wantErr: true},
+		{name: "No host", args: "http:///cb", want: "", wantErr: true},
+		{name: "Localhost", args: "http://localhost:8080/cb", want: "", wantErr: true},
+		{name: "Loopback", args: "http://127.0.0.1/cb", want: "", wantErr: true},
+		{name: "Loopback IPv6", args: "http://[::1]:80/cb", want: "", wantErr: true},
+		{name: "Private", args: "https://10.1.2.3/cb", want: "", wantErr: true},
+		{name: "Link-local", args: "http://169.254.169.254/latest", want: "", wantErr: true},
+		{name: "Public IP", args: "http://8.8.8.8/cb", want: "http://8.8.8.8/cb", wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getCallbackURL(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getCallbackURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("getCallbackURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getResultSplit(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxDuration string
+		splitBy     string
+		want        time.Duration
+		wantBy      string
+		wantErr     bool
+	}{
+		{name: "Empty", maxDuration: " ", want: 0, wantBy: ""},
+		{name: "Duration", maxDuration: " 1h30m ", want: 90 * time.Minute},
+		{name: "Chapter", splitBy: " Chapter ", wantBy: "chapter"},
+		{name: "Both", maxDuration: "2h", splitBy: "chapter", want: 2 * time.Hour, wantBy: "chapter"},
+		{name: "Short", maxDuration: "30s", wantErr: true},
+		{name: "Wrong duration", maxDuration: "1", wantErr: true},
+		{name: "Wrong by", splitBy: "size", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotBy, err := getResultSplit(tt.maxDuration, tt.splitBy)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantBy, gotBy)
+		})
+	}
+}
+
+func TestTTSConfigutaror_getPriority(t *testing.T) {
+	c, _ := NewTTSConfigurator("mp3", "astra", []string{"astra"})
+	assert.Nil(t, c.InitPriority(300, []string{"srv:0-10", "*:100-1000"}))
+	tests := []struct {
+		name      string
+		v         string
+		header    string
+		requestID string
+		want      int
+		wantErr   bool
+	}{
+		{name: "Default", v: "", want: 300, wantErr: false},
+		{name: "Value", v: "200", want: 200, wantErr: false},
+		{name: "Too small", v: "10", wantErr: true},
+		{name: "Too big", v: "1001", wantErr: true},
+		{name: "Wrong", v: "a", wantErr: true},
+		{name: "Caller", v: "5", requestID: "srv:111", want: 5, wantErr: false},
+		{name: "Caller default clamped", v: "", requestID: "srv:111", want: 10, wantErr: false},
+		{name: "Caller fail", v: "50", requestID: "srv:111", wantErr: true},
+		{name: "Unknown caller", v: "500", requestID: "olia:111", want: 500, wantErr: false},
+		{name: "Caller scope", v: "500", requestID: "srv:key:111", want: 500, wantErr: false},
+		{name: "Header", v: "50", header: "50-60", requestID: "srv:111", want: 50, wantErr: false},
+		{name: "Header fail", v: "5", header: "50-60", wantErr: true},
+		{name: "Header wrong", v: "50", header: "50", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.getPriority(tt.v, tt.header, tt.requestID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getPriority() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("getPriority() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTTSConfigutaror_InitPriority_Fail(t *testing.T) {
+	c, _ := NewTTSConfigurator("mp3", "astra", []string{"astra"})
+	assert.NotNil(t, c.InitPriority(-1, nil))
+	assert.NotNil(t, c.InitPriority(300, []string{"0-10"}))
+	assert.NotNil(t, c.InitPriority(300, []string{"srv:10-0"}))
+	assert.NotNil(t, c.InitPriority(300, []string{"srv:-1-10"}))
+	assert.NotNil(t, c.InitPriority(300, []string{"srv:a-10"}))
+}
+
+func Test_parsePriorityRange(t *testing.T) {
+	got, err := parsePriorityRange(" 10 - 20 ")
+	assert.Nil(t, err)
+	assert.Equal(t, priorityRange{from: 10, to: 20}, got)
+	_, err = parsePriorityRange("10")
+	assert.NotNil(t, err)
+}
+
+func Test_getEncoding(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		bitrate    string
+		sampleRate string
+		wantB      int
+		wantS      int
+		wantErr    bool
+	}{
+		{name: "Empty", format: "mp3", wantErr: false},
+		{name: "mp3", format: "mp3", bitrate: "64", sampleRate: "22050", wantB: 64, wantS: 22050, wantErr: false},
+		{name: "wav", format: "wav", sampleRate: "8000", wantS: 8000, wantErr: false},
+		{name: "ogg", format: "ogg", bitrate: "32", sampleRate: "16000", wantB: 32, wantS: 16000, wantErr: false},
+		{name: "Lossless bitrate", format: "flac", bitrate: "64", wantErr: true},
+		{name: "Wrong bitrate", format: "mp3", bitrate: "a", wantErr: true},
+		{name: "Negative bitrate", format: "mp3", bitrate: "-1", wantErr: true},
+		{name: "Wrong sample rate", format: "ogg", sampleRate: "44100", wantErr: true},
+		{name: "Wrong format", format: "", bitrate: "64", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotB, gotS, err := getEncoding(tt.format, tt.bitrate, tt.sampleRate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getEncoding() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.wantB, gotB)
+			assert.Equal(t, tt.wantS, gotS)
+		})
+	}
+}
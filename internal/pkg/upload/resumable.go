@@ -0,0 +1,258 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/airenas/async-api/pkg/api"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// FileLoader loads saved files
+type FileLoader interface {
+	Load(name string) (api.FileRead, error)
+}
+
+// FileRemover deletes saved files
+type FileRemover interface {
+	Remove(name string) error
+}
+
+// UploadSessionStore keeps resumable upload state
+type UploadSessionStore interface {
+	Create(data *persistence.UploadSession) error
+	Get(id string) (*persistence.UploadSession, error)
+	// AddChunk registers the chunk file if the session has received exactly from bytes
+	AddChunk(id string, from, to int64, file string) (bool, error)
+	Finish(id string) (bool, error)
+	// Reopen allows to finalize the session again if the job was not started
+	Reopen(id string) error
+}
+
+const (
+	headerUploadOffset = "Upload-Offset"
+	maxChunkSize       = 20 * 1024 * 1024
+)
+
+type resumableInput struct {
+	Input
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// resumableInit creates new upload session, no job is started until finalize is called
+func resumableInit(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("resumable init method")()
+
+		var input resumableInput
+		if err := decodeJSON(c, &input); err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(input.FileName))
+		if !checkFileExtension(ext) {
+			return echo.NewHTTPError(http.StatusBadRequest, "wrong file type: "+ext)
+		}
+		if input.Size < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "wrong size")
+		}
+		inData, err := data.Configurator.Configure(c.Request(), &input.Input)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := checkLexicon(c, data, inData); err != nil {
+			return err
+		}
+		if err := checkAssets(c, data, inData); err != nil {
+			return err
+		}
+
+		id := uuid.New().String()
+		err = data.SessionStore.Create(&persistence.UploadSession{ID: id, Ext: ext, Size: input.Size, Request: inData})
+		if err != nil {
+			goapp.Log.Error(err)
+			return errors.Wrap(err, "can not save upload session")
+		}
+		c.Response().Header().Set(headerUploadOffset, "0")
+		return c.JSON(http.StatusOK, result{ID: id})
+	}
+}
+
+// resumableOffset returns the number of bytes received so far
+func resumableOffset(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		us, err := getUploadSession(c, data)
+		if err != nil {
+			return err
+		}
+		c.Response().Header().Set(headerUploadOffset, strconv.FormatInt(us.Received, 10))
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// resumableAppend saves next chunk, the chunk's offset must match already received bytes
+func resumableAppend(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("resumable append method")()
+
+		us, err := getUploadSession(c, data)
+		if err != nil {
+			return err
+		}
+		offset, err := strconv.ParseInt(c.Request().Header.Get(headerUploadOffset), 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "wrong "+headerUploadOffset+" header")
+		}
+		if us.Finished {
+			return echo.NewHTTPError(http.StatusConflict, "upload finished")
+		}
+		if offset != us.Received {
+			c.Response().Header().Set(headerUploadOffset, strconv.FormatInt(us.Received, 10))
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("wrong offset, expected %d", us.Received))
+		}
+		chunk, err := io.ReadAll(io.LimitReader(c.Request().Body, maxChunkSize+1))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "can't read chunk")
+		}
+		if len(chunk) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "empty chunk")
+		}
+		if len(chunk) > maxChunkSize {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "chunk too large")
+		}
+		to := offset + int64(len(chunk))
+		if us.Size > 0 && to > us.Size {
+			return echo.NewHTTPError(http.StatusBadRequest, "chunk exceeds declared size")
+		}
+		if us.Ext == textExt {
+			// early check, the whole text is checked on finalize
+			if err := data.Configurator.CheckVoices(splitter.ChunkVoices(string(chunk)), 0); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+		// the name is unique, a concurrent request at the same offset can't overwrite the registered chunk
+		name := chunkName(us.ID, offset)
+		err = data.Saver.Save(name, bytes.NewReader(chunk))
+		if err != nil {
+			goapp.Log.Error(err)
+			return errors.Wrap(err, "can not save chunk")
+		}
+		ok, err := data.SessionStore.AddChunk(us.ID, offset, to, name)
+		if err != nil {
+			goapp.Log.Error(err)
+			return errors.Wrap(err, "can not update upload session")
+		}
+		if !ok {
+			removeChunk(data, name)
+			return echo.NewHTTPError(http.StatusConflict, "concurrent upload")
+		}
+		c.Response().Header().Set(headerUploadOffset, strconv.FormatInt(to, 10))
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// resumableFinalize joins all chunks and queues the job
+func resumableFinalize(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("resumable finalize method")()
+
+		us, err := getUploadSession(c, data)
+		if err != nil {
+			return err
+		}
+		if us.Finished {
+			return echo.NewHTTPError(http.StatusConflict, "upload finished")
+		}
+		if us.Received == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "no data uploaded")
+		}
+		if us.Size > 0 && us.Received != us.Size {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("upload incomplete, received %d of %d", us.Received, us.Size))
+		}
+		// finishing locks the session, it is reopened if the job is not started
+		ok, err := data.SessionStore.Finish(us.ID)
+		if err != nil {
+			goapp.Log.Error(err)
+			return errors.Wrap(err, "can not finish upload session")
+		}
+		if !ok {
+			return echo.NewHTTPError(http.StatusConflict, "upload finished")
+		}
+		if err := joinChunks(data, us); err != nil {
+			reopen(data, us.ID)
+			return err
+		}
+		if err := startJob(c, data, us.Request, us.ID, us.ID+us.Ext); err != nil {
+			reopen(data, us.ID)
+			return err
+		}
+		removeChunks(data, us)
+		return nil
+	}
+}
+
+func reopen(data *Data, id string) {
+	if err := data.SessionStore.Reopen(id); err != nil {
+		goapp.Log.Error(errors.Wrapf(err, "can't reopen upload session %s", id))
+	}
+}
+
+// removeChunks deletes the joined chunks, the cleaner removes the leftovers anyway
+func removeChunks(data *Data, us *persistence.UploadSession) {
+	for _, ch := range us.Chunks {
+		removeChunk(data, ch)
+	}
+}
+
+func removeChunk(data *Data, name string) {
+	if err := data.Remover.Remove(name); err != nil {
+		goapp.Log.Warn(errors.Wrapf(err, "can't remove chunk"))
+	}
+}
+
+func joinChunks(data *Data, us *persistence.UploadSession) error {
+	var readers []io.Reader
+	for _, ch := range us.Chunks {
+		f, err := data.Loader.Load(ch)
+		if err != nil {
+			goapp.Log.Error(err)
+			return errors.Wrap(err, "can not load chunk")
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	return saveFile(data, us.Request, us.ID, us.Ext, io.MultiReader(readers...))
+}
+
+func getUploadSession(c echo.Context, data *Data) (*persistence.UploadSession, error) {
+	id := c.Param("id")
+	if id == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "no ID")
+	}
+	res, err := data.SessionStore.Get(id)
+	if err != nil {
+		goapp.Log.Error(err)
+		return nil, errors.Wrap(err, "can not load upload session")
+	}
+	if res == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "no upload by ID")
+	}
+	return res, nil
+}
+
+// chunkName returns a unique chunk file name, it starts with the session ID for the cleaner
+func chunkName(id string, offset int64) string {
+	return fmt.Sprintf("%s.chunk.%015d.%s", id, offset, uuid.New().String())
+}
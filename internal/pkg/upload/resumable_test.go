@@ -0,0 +1,256 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/airenas/async-api/pkg/api"
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/petergtz/pegomock/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ResumableInit(t *testing.T) {
+	initTest(t)
+	req := newResumableRequest(http.MethodPost, "/resumable", `{"fileName":"a.txt","size":10,"voice":"vyt"}`)
+	resp := testCode(t, req, http.StatusOK)
+	bytes, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(bytes), `"id":"`)
+	assert.Equal(t, "0", resp.Header().Get(headerUploadOffset))
+	us := sessMock.VerifyWasCalledOnce().Create(pegomock.Any[*persistence.UploadSession]()).GetCapturedArguments()
+	assert.Equal(t, ".txt", us.Ext)
+	assert.Equal(t, int64(10), us.Size)
+	assert.Equal(t, "vyt", us.Request.Voice)
+	senderMock.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func Test_ResumableInit_Fail(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{name: "JSON", body: `olia`, code: http.StatusBadRequest},
+		{name: "Ext", body: `{"fileName":"a.wav"}`, code: http.StatusBadRequest},
+		{name: "Size", body: `{"fileName":"a.txt","size":-1}`, code: http.StatusBadRequest},
+		{name: "Voice", body: `{"fileName":"a.txt","voice":"aaa"}`, code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			testCode(t, newResumableRequest(http.MethodPost, "/resumable", tt.body), tt.code)
+		})
+	}
+}
+
+func Test_ResumableInit_FailStore(t *testing.T) {
+	initTest(t)
+	pegomock.When(sessMock.Create(pegomock.Any[*persistence.UploadSession]())).ThenReturn(errors.New("err"))
+	testCode(t, newResumableRequest(http.MethodPost, "/resumable", `{"fileName":"a.txt"}`), http.StatusInternalServerError)
+}
+
+func Test_ResumableOffset(t *testing.T) {
+	initTest(t)
+	pegomock.When(sessMock.Get("id1")).ThenReturn(&persistence.UploadSession{ID: "id1", Received: 15}, nil)
+	resp := testCode(t, newResumableRequest(http.MethodHead, "/resumable/id1", ""), http.StatusOK)
+	assert.Equal(t, "15", resp.Header().Get(headerUploadOffset))
+}
+
+func Test_ResumableOffset_NotFound(t *testing.T) {
+	initTest(t)
+	testCode(t, newResumableRequest(http.MethodHead, "/resumable/id1", ""), http.StatusNotFound)
+}
+
+func Test_ResumableAppend(t *testing.T) {
+	initTest(t)
+	pegomock.When(sessMock.Get("id1")).ThenReturn(&persistence.UploadSession{ID: "id1", Received: 5}, nil)
+	pegomock.When(sessMock.AddChunk(pegomock.Eq("id1"), pegomock.Eq(int64(5)), pegomock.Eq(int64(9)),
+		pegomock.Any[string]())).ThenReturn(true, nil)
+	req := newResumableRequest(http.MethodPatch, "/resumable/id1", "olia")
+	req.Header.Set(headerUploadOffset, "5")
+	resp := testCode(t, req, http.StatusNoContent)
+	assert.Equal(t, "9", resp.Header().Get(headerUploadOffset))
+	name, _ := saverMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[io.Reader]()).GetCapturedArguments()
+	assert.True(t, strings.HasPrefix(name, "id1.chunk.000000000000005."), name)
+	_, _, _, added := sessMock.VerifyWasCalledOnce().AddChunk(pegomock.Any[string](), pegomock.Any[int64](),
+		pegomock.Any[int64](), pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, name, added)
+	remMock.VerifyWasCalled(pegomock.Never()).Remove(pegomock.Any[string]())
+}
+
+func Test_ResumableAppend_Concurrent(t *testing.T) {
+	initTest(t)
+	pegomock.When(sessMock.Get("id1")).ThenReturn(&persistence.UploadSession{ID: "id1"}, nil)
+	pegomock.When(sessMock.AddChunk(pegomock.Any[string](), pegomock.Any[int64](), pegomock.Any[int64](),
+		pegomock.Any[string]())).ThenReturn(false, nil)
+	req := newResumableRequest(http.MethodPatch, "/resumable/id1", "olia")
+	req.Header.Set(headerUploadOffset, "0")
+	testCode(t, req, http.StatusConflict)
+	name, _ := saverMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[io.Reader]()).GetCapturedArguments()
+	assert.Equal(t, name, remMock.VerifyWasCalledOnce().Remove(pegomock.Any[string]()).GetCapturedArguments())
+}
+
+func Test_ChunkName(t *testing.T) {
+	assert.NotEqual(t, chunkName("id1", 5), chunkName("id1", 5))
+}
+
+func Test_ResumableAppend_Voice(t *testing.T) {
+	initTest(t)
+	pegomock.When(sessMock.Get("id1")).ThenReturn(&persistence.UploadSession{ID: "id1", Ext: ".txt"}, nil)
+	pegomock.When(sessMock.AddChunk(pegomock.Any[string](), pegomock.Any[int64](), pegomock.Any[int64](),
+		pegomock.Any[string]())).ThenReturn(true, nil)
+	req := newResumableRequest(http.MethodPatch, "/resumable/id1", "olia [voice=vyt]")
+	req.Header.Set(headerUploadOffset, "0")
+	testCode(t, req, http.StatusNoContent)
+	saverMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_ResumableAppend_Voice_Fail(t *testing.T) {
+	initTest(t)
+	pegomock.When(sessMock.Get("id1")).ThenReturn(&persistence.UploadSession{ID: "id1", Ext: ".txt"}, nil)
+	req := newResumableRequest(http.MethodPatch, "/resumable/id1", "olia [voice=olia]")
+	req.Header.Set(headerUploadOffset, "0")
+	resp := testCode(t, req, http.StatusBadRequest)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(b), "unknown voice 'olia'")
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_ResumableAppend_Fail(t *testing.T) {
+	tests := []struct {
+		name   string
+		us     *persistence.UploadSession
+		offset string
+		body   string
+		added  bool
+		code   int
+	}{
+		{name: "No session", us: nil, offset: "0", body: "olia", code: http.StatusNotFound},
+		{name: "No offset", us: &persistence.UploadSession{ID: "id1"}, offset: "", body: "olia", code: http.StatusBadRequest},
+		{name: "Wrong offset", us: &persistence.UploadSession{ID: "id1", Received: 2}, offset: "0", body: "olia",
+			code: http.StatusConflict},
+		{name: "Finished", us: &persistence.UploadSession{ID: "id1", Finished: true}, offset: "0", body: "olia",
+			code: http.StatusConflict},
+		{name: "Empty", us: &persistence.UploadSession{ID: "id1"}, offset: "0", body: "", code: http.StatusBadRequest},
+		{name: "Size", us: &persistence.UploadSession{ID: "id1", Size: 3}, offset: "0", body: "olia",
+			code: http.StatusBadRequest},
+		{name: "Concurrent", us: &persistence.UploadSession{ID: "id1"}, offset: "0", body: "olia", added: false,
+			code: http.StatusConflict},
+		{name: "Voice", us: &persistence.UploadSession{ID: "id1", Ext: ".txt"}, offset: "0", body: "olia [voice=olia]",
+			code: http.StatusBadRequest},
+		{name: "SSML voice", us: &persistence.UploadSession{ID: "id1", Ext: ".txt"}, offset: "0",
+			body: `<voice name="olia">`, code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(sessMock.Get("id1")).ThenReturn(tt.us, nil)
+			pegomock.When(sessMock.AddChunk(pegomock.Any[string](), pegomock.Any[int64](),
+				pegomock.Any[int64](), pegomock.Any[string]())).ThenReturn(tt.added, nil)
+			req := newResumableRequest(http.MethodPatch, "/resumable/id1", tt.body)
+			req.Header.Set(headerUploadOffset, tt.offset)
+			testCode(t, req, tt.code)
+		})
+	}
+}
+
+func Test_ResumableFinalize(t *testing.T) {
+	initTest(t)
+	pegomock.When(sessMock.Get("id1")).ThenReturn(&persistence.UploadSession{ID: "id1", Ext: ".txt", Received: 9,
+		Size: 9, Chunks: []string{"c0", "c5"}, Request: &persistence.ReqData{Voice: "vyt"}}, nil)
+	pegomock.When(sessMock.Finish("id1")).ThenReturn(true, nil)
+	pegomock.When(loaderMock.Load("c0")).ThenReturn(newTestFile(t, "olia "), nil)
+	pegomock.When(loaderMock.Load("c5")).ThenReturn(newTestFile(t, "olia"), nil)
+	var saved string
+	pegomock.When(saverMock.Save(pegomock.Any[string](), pegomock.Any[io.Reader]())).Then(func(p []pegomock.Param) pegomock.ReturnValues {
+		b, _ := io.ReadAll(p[1].(io.Reader))
+		saved = string(b)
+		return []pegomock.ReturnValue{nil}
+	})
+
+	resp := testCode(t, newResumableRequest(http.MethodPost, "/resumable/id1/finalize", ""), http.StatusOK)
+	bytes, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(bytes), `"id":"id1"`)
+	assert.Equal(t, "olia olia", saved)
+	rd := rSaverMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.ReqData]()).GetCapturedArguments()
+	assert.Equal(t, "id1", rd.ID)
+	assert.Equal(t, "id1.txt", rd.Filename)
+	senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+	assert.Equal(t, []string{"c0", "c5"},
+		remMock.VerifyWasCalled(pegomock.Times(2)).Remove(pegomock.Any[string]()).GetAllCapturedArguments())
+	sessMock.VerifyWasCalled(pegomock.Never()).Reopen(pegomock.Any[string]())
+}
+
+func Test_ResumableFinalize_Reopens(t *testing.T) {
+	tests := []struct {
+		name    string
+		loadErr error
+		sendErr error
+		code    int
+	}{
+		{name: "Join fail", loadErr: errors.New("olia"), code: http.StatusInternalServerError},
+		{name: "Send fail", sendErr: errors.New("olia"), code: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(sessMock.Get("id1")).ThenReturn(&persistence.UploadSession{ID: "id1", Ext: ".txt", Received: 4,
+				Chunks: []string{"c0"}, Request: &persistence.ReqData{Voice: "vyt"}}, nil)
+			pegomock.When(sessMock.Finish("id1")).ThenReturn(true, nil)
+			pegomock.When(loaderMock.Load("c0")).ThenReturn(newTestFile(t, "olia"), tt.loadErr)
+			pegomock.When(senderMock.Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+				pegomock.Any[string]())).ThenReturn(tt.sendErr)
+			testCode(t, newResumableRequest(http.MethodPost, "/resumable/id1/finalize", ""), tt.code)
+			sessMock.VerifyWasCalledOnce().Reopen("id1")
+			remMock.VerifyWasCalled(pegomock.Never()).Remove(pegomock.Any[string]())
+		})
+	}
+}
+
+func Test_ResumableFinalize_Fail(t *testing.T) {
+	tests := []struct {
+		name     string
+		us       *persistence.UploadSession
+		finished bool
+		code     int
+	}{
+		{name: "No session", us: nil, code: http.StatusNotFound},
+		{name: "Finished", us: &persistence.UploadSession{ID: "id1", Received: 2, Finished: true}, code: http.StatusConflict},
+		{name: "Empty", us: &persistence.UploadSession{ID: "id1"}, code: http.StatusBadRequest},
+		{name: "Incomplete", us: &persistence.UploadSession{ID: "id1", Received: 2, Size: 3}, code: http.StatusBadRequest},
+		{name: "Concurrent", us: &persistence.UploadSession{ID: "id1", Ext: ".txt", Received: 2,
+			Request: &persistence.ReqData{}}, finished: false, code: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			pegomock.When(sessMock.Get("id1")).ThenReturn(tt.us, nil)
+			pegomock.When(sessMock.Finish("id1")).ThenReturn(tt.finished, nil)
+			testCode(t, newResumableRequest(http.MethodPost, "/resumable/id1/finalize", ""), tt.code)
+			senderMock.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](), pegomock.Any[string]())
+		})
+	}
+}
+
+func newResumableRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(requestIDHEader, "m:testRequestID")
+	return req
+}
+
+func newTestFile(t *testing.T, data string) api.FileRead {
+	t.Helper()
+	fn := filepath.Join(t.TempDir(), "chunk")
+	require.Nil(t, os.WriteFile(fn, []byte(data), 0600))
+	res, err := os.Open(fn)
+	require.Nil(t, err)
+	return res
+}
@@ -0,0 +1,154 @@
+package upload
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/status"
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// StatusStore reads the job status and resets the error for the retry
+type StatusStore interface {
+	Get(id string) (*persistence.Status, error)
+	ResetError(id, st string) (bool, error)
+}
+
+// RequestStore reads the saved request
+type RequestStore interface {
+	Get(id string) (*persistence.ReqData, error)
+	SetRequestID(id, requestID string) error
+}
+
+// ProgressProvider returns the chars count not synthesized yet
+type ProgressProvider interface {
+	Remaining(id, outputFormat string) (int, error)
+}
+
+type retryResult struct {
+	ID    string `json:"id"`
+	Stage string `json:"stage"`
+	Chars int    `json:"chars"`
+}
+
+// retryQueues maps the failed stage to the queue to restart the job,
+// a job failed before the pipeline is restarted at the first stage
+var retryQueues = map[status.Status]string{status.Split: messages.Split,
+	status.Synthesize: messages.Synthesize, status.Join: messages.Join}
+
+func retry(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("retry method")()
+
+		id := c.Param("id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "no ID")
+		}
+		// the usage of the old request ID is already restored on the failure
+		requestID := extractRequestID(c.Request().Header)
+		if requestID == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "no "+requestIDHEader+" header")
+		}
+		st, err := data.StatusStore.Get(id)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't get status")
+		}
+		if st == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "no job by ID")
+		}
+		if st.Error == "" {
+			return echo.NewHTTPError(http.StatusConflict, "job is not failed")
+		}
+		queue, ok := retryQueue(st.Status, data.FirstStage)
+		if !ok {
+			return echo.NewHTTPError(http.StatusConflict, "can't retry job at '"+st.Status+"'")
+		}
+		inData, err := data.RequestStore.Get(id)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't get request")
+		}
+		if inData == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "no request by ID")
+		}
+		chars, err := remainingChars(data, st.Status, queue, inData)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't calculate remaining work")
+		}
+		ok, err = data.StatusStore.ResetError(id, st.Status)
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't reset status")
+		}
+		if !ok {
+			return echo.NewHTTPError(http.StatusConflict, "job status changed")
+		}
+		if err := data.RequestStore.SetRequestID(id, requestID); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't save request")
+		}
+		inData.RequestID = requestID
+		if err := data.MsgSender.Send(newMessage(inData), queue, ""); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "can't send msg")
+		}
+		goapp.Log.Infof("Restarted %s at %s, chars %d", id, queue, chars)
+		return c.JSON(http.StatusOK, retryResult{ID: id, Stage: st.Status, Chars: chars})
+	}
+}
+
+// retryQueue returns the queue to restart the job failed at the stage,
+// a custom pipeline stage is restarted at its own queue
+func retryQueue(stage, firstStage string) (string, bool) {
+	if status.From(stage) == status.Uploaded {
+		return messages.StageQueue(firstStage), true
+	}
+	if st := status.From(stage); st > 0 {
+		res, ok := retryQueues[st]
+		return res, ok
+	}
+	if strings.TrimSpace(stage) == "" {
+		return "", false
+	}
+	return messages.StageQueue(stage), true
+}
+
+// remainingChars returns the chars to synthesize: all text if the job failed before the pipeline
+// or at the split, not synthesized parts if the synthesis failed
+func remainingChars(data *Data, stage, queue string, inData *persistence.ReqData) (int, error) {
+	switch {
+	case status.From(stage) == status.Uploaded || queue == messages.Split:
+		text, err := loadText(data, inData.ID)
+		if err != nil {
+			return 0, err
+		}
+		est, err := data.Estimator.Estimate(text, inData.Voice, inData.Speed)
+		if err != nil {
+			return 0, errors.Wrap(err, "can't estimate")
+		}
+		return est.Chars, nil
+	case queue == messages.Synthesize:
+		return data.Progress.Remaining(inData.ID, inData.OutputFormat)
+	}
+	return 0, nil
+}
+
+func loadText(data *Data, id string) (string, error) {
+	f, err := data.Loader.Load(id + textExt)
+	if err != nil {
+		return "", errors.Wrap(err, "can't load text")
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return "", errors.Wrap(err, "can't read text")
+	}
+	return string(b), nil
+}
@@ -0,0 +1,162 @@
+package upload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/petergtz/pegomock/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func initRetryTest(t *testing.T, st string) {
+	t.Helper()
+	initTest(t)
+	pegomock.When(stMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Status{ID: "1", Status: st, Error: "err"}, nil)
+	pegomock.When(stMock.ResetError(pegomock.Any[string](), pegomock.Any[string]())).ThenReturn(true, nil)
+	pegomock.When(reqMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.ReqData{ID: "1", Voice: "astra",
+		OutputFormat: "mp3", RequestID: "m:old", Speed: 1}, nil)
+}
+
+func newRetryRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/retry/1", nil)
+	req.Header.Set(requestIDHEader, "m:new")
+	return req
+}
+
+func Test_Retry_Synthesize(t *testing.T) {
+	initRetryTest(t, "Synthesize")
+	pegomock.When(progMock.Remaining(pegomock.Any[string](), pegomock.Any[string]())).ThenReturn(150, nil)
+	req := newRetryRequest()
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, `{"id":"1","stage":"Synthesize","chars":150}`+"\n", resp.Body.String())
+	id, rID := reqMock.VerifyWasCalledOnce().SetRequestID(pegomock.Any[string](), pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "1", id)
+	assert.Equal(t, "m:new", rID)
+	_, st := stMock.VerifyWasCalledOnce().ResetError(pegomock.Any[string](), pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, "Synthesize", st)
+	msg, queue, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Synthesize, queue)
+	assert.Equal(t, "1", msg.(*messages.TTSMessage).ID)
+	assert.Equal(t, "m:new", msg.(*messages.TTSMessage).RequestID)
+	assert.Equal(t, "astra", msg.(*messages.TTSMessage).Voice)
+}
+
+func Test_Retry_Split(t *testing.T) {
+	initRetryTest(t, "UPLOADED")
+	pegomock.When(loaderMock.Load(pegomock.Any[string]())).ThenReturn(newTestFile(t, "olia olia"), nil)
+	pegomock.When(estMock.Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]())).
+		ThenReturn(&splitter.Estimation{Chars: 9, Parts: 1}, nil)
+	req := newRetryRequest()
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, `{"id":"1","stage":"UPLOADED","chars":9}`+"\n", resp.Body.String())
+	assert.Equal(t, "1.txt", loaderMock.VerifyWasCalledOnce().Load(pegomock.Any[string]()).GetCapturedArguments())
+	msg, queue, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Split, queue)
+	assert.Equal(t, "m:new", msg.(*messages.TTSMessage).RequestID)
+}
+
+func Test_Retry_FirstStage(t *testing.T) {
+	initRetryTest(t, "UPLOADED")
+	tData.FirstStage = "Clean"
+	pegomock.When(loaderMock.Load(pegomock.Any[string]())).ThenReturn(newTestFile(t, "olia olia"), nil)
+	pegomock.When(estMock.Estimate(pegomock.Any[string](), pegomock.Any[string](), pegomock.Any[float64]())).
+		ThenReturn(&splitter.Estimation{Chars: 9, Parts: 1}, nil)
+	resp := testCode(t, newRetryRequest(), http.StatusOK)
+	assert.Equal(t, `{"id":"1","stage":"UPLOADED","chars":9}`+"\n", resp.Body.String())
+	_, queue, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.StageQueue("Clean"), queue)
+}
+
+func Test_Retry_NoRequestID(t *testing.T) {
+	initRetryTest(t, "Synthesize")
+	req := newRetryRequest()
+	req.Header.Del(requestIDHEader)
+	testCode(t, req, http.StatusBadRequest)
+	stMock.VerifyWasCalled(pegomock.Never()).ResetError(pegomock.Any[string](), pegomock.Any[string]())
+	senderMock.VerifyWasCalled(pegomock.Never()).Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]())
+}
+
+func Test_Retry_Changed_KeepsRequestID(t *testing.T) {
+	initRetryTest(t, "Synthesize")
+	pegomock.When(stMock.ResetError(pegomock.Any[string](), pegomock.Any[string]())).ThenReturn(false, nil)
+	testCode(t, newRetryRequest(), http.StatusConflict)
+	reqMock.VerifyWasCalled(pegomock.Never()).SetRequestID(pegomock.Any[string](), pegomock.Any[string]())
+}
+
+func Test_Retry_Join(t *testing.T) {
+	initRetryTest(t, "Join")
+	req := newRetryRequest()
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, `{"id":"1","stage":"Join","chars":0}`+"\n", resp.Body.String())
+	_, queue, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.Join, queue)
+}
+
+func Test_Retry_CustomStage(t *testing.T) {
+	initRetryTest(t, "Loudness")
+	req := newRetryRequest()
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, `{"id":"1","stage":"Loudness","chars":0}`+"\n", resp.Body.String())
+	_, queue, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, messages.StageQueue("Loudness"), queue)
+}
+
+func Test_Retry_Fail(t *testing.T) {
+	tests := []struct {
+		name     string
+		prepare  func()
+		wantCode int
+	}{
+		{name: "No status", prepare: func() {
+			pegomock.When(stMock.Get(pegomock.Any[string]())).ThenReturn(nil, nil)
+		}, wantCode: http.StatusNotFound},
+		{name: "Status fail", prepare: func() {
+			pegomock.When(stMock.Get(pegomock.Any[string]())).ThenReturn(nil, errors.New("err"))
+		}, wantCode: http.StatusInternalServerError},
+		{name: "Not failed", prepare: func() {
+			pegomock.When(stMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Status{Status: "Synthesize"}, nil)
+		}, wantCode: http.StatusConflict},
+		{name: "Completed", prepare: func() {
+			pegomock.When(stMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Status{Status: "COMPLETED", Error: "err"}, nil)
+		}, wantCode: http.StatusConflict},
+		{name: "No stage", prepare: func() {
+			pegomock.When(stMock.Get(pegomock.Any[string]())).ThenReturn(&persistence.Status{Status: "", Error: "err"}, nil)
+		}, wantCode: http.StatusConflict},
+		{name: "No request", prepare: func() {
+			pegomock.When(reqMock.Get(pegomock.Any[string]())).ThenReturn(nil, nil)
+		}, wantCode: http.StatusNotFound},
+		{name: "Progress fail", prepare: func() {
+			pegomock.When(progMock.Remaining(pegomock.Any[string](), pegomock.Any[string]())).ThenReturn(0, errors.New("err"))
+		}, wantCode: http.StatusInternalServerError},
+		{name: "Changed", prepare: func() {
+			pegomock.When(stMock.ResetError(pegomock.Any[string](), pegomock.Any[string]())).ThenReturn(false, nil)
+		}, wantCode: http.StatusConflict},
+		{name: "Request ID fail", prepare: func() {
+			pegomock.When(reqMock.SetRequestID(pegomock.Any[string](), pegomock.Any[string]())).ThenReturn(errors.New("err"))
+		}, wantCode: http.StatusInternalServerError},
+		{name: "Send fail", prepare: func() {
+			pegomock.When(senderMock.Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+				pegomock.Any[string]())).ThenReturn(errors.New("err"))
+		}, wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initRetryTest(t, "Synthesize")
+			tt.prepare()
+			req := newRetryRequest()
+			testCode(t, req, tt.wantCode)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package upload
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"log"
 	"mime/multipart"
@@ -15,8 +17,11 @@ import (
 	"github.com/pkg/errors"
 
 	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/extract"
 	"github.com/airenas/big-tts/internal/pkg/messages"
 	"github.com/airenas/big-tts/internal/pkg/persistence"
+	"github.com/airenas/big-tts/internal/pkg/splitter"
+	"github.com/airenas/big-tts/internal/pkg/textnorm"
 
 	"github.com/airenas/go-app/pkg/goapp"
 
@@ -40,6 +45,11 @@ type RequestSaver interface {
 	Save(req *persistence.ReqData) error
 }
 
+// TextExtractor converts uploaded documents to text
+type TextExtractor interface {
+	Extract(ext string, data []byte) ([]byte, error)
+}
+
 // Data keeps data required for service work
 type Data struct {
 	Port         int
@@ -47,6 +57,29 @@ type Data struct {
 	Saver        FileSaver
 	ReqSaver     RequestSaver
 	MsgSender    MsgSender
+	Extractor    TextExtractor
+	Loader       FileLoader
+	Remover      FileRemover
+	SessionStore UploadSessionStore
+	Estimator    TextEstimator
+	SpeechRate   *SpeechRate
+	Canceler     JobCanceler
+	StatusStore  StatusStore
+	RequestStore RequestStore
+	Progress     ProgressProvider
+	// FirstStage is the first pipeline stage, a job failed before it is restarted there
+	FirstStage string
+	// KeyStore, KeyWindow and KeyFromContent configure idempotent uploads,
+	// disabled if KeyWindow is not positive
+	KeyStore       IdempotencyStore
+	KeyWindow      time.Duration
+	KeyFromContent bool
+	LexiconStore   LexiconStore
+	AssetStore     AssetStore
+	// AssetSaver saves the audio assets files by the asset ID
+	AssetSaver FileSaver
+	// AssetRemover deletes the audio assets files by the asset ID
+	AssetRemover FileRemover
 }
 
 const requestIDHEader = "x-doorman-requestid"
@@ -87,6 +120,54 @@ func validate(data *Data) error {
 	if data.MsgSender == nil {
 		return errors.New("no msg sender")
 	}
+	if data.Extractor == nil {
+		return errors.New("no text extractor")
+	}
+	if data.Loader == nil {
+		return errors.New("no file loader")
+	}
+	if data.Remover == nil {
+		return errors.New("no file remover")
+	}
+	if data.SessionStore == nil {
+		return errors.New("no upload session store")
+	}
+	if data.Estimator == nil {
+		return errors.New("no text estimator")
+	}
+	if data.SpeechRate == nil {
+		return errors.New("no speech rate")
+	}
+	if data.Canceler == nil {
+		return errors.New("no job canceler")
+	}
+	if data.StatusStore == nil {
+		return errors.New("no status store")
+	}
+	if data.RequestStore == nil {
+		return errors.New("no request store")
+	}
+	if data.Progress == nil {
+		return errors.New("no progress provider")
+	}
+	if data.FirstStage == "" {
+		return errors.New("no first pipeline stage")
+	}
+	if data.KeyWindow > 0 && data.KeyStore == nil {
+		return errors.New("no idempotency key store")
+	}
+	if data.LexiconStore == nil {
+		return errors.New("no lexicon store")
+	}
+	if data.AssetStore == nil {
+		return errors.New("no asset store")
+	}
+	if data.AssetSaver == nil {
+		return errors.New("no asset saver")
+	}
+	if data.AssetRemover == nil {
+		return errors.New("no asset remover")
+	}
 	return nil
 }
 
@@ -102,6 +183,22 @@ func initRoutes(data *Data) *echo.Echo {
 	promMdlw.Use(e)
 
 	e.POST("/upload", upload(data))
+	e.POST("/synthesize", synthesizeText(data))
+	e.POST("/estimate", estimate(data))
+	e.POST("/resumable", resumableInit(data))
+	e.HEAD("/resumable/:id", resumableOffset(data))
+	e.PATCH("/resumable/:id", resumableAppend(data))
+	e.POST("/resumable/:id/finalize", resumableFinalize(data))
+	e.POST("/cancel/:id", cancel(data))
+	e.POST("/retry/:id", retry(data))
+	e.GET("/voices", voices(data))
+	e.POST("/lexicon", lexiconCreate(data))
+	e.GET("/lexicon/:id", lexiconGet(data))
+	e.PUT("/lexicon/:id", lexiconUpdate(data))
+	e.DELETE("/lexicon/:id", lexiconDelete(data))
+	e.POST("/asset", assetCreate(data))
+	e.GET("/asset/:id", assetGet(data))
+	e.DELETE("/asset/:id", assetDelete(data))
 	e.GET("/live", live(data))
 
 	goapp.Log.Info("Routes:")
@@ -125,11 +222,17 @@ func upload(data *Data) func(echo.Context) error {
 	return func(c echo.Context) error {
 		defer goapp.Estimate("upload method")()
 
-		inData, err := getInputData(c, data.Configurator)
+		inData, err := data.Configurator.Configure(c.Request(), getFormInput(c))
 		if err != nil {
 			goapp.Log.Error(err)
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
+		if err := checkLexicon(c, data, inData); err != nil {
+			return err
+		}
+		if err := checkAssets(c, data, inData); err != nil {
+			return err
+		}
 
 		form, err := c.MultipartForm()
 		if err != nil {
@@ -152,50 +255,143 @@ func upload(data *Data) func(echo.Context) error {
 			return echo.NewHTTPError(http.StatusBadRequest, "wrong file type: "+ext)
 		}
 
-		id := uuid.New().String()
-		fileName := id + ext
-
 		src, err := file.Open()
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, "can't read file")
 		}
 		defer src.Close()
+		b, err := io.ReadAll(src)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "can't read file")
+		}
 
-		err = data.Saver.Save(fileName, src)
+		id := uuid.New().String()
+		oldID, key, err := reserveKey(c, data, id, b, inData)
 		if err != nil {
-			goapp.Log.Error(err)
-			return errors.Wrap(err, "can not save file")
+			return err
+		}
+		if oldID != "" {
+			return c.JSON(http.StatusOK, result{ID: oldID})
 		}
 
-		requestID := extractRequestID(c.Request().Header)
-		goapp.Log.Infof("RequestID=%s", goapp.Sanitize(requestID))
+		err = saveFile(data, inData, id, ext, bytes.NewReader(b))
+		if err == nil {
+			err = startJob(c, data, inData, id, id+ext)
+		}
+		if err != nil {
+			releaseKey(data, key, id)
+		}
+		return err
+	}
+}
+
+func synthesizeText(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("synthesize method")()
 
-		inData.ID = id
-		inData.Filename = fileName
-		inData.RequestID = requestID
-		err = data.ReqSaver.Save(inData)
+		var input Input
+		if err := decodeJSON(c, &input); err != nil {
+			return err
+		}
+		if strings.TrimSpace(input.Text) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "no text")
+		}
+		inData, err := data.Configurator.Configure(c.Request(), &input)
 		if err != nil {
 			goapp.Log.Error(err)
-			return errors.Wrap(err, "can not save request")
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := checkLexicon(c, data, inData); err != nil {
+			return err
+		}
+		if err := checkAssets(c, data, inData); err != nil {
+			return err
 		}
 
-		msg := &messages.TTSMessage{
-			QueueMessage: amessages.QueueMessage{ID: id},
-			Voice:        inData.Voice,
-			SaveRequest:  inData.SaveRequest,
-			Speed:        inData.Speed,
-			OutputFormat: inData.OutputFormat,
-			SaveTags:     inData.SaveTags,
-			RequestID:    requestID,
+		txt := textnorm.Normalize([]byte(input.Text))
+		if err := validateSSML(txt); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err := checkTextVoices(data, txt, inData.Speed); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
-		err = data.MsgSender.Send(msg, messages.Upload, "")
+
+		id := uuid.New().String()
+		oldID, key, err := reserveKey(c, data, id, txt, inData)
+		if err != nil {
+			return err
+		}
+		if oldID != "" {
+			return c.JSON(http.StatusOK, result{ID: oldID})
+		}
+
+		fileName := id + textExt
+		err = data.Saver.Save(fileName, bytes.NewReader(txt))
 		if err != nil {
 			goapp.Log.Error(err)
-			return errors.Wrap(err, "can not send msg")
+			releaseKey(data, key, id)
+			return errors.Wrap(err, "can not save file")
+		}
+		if err = startJob(c, data, inData, id, fileName); err != nil {
+			releaseKey(data, key, id)
 		}
+		return err
+	}
+}
 
-		res := result{ID: id}
-		return c.JSON(http.StatusOK, res)
+func decodeJSON(c echo.Context, res interface{}) error {
+	if err := json.NewDecoder(c.Request().Body).Decode(res); err != nil {
+		goapp.Log.Error(err)
+		return echo.NewHTTPError(http.StatusBadRequest, "can't decode input")
+	}
+	return nil
+}
+
+// startJob saves the request and sends it for processing
+func startJob(c echo.Context, data *Data, inData *persistence.ReqData, id, fileName string) error {
+	requestID := extractRequestID(c.Request().Header)
+	goapp.Log.Infof("RequestID=%s", goapp.Sanitize(requestID))
+
+	inData.ID = id
+	inData.Filename = fileName
+	inData.RequestID = requestID
+	err := data.ReqSaver.Save(inData)
+	if err != nil {
+		goapp.Log.Error(err)
+		return errors.Wrap(err, "can not save request")
+	}
+
+	err = data.MsgSender.Send(newMessage(inData), messages.Upload, "")
+	if err != nil {
+		goapp.Log.Error(err)
+		return errors.Wrap(err, "can not send msg")
+	}
+
+	res := result{ID: id}
+	return c.JSON(http.StatusOK, res)
+}
+
+func newMessage(inData *persistence.ReqData) *messages.TTSMessage {
+	return &messages.TTSMessage{
+		QueueMessage: amessages.QueueMessage{ID: inData.ID},
+		Voice:        inData.Voice,
+		SaveRequest:  inData.SaveRequest,
+		Speed:        inData.Speed,
+		OutputFormat: inData.OutputFormat,
+		SaveTags:     inData.SaveTags,
+		RequestID:    inData.RequestID,
+		Priority:     inData.Priority,
+		Bitrate:      inData.Bitrate,
+		SampleRate:   inData.SampleRate,
+		Lexicon:      inData.Lexicon,
+		Intro:        inData.Intro,
+		Outro:        inData.Outro,
+		Music:        inData.Music,
+		Metadata:     inData.Metadata,
+		MaxDuration:  inData.MaxDuration,
+		SplitBy:      inData.SplitBy,
 	}
 }
 
@@ -203,8 +399,13 @@ func extractRequestID(header http.Header) string {
 	return header.Get(requestIDHEader)
 }
 
-func getInputData(c echo.Context, cfg *TTSConfigutaror) (*persistence.ReqData, error) {
-	return cfg.Configure(c)
+// requestScope returns the caller's scope: the request ID without its last part.
+// The scope identifies the caller for priority ranges, idempotency keys, lexicons and assets
+func requestScope(requestID string) string {
+	if i := strings.LastIndex(requestID, ":"); i > 0 {
+		return requestID[:i]
+	}
+	return requestID
 }
 
 func cleanFiles(f *multipart.Form) {
@@ -215,6 +416,84 @@ func cleanFiles(f *multipart.Form) {
 	}
 }
 
+const textExt = ".txt"
+
 func checkFileExtension(ext string) bool {
-	return ext == ".txt"
+	return ext == textExt || extract.Supports(ext)
+}
+
+// saveFile saves the text as UTF-8. Documents are converted to text
+// and the original file is kept next to the extracted one
+func saveFile(data *Data, inData *persistence.ReqData, id, ext string, src io.Reader) error {
+	b, err := io.ReadAll(src)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "can't read file")
+	}
+	txt, err := toText(data, ext, b)
+	if err == nil {
+		err = checkTextVoices(data, txt, inData.Speed)
+	}
+	if err != nil {
+		goapp.Log.Error(err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if ext != textExt {
+		err = data.Saver.Save(id+ext, bytes.NewReader(b))
+		if err != nil {
+			goapp.Log.Error(err)
+			return errors.Wrap(err, "can not save file")
+		}
+	}
+	err = data.Saver.Save(id+textExt, bytes.NewReader(txt))
+	if err != nil {
+		goapp.Log.Error(err)
+		return errors.Wrap(err, "can not save text")
+	}
+	return nil
+}
+
+func toText(data *Data, ext string, b []byte) ([]byte, error) {
+	var err error
+	if ext == textExt || extract.IsText(ext) {
+		b, err = textnorm.ToUTF8(b)
+		if err != nil {
+			return nil, errors.Wrap(err, "wrong text encoding")
+		}
+	}
+	if ext != textExt {
+		b, err = data.Extractor.Extract(ext, b)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't extract text")
+		}
+	}
+	b = textnorm.Normalize(b)
+	if err := validateSSML(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// checkTextVoices checks the voices switched to inside the text: [voice=name] or <voice name="name">.
+// The request speed is not checked for SSML voices as SSML sets the rate by <prosody>
+func checkTextVoices(data *Data, txt []byte, speed float64) error {
+	voices, err := splitter.TextVoices(string(txt))
+	if err != nil {
+		return err
+	}
+	if splitter.IsSSML(string(txt)) {
+		speed = 0
+	}
+	return data.Configurator.CheckVoices(voices, speed)
+}
+
+// validateSSML checks the SSML before the job is created, plain text is not checked
+func validateSSML(b []byte) error {
+	txt := string(b)
+	if !splitter.IsSSML(txt) {
+		return nil
+	}
+	if err := splitter.ValidateSSML(txt); err != nil {
+		return errors.Wrap(err, "wrong SSML")
+	}
+	return nil
 }
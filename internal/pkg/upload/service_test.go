@@ -8,8 +8,10 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	amessages "github.com/airenas/async-api/pkg/messages"
+	"github.com/airenas/big-tts/internal/pkg/messages"
 	"github.com/airenas/big-tts/internal/pkg/persistence"
 	"github.com/airenas/big-tts/internal/pkg/test/mocks"
 	"github.com/labstack/echo/v4"
@@ -22,6 +24,20 @@ var (
 	saverMock  *mocks.MockFileSaver
 	rSaverMock *mocks.MockRequestSaver
 	senderMock *mocks.MockMsgSender
+	extrMock   *mocks.MockTextExtractor
+	loaderMock *mocks.MockFileReader
+	sessMock   *mocks.MockUploadSessionStore
+	remMock    *mocks.MockFileRemover
+	estMock    *mocks.MockTextEstimator
+	keyMock    *mocks.MockIdempotencyStore
+	cancelMock *mocks.MockJobCanceler
+	stMock     *mocks.MockStatusStore
+	reqMock    *mocks.MockRequestStore
+	progMock   *mocks.MockProgressProvider
+	lexMock    *mocks.MockLexiconStore
+	assetMock  *mocks.MockAssetStore
+	aSaverMock *mocks.MockFileSaver
+	aRemMock   *mocks.MockFileRemover
 	tData      *Data
 	tEcho      *echo.Echo
 	tResp      *httptest.ResponseRecorder
@@ -32,10 +48,40 @@ func initTest(t *testing.T) {
 	saverMock = mocks.NewMockFileSaver()
 	rSaverMock = mocks.NewMockRequestSaver()
 	senderMock = mocks.NewMockMsgSender()
+	extrMock = mocks.NewMockTextExtractor()
+	loaderMock = mocks.NewMockFileReader()
+	sessMock = mocks.NewMockUploadSessionStore()
+	remMock = mocks.NewMockFileRemover()
+	estMock = mocks.NewMockTextEstimator()
+	keyMock = mocks.NewMockIdempotencyStore()
+	cancelMock = mocks.NewMockJobCanceler()
+	stMock = mocks.NewMockStatusStore()
+	reqMock = mocks.NewMockRequestStore()
+	progMock = mocks.NewMockProgressProvider()
+	lexMock = mocks.NewMockLexiconStore()
+	assetMock = mocks.NewMockAssetStore()
+	aSaverMock = mocks.NewMockFileSaver()
+	aRemMock = mocks.NewMockFileRemover()
 	tData = &Data{}
 	tData.Saver = saverMock
 	tData.ReqSaver = rSaverMock
 	tData.MsgSender = senderMock
+	tData.Extractor = extrMock
+	tData.Loader = loaderMock
+	tData.SessionStore = sessMock
+	tData.Remover = remMock
+	tData.Estimator = estMock
+	tData.SpeechRate, _ = NewSpeechRate(10, []string{"vyt:20"})
+	tData.KeyStore = keyMock
+	tData.Canceler = cancelMock
+	tData.StatusStore = stMock
+	tData.RequestStore = reqMock
+	tData.Progress = progMock
+	tData.FirstStage = "Split"
+	tData.LexiconStore = lexMock
+	tData.AssetStore = assetMock
+	tData.AssetSaver = aSaverMock
+	tData.AssetRemover = aRemMock
 	tData.Configurator, _ = NewTTSConfigurator("mp3", "astra", []string{"vyt"})
 	tEcho = initRoutes(tData)
 	tResp = httptest.NewRecorder()
@@ -75,12 +121,18 @@ func Test_400(t *testing.T) {
 		{name: "File", args: args{file: "file.txt", filep: "file1"}, wantCode: http.StatusBadRequest},
 		{name: "FileName", args: args{file: "file", filep: "file"}, wantCode: http.StatusBadRequest},
 		{name: "FileName", args: args{file: "file.wav", filep: "file"}, wantCode: http.StatusBadRequest},
+		{name: "Docx", args: args{file: "file.docx", filep: "file"}, wantCode: http.StatusOK},
+		{name: "Epub", args: args{file: "file.EPUB", filep: "file"}, wantCode: http.StatusOK},
 		{name: "Voice", args: args{file: "file.txt", filep: "file", params: [][2]string{{"voice", "astra"}}},
 			wantCode: http.StatusOK},
 		{name: "Voice", args: args{file: "file.txt", filep: "file", params: [][2]string{{"voice", "astra1"}}},
 			wantCode: http.StatusBadRequest},
-		{name: "Voice", args: args{file: "file.txt", filep: "file", params: [][2]string{{"outputFormat", "wav"}}},
+		{name: "Voice", args: args{file: "file.txt", filep: "file", params: [][2]string{{"outputFormat", "aac"}}},
 			wantCode: http.StatusBadRequest},
+		{name: "Format", args: args{file: "file.txt", filep: "file", params: [][2]string{{"outputFormat", "wav"},
+			{"sampleRate", "8000"}}}, wantCode: http.StatusOK},
+		{name: "Format", args: args{file: "file.txt", filep: "file", params: [][2]string{{"outputFormat", "ogg"},
+			{"bitrate", "1000"}}}, wantCode: http.StatusBadRequest},
 		{name: "Voice", args: args{file: "file.txt", filep: "file", params: [][2]string{{"speed", "aa"}}},
 			wantCode: http.StatusBadRequest},
 		{name: "Voice", args: args{file: "file.txt", filep: "file", params: [][2]string{{"outputFormat", "mp3"}}},
@@ -103,6 +155,85 @@ func Test_Fails_Saver(t *testing.T) {
 	testCode(t, req, http.StatusInternalServerError)
 }
 
+func Test_Extracts(t *testing.T) {
+	initTest(t)
+	req := newTestRequest("file", "file.docx", "olia", nil)
+	pegomock.When(extrMock.Extract(pegomock.Any[string](), pegomock.Any[[]byte]())).ThenReturn([]byte("<speak>olia</speak>"), nil)
+
+	testCode(t, req, http.StatusOK)
+	ext, data := extrMock.VerifyWasCalledOnce().Extract(pegomock.Any[string](), pegomock.Any[[]byte]()).GetCapturedArguments()
+	assert.Equal(t, ".docx", ext)
+	assert.Equal(t, "olia", string(data))
+	names, _ := saverMock.VerifyWasCalled(pegomock.Times(2)).Save(pegomock.Any[string](), pegomock.Any[io.Reader]()).GetAllCapturedArguments()
+	assert.True(t, strings.HasSuffix(names[0], ".docx"))
+	assert.True(t, strings.HasSuffix(names[1], ".txt"))
+}
+
+func Test_Fails_Extractor(t *testing.T) {
+	initTest(t)
+	req := newTestRequest("file", "file.docx", "olia", nil)
+	pegomock.When(extrMock.Extract(pegomock.Any[string](), pegomock.Any[[]byte]())).ThenReturn(nil, errors.New("err"))
+
+	testCode(t, req, http.StatusBadRequest)
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_Normalizes(t *testing.T) {
+	initTest(t)
+	req := newTestRequest("file", "file.txt", "\xe0o\r\nlia", nil)
+	var saved string
+	pegomock.When(saverMock.Save(pegomock.Any[string](), pegomock.Any[io.Reader]())).Then(func(params []pegomock.Param) pegomock.ReturnValues {
+		b, _ := io.ReadAll(params[1].(io.Reader))
+		saved = string(b)
+		return []pegomock.ReturnValue{nil}
+	})
+
+	testCode(t, req, http.StatusOK)
+	assert.Equal(t, "ąo\nlia", saved)
+}
+
+func Test_Fails_Encoding(t *testing.T) {
+	initTest(t)
+	req := newTestRequest("file", "file.txt", "ol\x00ia", nil)
+
+	testCode(t, req, http.StatusBadRequest)
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_Fails_SSML(t *testing.T) {
+	initTest(t)
+	req := newTestRequest("file", "file.txt", "<speak>olia\n<foo>olia</foo></speak>", nil)
+
+	resp := testCode(t, req, http.StatusBadRequest)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(b), "line 2, column 1, tag")
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_Fails_TextVoice(t *testing.T) {
+	initTest(t)
+	req := newTestRequest("file", "file.txt", "olia [voice=vyt]olia [voice=olia]olia", nil)
+
+	resp := testCode(t, req, http.StatusBadRequest)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(b), "unknown voice 'olia'")
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_TextVoices(t *testing.T) {
+	initTest(t)
+	testCode(t, newTestRequest("file", "file.txt", "olia [voice=vyt]olia [voice=]olia", nil), http.StatusOK)
+}
+
+func Test_TextVoices_Speed(t *testing.T) {
+	initTest(t)
+	tData.Configurator, _ = NewTTSConfigurator("mp3", "astra", []string{"vyt:lt:male:0.5-1.2"})
+	testCode(t, newTestJSONRequest(`{"text":"olia [voice=vyt]olia","speed":1.5}`), http.StatusBadRequest)
+	tResp = httptest.NewRecorder()
+	testCode(t, newTestJSONRequest(`{"text":"<speak>olia<voice name=\"vyt\">olia</voice></speak>","speed":1.5}`),
+		http.StatusOK)
+}
+
 func Test_Fails_ReqSaver(t *testing.T) {
 	initTest(t)
 	req := newTestRequest("file", "file.txt", "olia", nil)
@@ -119,6 +250,79 @@ func Test_Fails_MsgSender(t *testing.T) {
 	testCode(t, req, http.StatusInternalServerError)
 }
 
+func Test_Synthesize(t *testing.T) {
+	initTest(t)
+	req := newTestJSONRequest(`{"text":"olia","voice":"vyt","speed":1.5,"outputFormat":"m4a","saveRequest":true,"callbackURL":"http://cb/1","priority":10,
+		"bitrate":96,"sampleRate":44100,"maxDuration":"1h","splitBy":"chapter"}`)
+	resp := testCode(t, req, http.StatusOK)
+	bytes, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(bytes), `"id":"`)
+	name, rd := saverMock.VerifyWasCalledOnce().Save(pegomock.Any[string](), pegomock.Any[io.Reader]()).GetCapturedArguments()
+	assert.True(t, strings.HasSuffix(name, ".txt"))
+	txt, _ := io.ReadAll(rd)
+	assert.Equal(t, "olia", string(txt))
+	rd2 := rSaverMock.VerifyWasCalledOnce().Save(pegomock.Any[*persistence.ReqData]()).GetCapturedArguments()
+	assert.Equal(t, "vyt", rd2.Voice)
+	assert.Equal(t, "m4a", rd2.OutputFormat)
+	assert.InDelta(t, 1.5, rd2.Speed, 0.0001)
+	assert.True(t, rd2.SaveRequest)
+	assert.Equal(t, "m:testRequestID", rd2.RequestID)
+	assert.Equal(t, "http://cb/1", rd2.CallbackURL)
+	assert.Equal(t, 10, rd2.Priority)
+	assert.Equal(t, 96, rd2.Bitrate)
+	assert.Equal(t, 44100, rd2.SampleRate)
+	assert.Equal(t, time.Hour, rd2.MaxDuration)
+	assert.Equal(t, "chapter", rd2.SplitBy)
+	msg, _, _ := senderMock.VerifyWasCalledOnce().Send(pegomock.Any[amessages.Message](), pegomock.Any[string](),
+		pegomock.Any[string]()).GetCapturedArguments()
+	assert.Equal(t, 10, msg.(*messages.TTSMessage).Priority)
+	assert.Equal(t, time.Hour, msg.(*messages.TTSMessage).MaxDuration)
+	assert.Equal(t, "chapter", msg.(*messages.TTSMessage).SplitBy)
+}
+
+func Test_Synthesize_400(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "No JSON", body: `olia`},
+		{name: "No text", body: `{"voice":"vyt"}`},
+		{name: "Empty text", body: `{"text":"  "}`},
+		{name: "Voice", body: `{"text":"olia","voice":"vyt1"}`},
+		{name: "Speed", body: `{"text":"olia","speed":3}`},
+		{name: "Format", body: `{"text":"olia","outputFormat":"aaa"}`},
+		{name: "Priority", body: `{"text":"olia","priority":-1}`},
+		{name: "Bitrate", body: `{"text":"olia","outputFormat":"flac","bitrate":64}`},
+		{name: "Sample rate", body: `{"text":"olia","outputFormat":"wav","sampleRate":1000}`},
+		{name: "Text voice", body: `{"text":"olia [voice=vyt1]olia"}`},
+		{name: "Max duration", body: `{"text":"olia","maxDuration":"10s"}`},
+		{name: "Split by", body: `{"text":"olia","splitBy":"page"}`},
+		{name: "SSML voice", body: `{"text":"<speak>olia<voice name=\"vyt1\">olia</voice></speak>"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			testCode(t, newTestJSONRequest(tt.body), http.StatusBadRequest)
+		})
+	}
+}
+
+func Test_Synthesize_Fails_SSML(t *testing.T) {
+	initTest(t)
+	req := newTestJSONRequest(`{"text":"<speak>olia<break time=\"1x\"/></speak>"}`)
+
+	resp := testCode(t, req, http.StatusBadRequest)
+	b, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(b), "line 1, column 12")
+	saverMock.VerifyWasCalled(pegomock.Never()).Save(pegomock.Any[string](), pegomock.Any[io.Reader]())
+}
+
+func Test_Synthesize_Fails_Saver(t *testing.T) {
+	initTest(t)
+	pegomock.When(saverMock.Save(pegomock.Any[string](), pegomock.Any[io.Reader]())).ThenReturn(errors.New("err"))
+	testCode(t, newTestJSONRequest(`{"text":"olia"}`), http.StatusInternalServerError)
+}
+
 func Test_Live(t *testing.T) {
 	initTest(t)
 	req := httptest.NewRequest(http.MethodGet, "/live", nil)
@@ -140,20 +344,28 @@ func Test_validate(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		{name: "OK", args: args{data: &Data{Saver: mocks.NewMockFileSaver(),
-			Configurator: &TTSConfigutaror{}, ReqSaver: mocks.NewMockRequestSaver(),
-			MsgSender: mocks.NewMockMsgSender()}}, wantErr: false},
-		{name: "Fail Saver", args: args{data: &Data{
-			Configurator: &TTSConfigutaror{}, ReqSaver: mocks.NewMockRequestSaver(),
-			MsgSender: mocks.NewMockMsgSender()}}, wantErr: true},
-		{name: "Fail Configurator", args: args{data: &Data{Saver: mocks.NewMockFileSaver(),
-			ReqSaver:  mocks.NewMockRequestSaver(),
-			MsgSender: mocks.NewMockMsgSender()}}, wantErr: true},
-		{name: "Fail ReqSaver", args: args{data: &Data{Saver: mocks.NewMockFileSaver(),
-			Configurator: &TTSConfigutaror{},
-			MsgSender:    mocks.NewMockMsgSender()}}, wantErr: true},
-		{name: "Fail Sender", args: args{data: &Data{Saver: mocks.NewMockFileSaver(),
-			Configurator: &TTSConfigutaror{}, ReqSaver: mocks.NewMockRequestSaver()}}, wantErr: true},
+		{name: "OK", args: args{data: newTestData(func(d *Data) {})}, wantErr: false},
+		{name: "Fail Saver", args: args{data: newTestData(func(d *Data) { d.Saver = nil })}, wantErr: true},
+		{name: "Fail Configurator", args: args{data: newTestData(func(d *Data) { d.Configurator = nil })}, wantErr: true},
+		{name: "Fail ReqSaver", args: args{data: newTestData(func(d *Data) { d.ReqSaver = nil })}, wantErr: true},
+		{name: "Fail Sender", args: args{data: newTestData(func(d *Data) { d.MsgSender = nil })}, wantErr: true},
+		{name: "Fail Extractor", args: args{data: newTestData(func(d *Data) { d.Extractor = nil })}, wantErr: true},
+		{name: "Fail Loader", args: args{data: newTestData(func(d *Data) { d.Loader = nil })}, wantErr: true},
+		{name: "Fail Remover", args: args{data: newTestData(func(d *Data) { d.Remover = nil })}, wantErr: true},
+		{name: "Fail SessionStore", args: args{data: newTestData(func(d *Data) { d.SessionStore = nil })}, wantErr: true},
+		{name: "Fail Estimator", args: args{data: newTestData(func(d *Data) { d.Estimator = nil })}, wantErr: true},
+		{name: "Fail SpeechRate", args: args{data: newTestData(func(d *Data) { d.SpeechRate = nil })}, wantErr: true},
+		{name: "Fail Canceler", args: args{data: newTestData(func(d *Data) { d.Canceler = nil })}, wantErr: true},
+		{name: "Fail StatusStore", args: args{data: newTestData(func(d *Data) { d.StatusStore = nil })}, wantErr: true},
+		{name: "Fail RequestStore", args: args{data: newTestData(func(d *Data) { d.RequestStore = nil })}, wantErr: true},
+		{name: "Fail Progress", args: args{data: newTestData(func(d *Data) { d.Progress = nil })}, wantErr: true},
+		{name: "Fail KeyStore", args: args{data: newTestData(func(d *Data) { d.KeyStore = nil; d.KeyWindow = time.Hour })}, wantErr: true},
+		{name: "No KeyStore", args: args{data: newTestData(func(d *Data) { d.KeyStore = nil })}, wantErr: false},
+		{name: "Fail LexiconStore", args: args{data: newTestData(func(d *Data) { d.LexiconStore = nil })}, wantErr: true},
+		{name: "Fail AssetStore", args: args{data: newTestData(func(d *Data) { d.AssetStore = nil })}, wantErr: true},
+		{name: "Fail FirstStage", args: args{data: newTestData(func(d *Data) { d.FirstStage = "" })}, wantErr: true},
+		{name: "Fail AssetSaver", args: args{data: newTestData(func(d *Data) { d.AssetSaver = nil })}, wantErr: true},
+		{name: "Fail AssetRemover", args: args{data: newTestData(func(d *Data) { d.AssetRemover = nil })}, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -164,6 +376,20 @@ func Test_validate(t *testing.T) {
 	}
 }
 
+func newTestData(f func(*Data)) *Data {
+	res := &Data{Saver: mocks.NewMockFileSaver(),
+		Configurator: &TTSConfigutaror{}, ReqSaver: mocks.NewMockRequestSaver(),
+		MsgSender: mocks.NewMockMsgSender(), Extractor: mocks.NewMockTextExtractor(),
+		Loader: mocks.NewMockFileReader(), Remover: mocks.NewMockFileRemover(), SessionStore: mocks.NewMockUploadSessionStore(),
+		Estimator: mocks.NewMockTextEstimator(), SpeechRate: &SpeechRate{}, Canceler: mocks.NewMockJobCanceler(),
+		StatusStore: mocks.NewMockStatusStore(), RequestStore: mocks.NewMockRequestStore(),
+		Progress: mocks.NewMockProgressProvider(), LexiconStore: mocks.NewMockLexiconStore(),
+		AssetStore: mocks.NewMockAssetStore(), AssetSaver: mocks.NewMockFileSaver(),
+		AssetRemover: mocks.NewMockFileRemover(), FirstStage: "Split"}
+	f(res)
+	return res
+}
+
 func newTestRequest(filep, file, bodyText string, params [][2]string) *http.Request {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -181,6 +407,13 @@ func newTestRequest(filep, file, bodyText string, params [][2]string) *http.Requ
 	return req
 }
 
+func newTestJSONRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/synthesize", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(requestIDHEader, "m:testRequestID")
+	return req
+}
+
 func Test_extractRequestID(t *testing.T) {
 	req := newTestRequest("file", "file.txt", "olia", nil)
 	assert.Equal(t, "m:testRequestID", extractRequestID(req.Header))
@@ -0,0 +1,244 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// Voice describes a synthesis voice
+type Voice struct {
+	Name     string  `json:"name"`
+	Language string  `json:"language,omitempty"`
+	Gender   string  `json:"gender,omitempty"`
+	MinSpeed float64 `json:"minSpeed,omitempty"`
+	MaxSpeed float64 `json:"maxSpeed,omitempty"`
+}
+
+// VoiceProvider loads the voices list, usually from the TTS backend
+type VoiceProvider interface {
+	GetVoices() ([]*Voice, error)
+}
+
+// VoiceCatalog keeps the available voices. The list can be replaced at runtime
+type VoiceCatalog struct {
+	def string
+
+	lock   sync.RWMutex
+	voices []*Voice
+	byName map[string]*Voice
+}
+
+// NewVoiceCatalog creates catalog from the default voice and config values
+// 'name[:language[:gender[:minSpeed-maxSpeed]]]'
+func NewVoiceCatalog(def string, voices []string) (*VoiceCatalog, error) {
+	res := &VoiceCatalog{def: strings.TrimSpace(def)}
+	if res.def == "" {
+		return nil, errors.New("no default voice")
+	}
+	var list []*Voice
+	for _, s := range voices {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		v, err := parseVoice(s)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	if !containsVoice(list, res.def) {
+		list = append([]*Voice{{Name: res.def}}, list...)
+	}
+	if err := res.Set(list); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Default returns the default voice name
+func (vc *VoiceCatalog) Default() string {
+	return vc.def
+}
+
+// Get returns voice by name
+func (vc *VoiceCatalog) Get(name string) (*Voice, bool) {
+	vc.lock.RLock()
+	defer vc.lock.RUnlock()
+	res, ok := vc.byName[name]
+	return res, ok
+}
+
+// List returns all voices
+func (vc *VoiceCatalog) List() []*Voice {
+	vc.lock.RLock()
+	defer vc.lock.RUnlock()
+	return vc.voices
+}
+
+// Set replaces the voices, the list must contain the default voice
+func (vc *VoiceCatalog) Set(voices []*Voice) error {
+	byName := make(map[string]*Voice, len(voices))
+	for _, v := range voices {
+		if v == nil || strings.TrimSpace(v.Name) == "" {
+			return errors.New("no voice name")
+		}
+		if _, ok := byName[v.Name]; ok {
+			return errors.Errorf("duplicate voice '%s'", v.Name)
+		}
+		byName[v.Name] = v
+	}
+	if _, ok := byName[vc.def]; !ok {
+		return errors.Errorf("no default voice '%s' in the list", vc.def)
+	}
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+	vc.voices, vc.byName = voices, byName
+	return nil
+}
+
+// Refresh loads voices from the provider
+func (vc *VoiceCatalog) Refresh(p VoiceProvider) error {
+	voices, err := p.GetVoices()
+	if err != nil {
+		return errors.Wrap(err, "can't load voices")
+	}
+	if err := vc.Set(voices); err != nil {
+		return errors.Wrap(err, "wrong voices")
+	}
+	goapp.Log.Infof("Loaded %d voices", len(voices))
+	return nil
+}
+
+// StartRefresh refreshes voices periodically until the context is canceled.
+// Failures are logged, the old list is kept
+func (vc *VoiceCatalog) StartRefresh(ctx context.Context, p VoiceProvider, every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := vc.Refresh(p); err != nil {
+					goapp.Log.Warn(err)
+				}
+			}
+		}
+	}()
+}
+
+func (v *Voice) checkSpeed(speed float64) error {
+	if speed == 0 || v.MinSpeed == 0 && v.MaxSpeed == 0 {
+		return nil
+	}
+	if speed < v.MinSpeed || (v.MaxSpeed > 0 && speed > v.MaxSpeed) {
+		return errors.Errorf("speed value (%.2f) must be in [%g,%g] for voice '%s'.", speed,
+			v.MinSpeed, v.MaxSpeed, v.Name)
+	}
+	return nil
+}
+
+func parseVoice(s string) (*Voice, error) {
+	strs := strings.Split(s, ":")
+	if len(strs) > 4 {
+		return nil, errors.Errorf("wrong voice '%s', expected 'name[:language[:gender[:minSpeed-maxSpeed]]]'", s)
+	}
+	res := &Voice{Name: strings.TrimSpace(strs[0])}
+	if res.Name == "" {
+		return nil, errors.Errorf("no voice name in '%s'", s)
+	}
+	if len(strs) > 1 {
+		res.Language = strings.TrimSpace(strs[1])
+	}
+	if len(strs) > 2 {
+		res.Gender = strings.TrimSpace(strs[2])
+	}
+	if len(strs) > 3 {
+		sp := strings.Split(strs[3], "-")
+		if len(sp) != 2 {
+			return nil, errors.Errorf("wrong voice speed range in '%s'", s)
+		}
+		var err error
+		res.MinSpeed, err = strconv.ParseFloat(strings.TrimSpace(sp[0]), 64)
+		if err != nil {
+			return nil, errors.Errorf("wrong voice speed range in '%s'", s)
+		}
+		res.MaxSpeed, err = strconv.ParseFloat(strings.TrimSpace(sp[1]), 64)
+		if err != nil || res.MinSpeed < 0 || res.MaxSpeed < res.MinSpeed {
+			return nil, errors.Errorf("wrong voice speed range in '%s'", s)
+		}
+	}
+	return res, nil
+}
+
+func containsVoice(voices []*Voice, name string) bool {
+	for _, v := range voices {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPVoiceProvider loads voices JSON array from the URL
+type HTTPVoiceProvider struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPVoiceProvider creates voices provider
+func NewHTTPVoiceProvider(url string, timeout time.Duration) (*HTTPVoiceProvider, error) {
+	if strings.TrimSpace(url) == "" {
+		return nil, errors.New("no voices URL")
+	}
+	if timeout <= 0 {
+		return nil, errors.Errorf("wrong timeout %s", timeout)
+	}
+	goapp.Log.Infof("Voices URL: %s", url)
+	return &HTTPVoiceProvider{url: url, httpClient: &http.Client{Timeout: timeout}}, nil
+}
+
+// GetVoices loads voices
+func (p *HTTPVoiceProvider) GetVoices() ([]*Voice, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't prepare request")
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't invoke voices service")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 10000))
+		return nil, errors.Errorf("voices service returned code %d", resp.StatusCode)
+	}
+	var res []*Voice
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, errors.Wrap(err, "can't decode voices")
+	}
+	return res, nil
+}
+
+type voicesResult struct {
+	Default string   `json:"default"`
+	Voices  []*Voice `json:"voices"`
+}
+
+func voices(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		vc := data.Configurator.Voices()
+		return c.JSON(http.StatusOK, voicesResult{Default: vc.Default(), Voices: vc.List()})
+	}
+}
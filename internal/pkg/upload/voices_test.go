@@ -0,0 +1,136 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testVoiceProvider struct {
+	voices []*Voice
+	err    error
+}
+
+func (p *testVoiceProvider) GetVoices() ([]*Voice, error) {
+	return p.voices, p.err
+}
+
+func TestNewVoiceCatalog(t *testing.T) {
+	got, err := NewVoiceCatalog("astra", []string{"vyt:lt:male:0.5-1.5", " ", "laimis"})
+	assert.Nil(t, err)
+	assert.Equal(t, "astra", got.Default())
+	assert.Equal(t, []*Voice{{Name: "astra"}, {Name: "vyt", Language: "lt", Gender: "male", MinSpeed: 0.5, MaxSpeed: 1.5},
+		{Name: "laimis"}}, got.List())
+	v, ok := got.Get("vyt")
+	assert.True(t, ok)
+	assert.Equal(t, "lt", v.Language)
+	_, ok = got.Get("olia")
+	assert.False(t, ok)
+}
+
+func TestNewVoiceCatalog_Fail(t *testing.T) {
+	tests := []struct {
+		name   string
+		def    string
+		voices []string
+	}{
+		{name: "No default", def: " ", voices: nil},
+		{name: "No name", def: "astra", voices: []string{":lt"}},
+		{name: "Too many", def: "astra", voices: []string{"a:lt:male:0.5-1:a"}},
+		{name: "Speed", def: "astra", voices: []string{"a:lt:male:0.5"}},
+		{name: "Speed wrong", def: "astra", voices: []string{"a:lt:male:a-1"}},
+		{name: "Speed order", def: "astra", voices: []string{"a:lt:male:2-1"}},
+		{name: "Duplicate", def: "astra", voices: []string{"a", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVoiceCatalog(tt.def, tt.voices)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestVoiceCatalog_Refresh(t *testing.T) {
+	got, _ := NewVoiceCatalog("astra", nil)
+	err := got.Refresh(&testVoiceProvider{voices: []*Voice{{Name: "astra"}, {Name: "vyt"}}})
+	assert.Nil(t, err)
+	_, ok := got.Get("vyt")
+	assert.True(t, ok)
+}
+
+func TestVoiceCatalog_Refresh_KeepsOld(t *testing.T) {
+	got, _ := NewVoiceCatalog("astra", []string{"vyt"})
+	err := got.Refresh(&testVoiceProvider{err: errors.New("olia")})
+	assert.NotNil(t, err)
+	err = got.Refresh(&testVoiceProvider{voices: []*Voice{{Name: "laimis"}}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, len(got.List()))
+	_, ok := got.Get("vyt")
+	assert.True(t, ok)
+}
+
+func TestVoiceCatalog_StartRefresh(t *testing.T) {
+	got, _ := NewVoiceCatalog("astra", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got.StartRefresh(ctx, &testVoiceProvider{voices: []*Voice{{Name: "astra"}, {Name: "vyt"}}}, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, ok := got.Get("vyt")
+		return ok
+	}, time.Second, time.Millisecond*5)
+}
+
+func TestVoice_checkSpeed(t *testing.T) {
+	v := &Voice{Name: "a", MinSpeed: 0.8, MaxSpeed: 1.2}
+	assert.Nil(t, v.checkSpeed(0))
+	assert.Nil(t, v.checkSpeed(1))
+	assert.NotNil(t, v.checkSpeed(0.5))
+	assert.NotNil(t, v.checkSpeed(1.5))
+	assert.Nil(t, (&Voice{Name: "a"}).checkSpeed(2))
+}
+
+func TestHTTPVoiceProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(rw).Encode([]*Voice{{Name: "astra", Language: "lt"}})
+	}))
+	defer srv.Close()
+	p, err := NewHTTPVoiceProvider(srv.URL, time.Second)
+	assert.Nil(t, err)
+	got, err := p.GetVoices()
+	assert.Nil(t, err)
+	assert.Equal(t, []*Voice{{Name: "astra", Language: "lt"}}, got)
+}
+
+func TestHTTPVoiceProvider_Fail(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	_, err := NewHTTPVoiceProvider("", time.Second)
+	assert.NotNil(t, err)
+	p, _ := NewHTTPVoiceProvider(srv.URL, time.Second)
+	_, err = p.GetVoices()
+	assert.NotNil(t, err)
+}
+
+func Test_Voices(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodGet, "/voices", nil)
+	resp := testCode(t, req, http.StatusOK)
+	assert.Equal(t, `{"default":"astra","voices":[{"name":"astra"},{"name":"vyt"}]}`+"\n", resp.Body.String())
+}
+
+func Test_Voices_AfterRefresh(t *testing.T) {
+	initTest(t)
+	err := tData.Configurator.Voices().Refresh(&testVoiceProvider{voices: []*Voice{{Name: "astra"}, {Name: "laimis"}}})
+	assert.Nil(t, err)
+	testCode(t, newTestJSONRequest(`{"text":"olia","voice":"vyt"}`), http.StatusBadRequest)
+	tResp = httptest.NewRecorder()
+	testCode(t, newTestJSONRequest(`{"text":"olia","voice":"laimis"}`), http.StatusOK)
+}
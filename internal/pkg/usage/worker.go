@@ -49,7 +49,7 @@ func (w *Worker) Do(ctx context.Context, msg *messages.TTSMessage) error {
 	if err != nil {
 		return errors.Wrapf(err, "wrong requestID format '%s'", msg.RequestID)
 	}
-	return w.invoke(service, rID, msg.Error)
+	return w.invoke(service, rID, msg.Error, msg.RestorePart)
 }
 
 func parse(s string) (string, string, error) {
@@ -62,10 +62,12 @@ func parse(s string) (string, string, error) {
 
 type request struct {
 	Error string `json:"error,omitempty"`
+	// Part of the usage to restore, all if empty
+	Part float64 `json:"part,omitempty"`
 }
 
-func (w *Worker) invoke(service, requestID, errorMsg string) error {
-	inp := request{Error: errorMsg}
+func (w *Worker) invoke(service, requestID, errorMsg string, part float64) error {
+	inp := request{Error: errorMsg, Part: part}
 	b, err := json.Marshal(inp)
 	if err != nil {
 		return err
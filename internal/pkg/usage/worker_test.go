@@ -35,6 +35,20 @@ func TestWorker_Do(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestWorker_Do_Part(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"error":"canceled","part":0.5}`, string(b))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	got, err := NewWorker(srv.URL)
+	assert.Nil(t, err)
+	err = got.Do(context.Background(), &messages.TTSMessage{QueueMessage: amessages.QueueMessage{ID: "id1", Error: "canceled"},
+		RequestID: "tt:m:rid", RestorePart: 0.5})
+	assert.Nil(t, err)
+}
+
 func TestWorker_Skip_NoRequest(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		assert.Fail(t, "unexpected call")
@@ -1,5 +1,10 @@
 package utils
 
+import (
+	"errors"
+	"fmt"
+)
+
 // ErrNonRestorableUsage indicates non restoreable usage error
 // on any error system tries to restore users usage counter
 // but on this error it does not
@@ -19,3 +24,25 @@ func (e *ErrNonRestorableUsage) Error() string {
 func (e *ErrNonRestorableUsage) Unwrap() error {
 	return e.err
 }
+
+// ErrJobCanceled is the cause of a context canceled because the user canceled the job
+var ErrJobCanceled = errors.New("job canceled")
+
+// ErrCanceled indicates the job was canceled by the user
+// Part is the part of the usage to restore: 0 - nothing, 1 - all
+type ErrCanceled struct {
+	Part float64
+}
+
+// NewErrCanceled creates new error
+func NewErrCanceled(part float64) error {
+	return &ErrCanceled{Part: part}
+}
+
+func (e *ErrCanceled) Error() string {
+	return fmt.Sprintf("job canceled, restore part %.2f", e.Part)
+}
+
+func (e *ErrCanceled) Unwrap() error {
+	return ErrJobCanceled
+}
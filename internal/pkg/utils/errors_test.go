@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"testing"
 
@@ -15,3 +16,12 @@ func TestErrNonRestorableUsage_Error(t *testing.T) {
 func TestErrNonRestorableUsage_Unwrap(t *testing.T) {
 	assert.True(t, errors.Is(NewErrNonRestorableUsage(io.EOF), io.EOF))
 }
+
+func TestErrCanceled(t *testing.T) {
+	err := NewErrCanceled(0.25)
+	assert.Equal(t, "job canceled, restore part 0.25", err.Error())
+	assert.True(t, errors.Is(err, ErrJobCanceled))
+	var errTest *ErrCanceled
+	assert.True(t, errors.As(fmt.Errorf("wrap: %w", err), &errTest))
+	assert.InDelta(t, 0.25, errTest.Part, 0.0001)
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsInternalHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "host", want: false},
+		{host: "example.com", want: false},
+		{host: "8.8.8.8", want: false},
+		{host: "2001:4860:4860::8888", want: false},
+		{host: "localhost", want: true},
+		{host: "LocalHost.", want: true},
+		{host: "a.localhost", want: true},
+		{host: "127.0.0.1", want: true},
+		{host: "::1", want: true},
+		{host: "[::1]", want: true},
+		{host: "10.0.0.1", want: true},
+		{host: "172.16.5.4", want: true},
+		{host: "192.168.1.1", want: true},
+		{host: "169.254.169.254", want: true},
+		{host: "fe80::1", want: true},
+		{host: "fd00::1", want: true},
+		{host: "0.0.0.0", want: true},
+		{host: "224.0.0.1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsInternalHost(tt.host))
+		})
+	}
+}